@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/caiofilipini/pingo/pinger"
+)
+
+// aggregate rolls up the runs of several hosts into a single summary of
+// their total loss, worst latencies and unreachable hosts.
+type aggregate struct {
+	hosts       int
+	transmitted int
+	received    int
+
+	// unreachable is the number of hosts that were sent requests, but
+	// never replied to any.
+	unreachable int
+
+	// worstP99 is the highest 99th percentile of the round-trip latencies
+	// of a host, in milliseconds, and worstHost the host it is of.
+	worstP99  float64
+	worstHost string
+}
+
+// add adds the run of host, which was sent transmitted requests and
+// replied to received of them, the 99th percentile of whose round-trip
+// latencies was p99, in milliseconds.
+func (a *aggregate) add(host string, transmitted int, received int, p99 float64) {
+	a.hosts++
+	a.transmitted += transmitted
+	a.received += received
+	if transmitted > 0 && received == 0 {
+		a.unreachable++
+	}
+	if received > 0 && (a.worstHost == "" || p99 > a.worstP99) {
+		a.worstP99, a.worstHost = p99, host
+	}
+}
+
+// addStats adds the run of host out of its stats.
+func (a *aggregate) addStats(host string, stats pinger.Stats) {
+	a.add(host, stats.Transmitted(), stats.Received(), stats.Percentiles(99)[0])
+}
+
+// format formats the aggregate, with the worst latencies in unit, e.g.
+//
+//	3 hosts, 30 packets transmitted, 20 packets received, 33.3% packet loss, worst p99 = 12.3 ms (b.example.com), 1 unreachable
+func (a *aggregate) format(unit string) string {
+	loss := 0.0
+	if a.transmitted > 0 {
+		loss = (1 - float64(a.received)/float64(a.transmitted)) * 100
+	}
+	line := fmt.Sprintf("%d hosts, %d packets transmitted, %d packets received, %.1f%% packet loss", a.hosts, a.transmitted, a.received, loss)
+	if a.worstHost != "" {
+		line += fmt.Sprintf(", worst p99 = %s (%s)", formatRTTs(unit, a.worstP99), a.worstHost)
+	}
+	return line + fmt.Sprintf(", %d unreachable", a.unreachable)
+}
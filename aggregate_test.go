@@ -0,0 +1,64 @@
+package main
+
+import "testing"
+
+func TestAggregate(t *testing.T) {
+	type run struct {
+		host        string
+		transmitted int
+		received    int
+		p99         float64
+	}
+
+	tests := []struct {
+		desc     string
+		runs     []run
+		expected string
+	}{
+		{
+			desc: "all hosts reachable",
+			runs: []run{
+				{host: "a.example.com", transmitted: 10, received: 10, p99: 12.5},
+				{host: "b.example.com", transmitted: 10, received: 9, p99: 30},
+			},
+			expected: "2 hosts, 20 packets transmitted, 19 packets received, 5.0% packet loss, worst p99 = 30.000 ms (b.example.com), 0 unreachable",
+		},
+		{
+			desc: "unreachable host",
+			runs: []run{
+				{host: "a.example.com", transmitted: 10, received: 10, p99: 12.5},
+				{host: "b.example.com", transmitted: 10, received: 0},
+				{host: "c.example.com", transmitted: 10, received: 10, p99: 8},
+			},
+			expected: "3 hosts, 30 packets transmitted, 20 packets received, 33.3% packet loss, worst p99 = 12.500 ms (a.example.com), 1 unreachable",
+		},
+		{
+			desc: "no host reachable",
+			runs: []run{
+				{host: "a.example.com", transmitted: 5, received: 0},
+				{host: "b.example.com", transmitted: 5, received: 0},
+			},
+			expected: "2 hosts, 10 packets transmitted, 0 packets received, 100.0% packet loss, 2 unreachable",
+		},
+		{
+			desc: "host never sent requests",
+			runs: []run{
+				{host: "a.example.com", transmitted: 10, received: 10, p99: 0.5},
+				{host: "b.example.com"},
+			},
+			expected: "2 hosts, 10 packets transmitted, 10 packets received, 0.0% packet loss, worst p99 = 0.500 ms (a.example.com), 0 unreachable",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			var a aggregate
+			for _, r := range tc.runs {
+				a.add(r.host, r.transmitted, r.received, r.p99)
+			}
+			if got := a.format("ms"); got != tc.expected {
+				t.Errorf("wanted %s, got %s", tc.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+// Package asn looks up the origin autonomous system of addresses using
+// Team Cymru's IP to ASN mapping service over DNS
+// (https://www.team-cymru.com/ip-asn-mapping).
+package asn
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// originZone is the zone queried for the origin of IPv4 addresses.
+	originZone = "origin.asn.cymru.com"
+
+	// origin6Zone is the zone queried for the origin of IPv6 addresses.
+	origin6Zone = "origin6.asn.cymru.com"
+
+	// nameZone is the zone queried for the name of an AS.
+	nameZone = "asn.cymru.com"
+)
+
+// Info holds the origin AS of an address.
+type Info struct {
+	// ASN is the number of the origin AS.
+	ASN uint
+
+	// Prefix is the announced prefix containing the address.
+	Prefix string
+
+	// Country is the country code the AS is registered in.
+	Country string
+
+	// Name is the name of the AS.
+	Name string
+}
+
+// String formats the info as "AS15169 GOOGLE, US".
+func (i Info) String() string {
+	if i.Name == "" {
+		return fmt.Sprintf("AS%d", i.ASN)
+	}
+	return fmt.Sprintf("AS%d %s", i.ASN, i.Name)
+}
+
+// lookupTXT is the function used for DNS TXT lookups.
+type lookupTXT func(ctx context.Context, name string) ([]string, error)
+
+// Client looks up the origin AS of addresses, caching the results. Failed
+// lookups are not cached, so they are retried next time.
+type Client struct {
+	// Timeout bounds each lookup, including that of the name of the AS.
+	Timeout time.Duration
+
+	lookup  lookupTXT
+	mu      sync.Mutex
+	cache   map[string]Info
+	pending map[string]bool
+}
+
+// NewClient returns a new Client using the system resolver, with a
+// default timeout of 2s.
+func NewClient() *Client {
+	return &Client{
+		Timeout: 2 * time.Second,
+		lookup:  net.DefaultResolver.LookupTXT,
+		cache:   make(map[string]Info),
+		pending: make(map[string]bool),
+	}
+}
+
+// Lookup returns the origin AS of ip, querying it unless it is cached.
+func (c *Client) Lookup(ip net.IP) (Info, error) {
+	if info, ok := c.Cached(ip); ok {
+		return info, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+	defer cancel()
+	info, err := c.query(ctx, ip)
+	if err != nil {
+		return Info{}, err
+	}
+
+	c.mu.Lock()
+	c.cache[ip.String()] = info
+	c.mu.Unlock()
+
+	return info, nil
+}
+
+// Cached returns the origin AS of ip if it was already looked up, without
+// querying it otherwise.
+func (c *Client) Cached(ip net.IP) (Info, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	info, ok := c.cache[ip.String()]
+	return info, ok
+}
+
+// Prefetch looks up the origin AS of ip in the background, unless it is
+// cached or already being looked up, making it available to Cached once
+// the lookup succeeds.
+func (c *Client) Prefetch(ip net.IP) {
+	key := ip.String()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.cache[key]; ok || c.pending[key] {
+		return
+	}
+	c.pending[key] = true
+
+	go func() {
+		c.Lookup(ip)
+
+		c.mu.Lock()
+		delete(c.pending, key)
+		c.mu.Unlock()
+	}()
+}
+
+// query looks up the origin AS of ip and its name.
+func (c *Client) query(ctx context.Context, ip net.IP) (Info, error) {
+	txt, err := c.lookup(ctx, originQuery(ip))
+	if err != nil {
+		return Info{}, fmt.Errorf("cannot look up origin of %v: %v", ip, err)
+	}
+	if len(txt) == 0 {
+		return Info{}, fmt.Errorf("cannot look up origin of %v: no records", ip)
+	}
+	info, err := parseOrigin(txt[0])
+	if err != nil {
+		return Info{}, err
+	}
+
+	// The name is a nice-to-have, so failing to look it up is not fatal.
+	if txt, err := c.lookup(ctx, fmt.Sprintf("AS%d.%s", info.ASN, nameZone)); err == nil && len(txt) > 0 {
+		info.Name = parseName(txt[0])
+	}
+	return info, nil
+}
+
+// originQuery returns the name to be queried for the origin of ip, i.e.
+// its reversed octets (or nibbles, for IPv6) under the origin zone.
+func originQuery(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return fmt.Sprintf("%d.%d.%d.%d.%s", ip4[3], ip4[2], ip4[1], ip4[0], originZone)
+	}
+
+	ip6 := ip.To16()
+	var b strings.Builder
+	for i := len(ip6) - 1; i >= 0; i-- {
+		fmt.Fprintf(&b, "%x.%x.", ip6[i]&0x0f, ip6[i]>>4)
+	}
+	return b.String() + origin6Zone
+}
+
+// parseOrigin parses an origin record, e.g.
+// "15169 | 8.8.8.0/24 | US | arin | 2023-12-28". Addresses announced by
+// multiple ASes list all of them, in which case the first one is used.
+func parseOrigin(txt string) (Info, error) {
+	fields := splitFields(txt)
+	if len(fields) < 3 {
+		return Info{}, fmt.Errorf("unexpected origin record: %q", txt)
+	}
+
+	asns := strings.Fields(fields[0])
+	if len(asns) == 0 {
+		return Info{}, fmt.Errorf("unexpected origin record: %q", txt)
+	}
+	asn, err := strconv.ParseUint(asns[0], 10, 32)
+	if err != nil {
+		return Info{}, fmt.Errorf("unexpected origin record: %q", txt)
+	}
+
+	return Info{
+		ASN:     uint(asn),
+		Prefix:  fields[1],
+		Country: fields[2],
+	}, nil
+}
+
+// parseName parses the name out of an AS record, e.g.
+// "15169 | US | arin | 2000-03-30 | GOOGLE, US".
+func parseName(txt string) string {
+	fields := splitFields(txt)
+	if len(fields) < 5 {
+		return ""
+	}
+	return fields[4]
+}
+
+// splitFields splits a record into its "|"-separated fields.
+func splitFields(txt string) []string {
+	fields := strings.Split(txt, "|")
+	for i, f := range fields {
+		fields[i] = strings.TrimSpace(f)
+	}
+	return fields
+}
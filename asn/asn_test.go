@@ -0,0 +1,174 @@
+package asn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestOriginQuery(t *testing.T) {
+	tests := []struct {
+		desc     string
+		ip       net.IP
+		expected string
+	}{
+		{
+			desc:     "reverses the octets of an IPv4 address",
+			ip:       net.ParseIP("8.8.4.4"),
+			expected: "4.4.8.8.origin.asn.cymru.com",
+		},
+		{
+			desc:     "reverses the nibbles of an IPv6 address",
+			ip:       net.ParseIP("2001:db8::1"),
+			expected: "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.origin6.asn.cymru.com",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			query := originQuery(tc.ip)
+			if query != tc.expected {
+				t.Errorf("wanted %s, got %s", tc.expected, query)
+			}
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	records := map[string][]string{
+		"8.8.8.8.origin.asn.cymru.com": {"15169 | 8.8.8.0/24 | US | arin | 2023-12-28"},
+		"1.1.1.1.origin.asn.cymru.com": {"13335 64512 | 1.1.1.0/24 | AU | apnic | 2011-08-11"},
+		"AS15169.asn.cymru.com":        {"15169 | US | arin | 2000-03-30 | GOOGLE, US"},
+		"9.9.9.9.origin.asn.cymru.com": {"garbage"},
+	}
+
+	tests := []struct {
+		desc     string
+		ip       string
+		expected Info
+		wantErr  bool
+	}{
+		{
+			desc:     "returns the origin and name of the AS",
+			ip:       "8.8.8.8",
+			expected: Info{ASN: 15169, Prefix: "8.8.8.0/24", Country: "US", Name: "GOOGLE, US"},
+		},
+		{
+			desc:     "returns the first AS of a multi-origin prefix without a name",
+			ip:       "1.1.1.1",
+			expected: Info{ASN: 13335, Prefix: "1.1.1.0/24", Country: "AU"},
+		},
+		{
+			desc:    "fails for an unexpected record",
+			ip:      "9.9.9.9",
+			wantErr: true,
+		},
+		{
+			desc:    "fails for an address without records",
+			ip:      "10.0.0.1",
+			wantErr: true,
+		},
+	}
+
+	c := NewClient()
+	c.lookup = func(ctx context.Context, name string) ([]string, error) {
+		txt, ok := records[name]
+		if !ok {
+			return nil, fmt.Errorf("no such host")
+		}
+		return txt, nil
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			info, err := c.Lookup(net.ParseIP(tc.ip))
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("wanted an error, got %v", info)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("wanted no error, got %v", err)
+			}
+			if info != tc.expected {
+				t.Errorf("wanted %+v, got %+v", tc.expected, info)
+			}
+		})
+	}
+}
+
+func TestLookupRetriesFailures(t *testing.T) {
+	fail := true
+	c := NewClient()
+	c.lookup = func(ctx context.Context, name string) ([]string, error) {
+		if fail {
+			return nil, errors.New("server misbehaving")
+		}
+		return []string{"15169 | 8.8.8.0/24 | US | arin | 2023-12-28"}, nil
+	}
+
+	ip := net.ParseIP("8.8.8.8")
+	if _, err := c.Lookup(ip); err == nil {
+		t.Fatal("wanted an error, got none")
+	}
+	if info, ok := c.Cached(ip); ok {
+		t.Errorf("wanted the failure not to be cached, got %+v", info)
+	}
+
+	fail = false
+	info, err := c.Lookup(ip)
+	if err != nil {
+		t.Fatalf("wanted no error, got %v", err)
+	}
+	if info.ASN != 15169 {
+		t.Errorf("wanted 15169, got %d", info.ASN)
+	}
+}
+
+func TestLookupTimeout(t *testing.T) {
+	c := NewClient()
+	c.Timeout = 10 * time.Millisecond
+	c.lookup = func(ctx context.Context, name string) ([]string, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	start := time.Now()
+	if _, err := c.Lookup(net.ParseIP("8.8.8.8")); err == nil {
+		t.Fatal("wanted an error, got none")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("wanted the lookup to time out, took %v", elapsed)
+	}
+}
+
+func TestPrefetch(t *testing.T) {
+	c := NewClient()
+	c.lookup = func(ctx context.Context, name string) ([]string, error) {
+		return []string{"15169 | 8.8.8.0/24 | US | arin | 2023-12-28"}, nil
+	}
+
+	ip := net.ParseIP("8.8.8.8")
+	if _, ok := c.Cached(ip); ok {
+		t.Fatal("wanted nothing cached before prefetching")
+	}
+	c.Prefetch(ip)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if info, ok := c.Cached(ip); ok {
+			if info.ASN != 15169 {
+				t.Errorf("wanted 15169, got %d", info.ASN)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("wanted the prefetched info to be cached")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
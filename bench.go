@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/caiofilipini/pingo/pinger"
+)
+
+// runBench sends requests to hosts in turn at rate requests per second for
+// duration, or until interrupted, regardless of whether previous requests
+// were replied to, and prints the loss and latencies of each host under
+// that load, and of all of them, along with the rate actually achieved,
+// flagging runs whose sender could not keep up. RTTs are printed in unit.
+func runBench(hosts []string, rate float64, duration time.Duration, unit string, opts pinger.Options) {
+	addrs := make([]net.Addr, len(hosts))
+	width := 0
+	for i, host := range hosts {
+		addr, err := opts.Resolve(context.Background(), host)
+		if err != nil {
+			fmt.Printf("failed to resolve host %s: %v\n", host, err)
+			os.Exit(2)
+		}
+		addrs[i] = addr
+		if len(host) > width {
+			width = len(host)
+		}
+	}
+	fmt.Printf("BENCH %s at %g requests/s for %v: %d data bytes\n", joinAddrs(addrs), rate, duration, opts.PacketSize)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	report, err := pinger.Load(ctx, addrs, rate, duration, opts)
+	if err != nil {
+		fmt.Printf("failed to benchmark: %v\n", err)
+		os.Exit(2)
+	}
+
+	fmt.Println()
+	fmt.Println("--- bench statistics ---")
+	sent := 0
+	var total aggregate
+	for i, stats := range report.Stats {
+		sent += stats.Transmitted()
+		total.addStats(hosts[i], stats)
+		line := fmt.Sprintf("%-*s %d packets transmitted, %d packets received, %.1f%% packet loss", width, hosts[i], stats.Transmitted(), stats.Received(), stats.PacketLoss())
+		if stats.Received() > 0 {
+			min, avg, max, _ := stats.RTTStats()
+			ps := stats.Percentiles(50, 99)
+			line += ", round-trip min/p50/avg/p99/max = " + formatRTTs(unit, min, ps[0], avg, ps[1], max)
+		}
+		fmt.Println(line)
+	}
+	if len(hosts) > 1 {
+		fmt.Println(total.format(unit))
+	}
+	fmt.Printf("%d packets sent in %.3fs: %.1f requests/s achieved of %g targeted, max lag %v, %d send errors\n",
+		sent, report.Elapsed.Seconds(), report.Achieved, report.Rate, report.MaxLag.Round(time.Microsecond), report.SendErrors)
+	if report.SenderLimited() {
+		fmt.Println("sender could not keep up with the target rate: it is the bottleneck, so loss and latencies may not reflect the network")
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/caiofilipini/pingo/pinger"
+)
+
+// loadStreams is the number of parallel downloads used for saturating the
+// link with a load URL.
+const loadStreams = 4
+
+// defaultBloatCount is the number of requests of each phase of a
+// bufferbloat test when no count is specified.
+const defaultBloatCount = 10
+
+// loadGenerator saturates the link until ctx is done.
+type loadGenerator func(ctx context.Context) error
+
+// downloadLoad returns a load generator downloading url over and over
+// again, over loadStreams parallel connections.
+func downloadLoad(url string) loadGenerator {
+	return func(ctx context.Context) error {
+		errs := make(chan error, loadStreams)
+		var wg sync.WaitGroup
+		for i := 0; i < loadStreams; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for ctx.Err() == nil {
+					if err := download(ctx, url); err != nil && ctx.Err() == nil {
+						errs <- err
+						return
+					}
+				}
+			}()
+		}
+		wg.Wait()
+
+		select {
+		case err := <-errs:
+			return err
+		default:
+			return nil
+		}
+	}
+}
+
+// download fetches url, discarding its body.
+func download(ctx context.Context, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status downloading %s: %s", url, res.Status)
+	}
+	_, err = io.Copy(io.Discard, res.Body)
+	return err
+}
+
+// commandLoad returns a load generator running cmd through the shell,
+// e.g. an iperf3 client, killing it once the loaded phase is over.
+func commandLoad(cmd string) loadGenerator {
+	return func(ctx context.Context) error {
+		c := exec.CommandContext(ctx, "sh", "-c", cmd)
+		c.Stderr = os.Stderr
+		if err := c.Run(); err != nil && ctx.Err() == nil {
+			return fmt.Errorf("load command failed: %v", err)
+		}
+		return nil
+	}
+}
+
+// measureBufferbloat pings addr while the link is idle, and then while
+// load saturates it, reporting how much latency increases under load, with
+// round-trip latencies in unit.
+func measureBufferbloat(host string, addr net.Addr, unit string, opts pinger.Options, load loadGenerator) {
+	if opts.Count == 0 {
+		opts.Count = defaultBloatCount
+	}
+	fmt.Printf("BUFFERBLOAT %s (%v): %d requests idle, then %d under load\n", host, addr, opts.Count, opts.Count)
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+
+	idle, interrupted := bloatPhase("idle", host, addr, unit, opts, sig)
+	if interrupted {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	loadErr := make(chan error, 1)
+	go func() {
+		loadErr <- load(ctx)
+	}()
+	loaded, _ := bloatPhase("loaded", host, addr, unit, opts, sig)
+	cancel()
+	if err := <-loadErr; err != nil {
+		fmt.Printf("failed to generate load: %v\n", err)
+		os.Exit(2)
+	}
+
+	printBufferbloat(host, idle, loaded, unit)
+}
+
+// bloatPhase pings addr with opts, printing the results labeled with
+// phase with round-trip latencies in unit, and returns the stats, along
+// with whether it was interrupted.
+func bloatPhase(phase string, host string, addr net.Addr, unit string, opts pinger.Options, sig <-chan os.Signal) (pinger.Stats, bool) {
+	p := pinger.NewPinger(&opts)
+	results, errs := p.Report()
+	go p.Ping(addr)
+
+	interrupted := false
+	for {
+		select {
+		case <-sig:
+			interrupted = true
+			p.Stop()
+		case res, ok := <-results:
+			if !ok {
+				if err, ok := <-errs; ok {
+					failPing(host, err)
+				}
+				return p.Stats(), interrupted
+			}
+			fmt.Printf("%-6s icmp_seq=%d %s\n", phase, res.Seq, formatStatus(res, unit))
+		}
+	}
+}
+
+// printBufferbloat prints the latencies of both phases, followed by the
+// increase of the median round-trip under load and its grade, in unit.
+func printBufferbloat(host string, idle pinger.Stats, loaded pinger.Stats, unit string) {
+	fmt.Println()
+	fmt.Printf("--- %s bufferbloat statistics ---\n", host)
+
+	var medians [2]float64
+	for i, phase := range []struct {
+		label string
+		stats pinger.Stats
+	}{{"idle", idle}, {"loaded", loaded}} {
+		min, avg, max, stddev := phase.stats.RTTStats()
+		medians[i] = phase.stats.Percentiles(50)[0]
+		fmt.Printf(
+			"%-6s %d packets transmitted, %d packets received, %.1f%% packet loss, round-trip min/median/avg/max/stddev = %s\n",
+			phase.label,
+			phase.stats.Transmitted(),
+			phase.stats.Received(),
+			phase.stats.PacketLoss(),
+			formatRTTs(unit, min, medians[i], avg, max, stddev),
+		)
+	}
+
+	increase := medians[1] - medians[0]
+	scale := rttScaleOf(unit, increase)
+	fmt.Printf("latency increase under load = %+.*f %s median round-trip, grade %s\n", scale.digits, increase/scale.ms, scale.label, bloatGrade(increase))
+}
+
+// bloatGrade grades the increase of latency under load, in milliseconds,
+// on the scale popularized by the DSLReports speed test.
+func bloatGrade(increase float64) string {
+	switch {
+	case increase < 5:
+		return "A+"
+	case increase < 30:
+		return "A"
+	case increase < 60:
+		return "B"
+	case increase < 200:
+		return "C"
+	case increase < 400:
+		return "D"
+	default:
+		return "F"
+	}
+}
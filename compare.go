@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/caiofilipini/pingo/math"
+	"github.com/caiofilipini/pingo/pinger"
+)
+
+// probe describes one of the runs being compared: the host pinged, and the
+// options used for pinging it.
+type probe struct {
+	label string
+	addr  net.Addr
+	opts  pinger.Options
+}
+
+// compareHosts pings hostA and hostB simultaneously with identical options,
+// printing round-trip latencies in unit.
+func compareHosts(hostA string, hostB string, unit string, opts pinger.Options) {
+	var probes []probe
+	for _, host := range []string{hostA, hostB} {
+		addr, err := opts.Resolve(context.Background(), host)
+		if err != nil {
+			fmt.Printf("failed to resolve host %s: %v\n", host, err)
+			os.Exit(2)
+		}
+		probes = append(probes, probe{label: host, addr: addr, opts: opts})
+	}
+
+	fmt.Printf("COMPARE %s (%v) vs %s (%v): %d data bytes\n", hostA, probes[0].addr, hostB, probes[1].addr, opts.PacketSize)
+	compare(probes, unit)
+}
+
+// compareSources pings host from each of the given source addresses
+// simultaneously, with otherwise identical options, printing round-trip
+// latencies in unit.
+func compareSources(host string, addr net.Addr, sources []net.IP, unit string, opts pinger.Options) {
+	var probes []probe
+	var labels []string
+	for _, src := range sources {
+		srcOpts := opts
+		srcOpts.Source = src
+		probes = append(probes, probe{label: src.String(), addr: addr, opts: srcOpts})
+		labels = append(labels, src.String())
+	}
+
+	fmt.Printf("PING %s (%v) from %s: %d data bytes\n", host, addr, strings.Join(labels, ", "), opts.PacketSize)
+	compare(probes, unit)
+}
+
+// compareDSCP pings host with each of the given DSCP values
+// simultaneously, with otherwise identical options, exposing whether the
+// network differentiates between those traffic classes. Round-trip
+// latencies are printed in unit.
+func compareDSCP(host string, addr net.Addr, values []uint, unit string, opts pinger.Options) {
+	var probes []probe
+	var labels []string
+	for _, dscp := range values {
+		dscpOpts := opts
+		dscpOpts.DSCP = dscp
+		label := fmt.Sprintf("dscp=%d", dscp)
+		probes = append(probes, probe{label: label, addr: addr, opts: dscpOpts})
+		labels = append(labels, strconv.Itoa(int(dscp)))
+	}
+
+	fmt.Printf("PING %s (%v) with DSCP %s: %d data bytes\n", host, addr, strings.Join(labels, ", "), opts.PacketSize)
+	compare(probes, unit)
+}
+
+// compare runs the given probes simultaneously, printing their results as
+// they arrive, labeled accordingly, followed by a comparative summary, with
+// round-trip latencies in unit.
+func compare(probes []probe, unit string) {
+	pingers := make([]pinger.Pinger, len(probes))
+	for i := range probes {
+		pingers[i] = pinger.NewPinger(&probes[i].opts)
+	}
+
+	type result struct {
+		label string
+		ping  pinger.Ping
+	}
+	results := make(chan result)
+	errors := make(chan error, len(probes))
+
+	var wg sync.WaitGroup
+	for i, p := range pingers {
+		wg.Add(1)
+		go func(pr probe, p pinger.Pinger) {
+			defer wg.Done()
+
+			pings, errs := p.Report()
+			go p.Ping(pr.addr)
+
+			for ping := range pings {
+				results <- result{pr.label, ping}
+			}
+			if err, ok := <-errs; ok {
+				errors <- fmt.Errorf("failed to ping %v (%s): %v", pr.addr, pr.label, err)
+			}
+		}(probes[i], p)
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+
+	width := 0
+	for _, pr := range probes {
+		if len(pr.label) > width {
+			width = len(pr.label)
+		}
+	}
+
+	interrupted := false
+	for stop := false; !stop; {
+		select {
+		case <-sig:
+			// A second interrupt terminates right away, even if the
+			// requests in flight hang.
+			if interrupted {
+				for _, p := range pingers {
+					p.StopWait(0)
+				}
+				stop = true
+				continue
+			}
+			interrupted = true
+			for _, p := range pingers {
+				p.Stop()
+			}
+		case res, ok := <-results:
+			if !ok {
+				stop = true
+				continue
+			}
+			fmt.Printf("%-*s icmp_seq=%d %s\n", width, res.label, res.ping.Seq, formatStatus(res.ping, unit))
+		case err := <-errors:
+			fmt.Println(err)
+			os.Exit(2)
+		}
+	}
+
+	stats := make([]pinger.Stats, len(pingers))
+	for i, p := range pingers {
+		stats[i] = p.Stats()
+	}
+	printComparison(probes, stats, width, unit)
+}
+
+// formatStatus formats the outcome of a request in a few words, with its
+// round-trip latency in unit.
+func formatStatus(res pinger.Ping, unit string) string {
+	switch {
+	case res.Timeout:
+		return "timeout"
+	case res.TimeExceeded:
+		return fmt.Sprintf("ttl exceeded from %v", res.Hop.Addr)
+	case res.Unreachable:
+		return "unreachable"
+	case res.Remarked:
+		return fmt.Sprintf("time=%s (re-marked to dscp=%d)", formatRTTs(unit, math.TimeInMillis(res.RTT)), res.DSCP)
+	default:
+		return fmt.Sprintf("time=%s", formatRTTs(unit, math.TimeInMillis(res.RTT)))
+	}
+}
+
+// printComparison prints the loss and latencies of each probe side by side,
+// followed by how those of the others differ from the first's, in unit.
+func printComparison(probes []probe, stats []pinger.Stats, width int, unit string) {
+	labels := make([]string, len(probes))
+	medians := make([]float64, len(stats))
+
+	for i, pr := range probes {
+		labels[i] = pr.label
+	}
+
+	fmt.Println()
+	fmt.Printf("--- %s ping statistics ---\n", strings.Join(labels, " vs "))
+	for i, s := range stats {
+		min, avg, max, stddev := s.RTTStats()
+		medians[i] = s.Percentiles(50)[0]
+		fmt.Printf(
+			"%-*s %d packets transmitted, %d packets received, %.1f%% packet loss, round-trip min/median/avg/max/stddev = %s\n",
+			width,
+			labels[i],
+			s.Transmitted(),
+			s.Received(),
+			s.PacketLoss(),
+			formatRTTs(unit, min, medians[i], avg, max, stddev),
+		)
+	}
+
+	for i := 1; i < len(stats); i++ {
+		diff := medians[i] - medians[0]
+		scale := rttScaleOf(unit, diff)
+		fmt.Printf(
+			"%s - %s: %+.1f%% packet loss, %+.*f %s median round-trip\n",
+			labels[i],
+			labels[0],
+			stats[i].PacketLoss()-stats[0].PacketLoss(),
+			scale.digits, diff/scale.ms, scale.label,
+		)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// consulTimeout is the timeout of requests to the Consul HTTP API.
+const consulTimeout = 10 * time.Second
+
+// consulDefaultAddr returns the address of the Consul HTTP API set in the
+// CONSUL_HTTP_ADDR environment variable, like the Consul CLI does, or
+// that of the local agent.
+func consulDefaultAddr() string {
+	if addr := os.Getenv("CONSUL_HTTP_ADDR"); addr != "" {
+		return addr
+	}
+	return "http://127.0.0.1:8500"
+}
+
+// consulEntry is the part of an entry returned by the health endpoint of
+// the Consul HTTP API that is used for finding the address of a service
+// instance.
+type consulEntry struct {
+	Node struct {
+		Address string
+	}
+	Service struct {
+		Address string
+	}
+}
+
+// consulDiscoverer returns a discoverer of the addresses of the instances
+// of service registered in the Consul catalog reachable at addr, e.g.
+// http://127.0.0.1:8500. Only instances with every one of tags are
+// returned and, if passing is set, only those passing their health checks.
+// The ACL token in the CONSUL_HTTP_TOKEN environment variable, if any, is
+// used for the requests.
+func consulDiscoverer(addr string, service string, tags []string, passing bool) discoverer {
+	client := &http.Client{Timeout: consulTimeout}
+
+	query := url.Values{}
+	for _, tag := range tags {
+		query.Add("tag", tag)
+	}
+	if passing {
+		query.Set("passing", "true")
+	}
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+	endpoint := fmt.Sprintf("%s/v1/health/service/%s?%s", strings.TrimSuffix(addr, "/"), url.PathEscape(service), query.Encode())
+
+	return func() ([]string, error) {
+		req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+		if err != nil {
+			return nil, fmt.Errorf("cannot query Consul for service %s: %v", service, err)
+		}
+		if token := os.Getenv("CONSUL_HTTP_TOKEN"); token != "" {
+			req.Header.Set("X-Consul-Token", token)
+		}
+
+		res, err := client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("cannot query Consul for service %s: %v", service, err)
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("cannot query Consul for service %s: %s", service, res.Status)
+		}
+
+		var entries []consulEntry
+		if err := json.NewDecoder(res.Body).Decode(&entries); err != nil {
+			return nil, fmt.Errorf("cannot decode Consul response for service %s: %v", service, err)
+		}
+
+		seen := make(map[string]bool, len(entries))
+		var hosts []string
+		for _, e := range entries {
+			// Instances registered without an address of their own
+			// are reachable at the address of their node.
+			host := e.Service.Address
+			if host == "" {
+				host = e.Node.Address
+			}
+			if host == "" || seen[host] {
+				continue
+			}
+			seen[host] = true
+			hosts = append(hosts, host)
+		}
+		sort.Strings(hosts)
+		return hosts, nil
+	}
+}
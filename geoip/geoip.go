@@ -0,0 +1,111 @@
+// Package geoip enriches addresses with geolocation and AS information
+// read from MaxMind databases (e.g. GeoLite2-City and GeoLite2-ASN).
+package geoip
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// Location holds the geolocation and AS information of an address.
+// Fields not found in the databases are left zeroed.
+type Location struct {
+	// Country is the ISO 3166-1 code of the country.
+	Country string
+
+	// City is the English name of the city.
+	City string
+
+	// ASN is the number of the autonomous system.
+	ASN uint
+
+	// ASOrg is the organization owning the autonomous system.
+	ASOrg string
+}
+
+// String formats the location as "AS15169 Google LLC, US Mountain View",
+// omitting the missing parts.
+func (l Location) String() string {
+	var parts []string
+	if l.ASN != 0 {
+		as := fmt.Sprintf("AS%d", l.ASN)
+		if l.ASOrg != "" {
+			as += " " + l.ASOrg
+		}
+		parts = append(parts, as)
+	}
+	if geo := strings.TrimSpace(l.Country + " " + l.City); geo != "" {
+		parts = append(parts, geo)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// record is the subset of the MaxMind City, Country and ASN database
+// records used for building a Location.
+type record struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	ASN   uint   `maxminddb:"autonomous_system_number"`
+	ASOrg string `maxminddb:"autonomous_system_organization"`
+}
+
+// DB is a set of MaxMind databases queried together.
+type DB struct {
+	readers []*maxminddb.Reader
+}
+
+// Open opens the MaxMind databases at the given paths.
+func Open(paths ...string) (*DB, error) {
+	db := &DB{}
+	for _, path := range paths {
+		r, err := maxminddb.Open(path)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("cannot open GeoIP database %s: %v", path, err)
+		}
+		db.readers = append(db.readers, r)
+	}
+	return db, nil
+}
+
+// Lookup returns the Location of ip, merging the information found in
+// each of the databases.
+func (db *DB) Lookup(ip net.IP) (Location, error) {
+	var loc Location
+	for _, r := range db.readers {
+		var rec record
+		if err := r.Lookup(ip, &rec); err != nil {
+			return Location{}, fmt.Errorf("cannot look up %v: %v", ip, err)
+		}
+
+		if rec.Country.ISOCode != "" {
+			loc.Country = rec.Country.ISOCode
+		}
+		if city := rec.City.Names["en"]; city != "" {
+			loc.City = city
+		}
+		if rec.ASN != 0 {
+			loc.ASN = rec.ASN
+			loc.ASOrg = rec.ASOrg
+		}
+	}
+	return loc, nil
+}
+
+// Close closes the databases.
+func (db *DB) Close() error {
+	var err error
+	for _, r := range db.readers {
+		if cerr := r.Close(); cerr != nil {
+			err = cerr
+		}
+	}
+	return err
+}
@@ -0,0 +1,107 @@
+package geoip
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+)
+
+// The databases in testdata are written by testdata/gen.go.
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		desc     string
+		dbs      []string
+		ip       net.IP
+		expected Location
+	}{
+		{
+			desc:     "city database",
+			dbs:      []string{"GeoLite2-City-Test.mmdb"},
+			ip:       net.ParseIP("1.1.1.1"),
+			expected: Location{Country: "AU", City: "Sydney"},
+		},
+		{
+			desc:     "country database",
+			dbs:      []string{"GeoLite2-Country-Test.mmdb"},
+			ip:       net.ParseIP("8.8.8.8"),
+			expected: Location{Country: "US"},
+		},
+		{
+			desc:     "ASN database",
+			dbs:      []string{"GeoLite2-ASN-Test.mmdb"},
+			ip:       net.ParseIP("8.8.8.8"),
+			expected: Location{ASN: 15169, ASOrg: "Google LLC"},
+		},
+		{
+			desc:     "merges city and ASN databases",
+			dbs:      []string{"GeoLite2-City-Test.mmdb", "GeoLite2-ASN-Test.mmdb"},
+			ip:       net.ParseIP("1.1.1.1"),
+			expected: Location{Country: "AU", City: "Sydney", ASN: 13335, ASOrg: "Cloudflare, Inc."},
+		},
+		{
+			desc:     "address not in the databases",
+			dbs:      []string{"GeoLite2-City-Test.mmdb", "GeoLite2-ASN-Test.mmdb"},
+			ip:       net.ParseIP("192.0.2.1"),
+			expected: Location{},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			paths := make([]string, len(tc.dbs))
+			for i, db := range tc.dbs {
+				paths[i] = filepath.Join("testdata", db)
+			}
+			db, err := Open(paths...)
+			if err != nil {
+				t.Fatalf("cannot open databases: %v", err)
+			}
+			defer db.Close()
+
+			loc, err := db.Lookup(tc.ip)
+			if err != nil {
+				t.Fatalf("wanted no error, got %v", err)
+			}
+			if loc != tc.expected {
+				t.Errorf("wanted %+v, got %+v", tc.expected, loc)
+			}
+		})
+	}
+}
+
+func TestOpenMissing(t *testing.T) {
+	if _, err := Open(filepath.Join("testdata", "missing.mmdb")); err == nil {
+		t.Error("wanted an error opening a missing database")
+	}
+}
+
+func TestLocationString(t *testing.T) {
+	tests := []struct {
+		desc     string
+		loc      Location
+		expected string
+	}{
+		{
+			desc:     "every part",
+			loc:      Location{Country: "US", City: "Mountain View", ASN: 15169, ASOrg: "Google LLC"},
+			expected: "AS15169 Google LLC, US Mountain View",
+		},
+		{
+			desc:     "country only",
+			loc:      Location{Country: "AU"},
+			expected: "AU",
+		},
+		{
+			desc:     "nothing known",
+			expected: "",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			if actual := tc.loc.String(); actual != tc.expected {
+				t.Errorf("wanted %q, got %q", tc.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+//go:build ignore
+
+// gen writes the MaxMind databases used in tests, each mapping 1.1.1.0/24
+// and 8.8.8.0/24 to a record like those of the GeoLite2 databases:
+//
+//	go run gen.go
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"log"
+	"net"
+	"os"
+	"sort"
+)
+
+func main() {
+	city := func(country string, city string) map[string]interface{} {
+		return map[string]interface{}{
+			"country": map[string]interface{}{"iso_code": country},
+			"city":    map[string]interface{}{"names": map[string]interface{}{"en": city}},
+		}
+	}
+	country := func(country string) map[string]interface{} {
+		return map[string]interface{}{
+			"country": map[string]interface{}{"iso_code": country},
+		}
+	}
+	as := func(number uint32, org string) map[string]interface{} {
+		return map[string]interface{}{
+			"autonomous_system_number":       number,
+			"autonomous_system_organization": org,
+		}
+	}
+
+	write("GeoLite2-City-Test.mmdb", "GeoLite2-City", map[string]map[string]interface{}{
+		"1.1.1.0/24": city("AU", "Sydney"),
+		"8.8.8.0/24": city("US", "Mountain View"),
+	})
+	write("GeoLite2-Country-Test.mmdb", "GeoLite2-Country", map[string]map[string]interface{}{
+		"1.1.1.0/24": country("AU"),
+		"8.8.8.0/24": country("US"),
+	})
+	write("GeoLite2-ASN-Test.mmdb", "GeoLite2-ASN", map[string]map[string]interface{}{
+		"1.1.1.0/24": as(13335, "Cloudflare, Inc."),
+		"8.8.8.0/24": as(15169, "Google LLC"),
+	})
+}
+
+// node is a node of the search tree, whose records are either other nodes
+// or data.
+type node struct {
+	children [2]*node
+	data     int
+}
+
+// write writes an IPv4 database of type dbType with 24-bit records to path,
+// mapping each network to its record.
+func write(path string, dbType string, records map[string]map[string]interface{}) {
+	var data bytes.Buffer
+	root := &node{data: -1}
+	networks := make([]string, 0, len(records))
+	for network := range records {
+		networks = append(networks, network)
+	}
+	sort.Strings(networks)
+	for _, network := range networks {
+		_, ipNet, err := net.ParseCIDR(network)
+		if err != nil {
+			log.Fatal(err)
+		}
+		offset := data.Len()
+		encode(&data, records[network])
+
+		ones, _ := ipNet.Mask.Size()
+		ip := ipNet.IP.To4()
+		n := root
+		for i := 0; i < ones; i++ {
+			bit := ip[i/8] >> (7 - uint(i%8)) & 1
+			if n.children[bit] == nil {
+				n.children[bit] = &node{data: -1}
+			}
+			n = n.children[bit]
+		}
+		n.data = offset
+	}
+
+	// Nodes are numbered breadth first, and leaves point to the data.
+	var nodes []*node
+	ids := map[*node]int{}
+	queue := []*node{root}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		if n.data >= 0 {
+			continue
+		}
+		ids[n] = len(nodes)
+		nodes = append(nodes, n)
+		for _, c := range n.children {
+			if c != nil {
+				queue = append(queue, c)
+			}
+		}
+	}
+
+	var out bytes.Buffer
+	count := len(nodes)
+	for _, n := range nodes {
+		for _, c := range n.children {
+			record := count // no data
+			switch {
+			case c == nil:
+			case c.data >= 0:
+				record = count + 16 + c.data
+			default:
+				record = ids[c]
+			}
+			out.Write([]byte{byte(record >> 16), byte(record >> 8), byte(record)})
+		}
+	}
+	out.Write(make([]byte, 16))
+	out.Write(data.Bytes())
+	out.WriteString("\xab\xcd\xefMaxMind.com")
+	encode(&out, map[string]interface{}{
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(1500000000),
+		"database_type":               dbType,
+		"description":                 map[string]interface{}{"en": dbType + " test database"},
+		"ip_version":                  uint16(4),
+		"languages":                   []interface{}{"en"},
+		"node_count":                  uint32(count),
+		"record_size":                 uint16(24),
+	})
+	if err := os.WriteFile(path, out.Bytes(), 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// encode appends v to b in the format of the data section.
+func encode(b *bytes.Buffer, v interface{}) {
+	switch v := v.(type) {
+	case string:
+		control(b, 2, len(v))
+		b.WriteString(v)
+	case uint16:
+		control(b, 5, 2)
+		binary.Write(b, binary.BigEndian, v)
+	case uint32:
+		control(b, 6, 4)
+		binary.Write(b, binary.BigEndian, v)
+	case uint64:
+		control(b, 9, 8)
+		binary.Write(b, binary.BigEndian, v)
+	case []interface{}:
+		control(b, 11, len(v))
+		for _, e := range v {
+			encode(b, e)
+		}
+	case map[string]interface{}:
+		control(b, 7, len(v))
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			encode(b, k)
+			encode(b, v[k])
+		}
+	default:
+		log.Fatalf("cannot encode %T", v)
+	}
+}
+
+// control appends the control byte of a field of the given type and size,
+// which is always under 285 here, followed by the type and size bytes
+// extending it, if needed.
+func control(b *bytes.Buffer, typ int, size int) {
+	if size >= 285 {
+		log.Fatalf("size %d too large", size)
+	}
+	first := size
+	if size >= 29 {
+		first = 29
+	}
+	if typ <= 7 {
+		b.WriteByte(byte(typ<<5 | first))
+	} else {
+		b.WriteByte(byte(first))
+		b.WriteByte(byte(typ - 7))
+	}
+	if size >= 29 {
+		b.WriteByte(byte(size - 29))
+	}
+}
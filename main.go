@@ -1,48 +1,462 @@
 package main
 
 import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"net"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
+	"text/template"
 	"time"
 
-	"github.com/caiofilipini/pingo/math"
+	"github.com/caiofilipini/pingo/asn"
+	"github.com/caiofilipini/pingo/geoip"
 	"github.com/caiofilipini/pingo/pinger"
+	"github.com/caiofilipini/pingo/twamp"
 )
 
 func main() {
 	bin := os.Args[0]
 	count := flag.Uint("c", 0, fmt.Sprintf("number of packets to be sent and received; if not specified, %s will send requests until interrupted", bin))
 	packetSize := flag.Uint("s", pinger.DefaultPacketSize, "number of data bytes to be sent in each request")
-	timeout := flag.Uint("t", uint(pinger.DefaultTimeout.Seconds()), "timeout in seconds for each request")
+	timeout := flag.Duration("t", pinger.DefaultTimeout, "timeout for each request, e.g. 500ms")
+	packetPair := flag.Bool("packet-pair", false, "experimental: send each request as a pair of back-to-back echo requests, estimating the bottleneck bandwidth out of the spacing of their replies; more accurate with a large -s, e.g. 1472")
+	loadURL := flag.String("load-url", "", fmt.Sprintf("URL of a large file to download over %d parallel connections for saturating the link during the loaded phase of '%s bufferbloat'", loadStreams, bin))
+	loadCmd := flag.String("load-cmd", "", fmt.Sprintf("shell command saturating the link during the loaded phase of '%s bufferbloat', e.g. an iperf3 client; it is killed once the phase is over", bin))
+	warmup := flag.Uint("warmup", 0, "number of initial requests whose results are printed, but excluded from the statistics, in addition to -c")
+	linger := flag.Duration("W", 0, "time to wait for the reply to the final request when -c is specified, if longer than -t, e.g. 5s")
+	ttl := flag.Uint("m", 0, "IP time to live of outgoing packets; if not specified, the system default is used")
+	flows := flag.Uint("f", 0, "number of flows to cycle requests across, keeping the ICMP checksum constant within each flow; if not specified, requests are sent without flow control")
+	flowID := flag.Uint("F", 0, "identifier (ICMP checksum) of the first flow when -f is specified")
+	dscpSweep := flag.String("dscp-sweep", "", "comma-separated DSCP values (0-63) to ping the host with simultaneously, comparing the loss and latencies of each traffic class, e.g. 0,10,46")
+	dscp := flag.Uint("Q", 0, "DSCP value (0-63) of outgoing packets; if specified, the value of each reply is reported on Linux")
+	ecn := flag.Uint("E", 0, "ECN codepoint of outgoing packets (1 for ECT(1), 2 for ECT(0)); if specified, the codepoint of each reply is reported on Linux")
+	geoipDB := flag.String("geoip-db", "", "comma-separated paths of MaxMind databases (City, Country or ASN) used for annotating the host and hops with their location")
+	rotate := flag.Bool("rotate", false, "send requests to each of the addresses the host resolves to in turn, as clients of DNS round-robin do")
+	doh := flag.String("doh", "", "URL of a DNS over HTTPS resolver to resolve hosts with, e.g. https://1.1.1.1/dns-query")
+	dot := flag.String("dot", "", "address of a DNS over TLS resolver to resolve hosts with, e.g. 1.1.1.1 or dns.example.com:853")
+	histDigits := flag.Uint("hdr-digits", pinger.DefaultHistogramDigits, "number of significant value digits (1-5) of the RTT histogram")
+	maxSamples := flag.Uint("max-samples", pinger.DefaultMaxSamples, "number of most recent RTTs and lost sequence numbers kept for the summary, bounding memory use on endless runs; other stats cover the whole run regardless")
+	discardSamples := flag.Bool("discard-samples", false, "keep no RTTs or lost sequence numbers at all, only counters and streaming aggregates, minimizing memory use on long runs; implies no -missing lists, no -buckets, only the most recent -flap-threshold outages, and no periodicity or rate limiting detection")
+	histLog := flag.String("hdr-log", "", "path of a file to export the RTT histogram to, in the HdrHistogram log format")
+	percentiles := flag.String("percentiles", "", "comma-separated percentiles of the round-trip latencies to be included in the summary, e.g. 50,90,99,99.9")
+	summaryTemplate := flag.String("summary-template", "", "Go text/template used for printing the summary instead of the default one, e.g. '{{.Host}} {{.PacketLoss}} {{.Avg}}'; available fields are Version, Host, Addr, Location (with Country, City, ASN and ASOrg, if -geoip-db is specified), StartTime, Duration, Transmitted, Received, PacketLoss, Timeouts, Errors, Duplicates, Corrupted, Warmup, LossBursts, MaxBurst, MeanBurst, Burstiness, RateLimited, Outages (the most recent ones, each with Start, End, Duration and Lost), OutageCount, LongestOutage (with the same fields, if any ended), Buckets (each with Start, Width, Transmitted, Received, PacketLoss, Min, Avg and Max), Min, Avg, Max, StdDev, SRTT, RTTVar, Trend (in ms/min), Period (in replies, if periodic), Anomalies, Warn and Crit (replies over -warn and -crit), Responders (replies per address), ChecksumErrors, Missing and Late (sequence numbers), Bandwidth (in bits per second, if -packet-pair is specified), OneWay (with Forward, Reverse and Asymmetry, if -one-way or -twamp is specified) and Percentiles (e.g. '{{index .Percentiles \"p99\"}}')")
+	bucketWidth := flag.Duration("bucket-width", pinger.DefaultBucketWidth, "width of the time buckets requests are summarized into, e.g. 1m or 1h")
+	coarseBucketWidth := flag.Duration("coarse-bucket-width", pinger.DefaultCoarseBucketWidth, "width of the time buckets older buckets are downsampled into on long runs, once over -max-samples")
+	listBuckets := flag.Bool("buckets", false, "list the loss and round-trip latencies of each time bucket in the summary")
+	listMissing := flag.Bool("missing", false, "list the sequence numbers of requests never replied to, and of those replied to late, in the summary")
+	countReplies := flag.Bool("count-replies", false, "stop after -c replies are received, rather than after -c requests are sent")
+	successes := flag.Uint("successes", 0, "stop once this many consecutive replies are received, exiting with status 0, or with status 1 if stopped before, e.g. for waiting until a host is reliably reachable; -c then bounds the wait")
+	successRTT := flag.Duration("successes-rtt", 0, "round-trip latency replies must be under to count towards -successes, e.g. 100ms")
+	showProgress := flag.Bool("progress", false, "render a progress bar with an ETA on stderr when -c is specified")
+	statsInterval := flag.Duration("stats-interval", 0, "interval between one-line summaries of the packets sent since the previous one, e.g. 60s; if not specified, only the final summary is printed")
+	summaryJSON := flag.Bool("summary-json", false, "print only the summary, as a single JSON object")
+	output := flag.String("o", "text", "output format: text, tsv for tab-separated seq, status, responder, size, RTT (ms) and responder location (if -geoip-db is specified) columns without any other output, smokeping for RRDtool update values laid out as Smokeping's, for each interval and the whole run, or checkmk for a CheckMK local check line checked against -checkmk-warn and -checkmk-crit, for the whole run")
+	unit := flag.String("unit", "auto", "unit of round-trip latencies in text output: auto for µs under a millisecond, s from a second on and ms otherwise, or a fixed one of us, ms and s for parsing")
+	checkmkWarn := flag.String("checkmk-warn", "200ms,80%", "round-trip average and packet loss at which the check printed with -o checkmk is WARN")
+	checkmkCrit := flag.String("checkmk-crit", "500ms,100%", "round-trip average and packet loss at which the check printed with -o checkmk is CRIT")
+	zabbixAddr := flag.String("zabbix", "", "address of a Zabbix server or proxy to push the loss and round-trip latencies of the host to as trapper items (pingo.loss[host], pingo.rtt.min[host], pingo.rtt.avg[host] and pingo.rtt.max[host], in percent and seconds), e.g. zabbix.example.com or 10.0.0.1:10051")
+	zabbixHost := flag.String("zabbix-host", "", "name of the host the items pushed with -zabbix belong to in Zabbix; if not specified, the local host name is used")
+	zabbixInterval := flag.Duration("zabbix-interval", time.Minute, "interval between pushes of the items covering the requests sent since the previous push with -zabbix")
+	outageThreshold := flag.Uint("outage-threshold", pinger.DefaultOutageThreshold, "number of consecutive lost requests after which the host is considered unreachable, starting an outage")
+	flapThreshold := flag.Uint("flap-threshold", pinger.DefaultFlapThreshold, "number of outages within -flap-window after which the host is considered flapping, rather than down")
+	flapWindow := flag.Duration("flap-window", pinger.DefaultFlapWindow, "window within which outages are counted for detecting flapping")
+	anomalyWindow := flag.Uint("anomaly-window", pinger.DefaultAnomalyWindow, "number of most recent round-trips the baseline for detecting latency anomalies is computed from")
+	warnRTT := flag.Duration("warn", 0, "round-trip latency at or above which replies are flagged as warn, in yellow on a terminal, and counted in the summary, e.g. 50ms")
+	critRTT := flag.Duration("crit", 0, "round-trip latency at or above which replies are flagged as crit, in red on a terminal, and counted in the summary, e.g. 150ms")
+	anomalyThreshold := flag.Float64("anomaly-threshold", pinger.DefaultAnomalyThreshold, "number of standard deviations from the baseline above which a round-trip is marked as anomalous")
+	source := flag.String("S", "", "comma-separated source addresses or interface names of outgoing packets; if several are specified, the host is pinged from each of them simultaneously and their results compared")
+	iface := flag.String("interface", "", "name of the network interface outgoing packets are sent through, regardless of the routing policy")
+	ipOptions := flag.String("ip-options", "", "hex-encoded IPv4 header options of outgoing packets, e.g. 07070400000000 for recording the route of a single hop")
+	var debug bool
+	flag.BoolVar(&debug, "v", false, "log hex dumps of the ICMP messages sent and received on stderr, along with why received messages were rejected")
+	flag.BoolVar(&debug, "debug", false, "same as -v")
+	broadcast := flag.Bool("b", false, "allow pinging a broadcast address, listing every host that replies")
+	unprivileged := flag.Bool("unprivileged", false, "send requests through unprivileged datagram ICMP sockets right away, rather than only when raw ones are not permitted")
+	tcpPort := flag.Uint("tcp-fallback", 0, "port to time TCP handshakes with when neither raw nor datagram ICMP sockets are permitted; if not specified, there is no TCP fallback")
+	oneWay := flag.Bool("one-way", false, "send ICMP Timestamp requests instead of echo requests, estimating the forward and reverse one-way delays and their asymmetry; requires raw sockets and synchronized clocks, with a resolution of a millisecond")
+	twampPort := flag.Uint("twamp", 0, fmt.Sprintf("UDP port of a TWAMP Light reflector on the host (usually %d) to send test packets to instead of ICMP echo requests, also estimating one-way delays; see '%s reflect' for running a reflector", twamp.DefaultPort, bin))
+	benchRate := flag.Float64("bench-rate", 1000, fmt.Sprintf("rate at which '%s bench' sends requests across its hosts, in requests per second, e.g. 5000", bin))
+	benchDuration := flag.Duration("bench-duration", 10*time.Second, fmt.Sprintf("time '%s bench' sends requests for", bin))
+	respondDelay := flag.Duration("respond-delay", 0, fmt.Sprintf("delay '%s respond' adds to the round-trip of every TWAMP test packet, e.g. 20ms", bin))
+	respondLoss := flag.Float64("respond-loss", 0, fmt.Sprintf("percentage of TWAMP test packets '%s respond' drops rather than reflects, e.g. 5", bin))
+	discoveryInterval := flag.Duration("discovery-interval", time.Minute, fmt.Sprintf("interval between lookups of the targets '%s srv', '%s consul' and '%s discover -ping-found' ping", bin, bin, bin))
+	mdnsWait := flag.Duration("mdns-wait", 2*time.Second, fmt.Sprintf("time '%s discover' waits for mDNS responses for", bin))
+	pingFound := flag.Bool("ping-found", false, fmt.Sprintf("ping every device found by '%s discover', browsing again every -discovery-interval, rather than just listing them", bin))
+	consulAddr := flag.String("consul-addr", consulDefaultAddr(), fmt.Sprintf("address of the Consul HTTP API '%s consul' looks services up with; the ACL token in CONSUL_HTTP_TOKEN, if any, is used", bin))
+	consulTags := flag.String("consul-tags", "", fmt.Sprintf("comma-separated tags the service instances pinged by '%s consul' must all have", bin))
+	consulPassing := flag.Bool("consul-passing", true, fmt.Sprintf("ping only the service instances passing their health checks with '%s consul'", bin))
+	wakeMAC := flag.String("wake", "", "MAC address of the host to wake up with a Wake-on-LAN magic packet before pinging it; combine with -count-replies -c 1 for waiting until it responds")
+	wakeAddr := flag.String("wake-addr", defaultWakeAddr, "address the Wake-on-LAN magic packet is sent to when -wake is specified, e.g. the broadcast address of the host's subnet")
+	version := flag.Bool("version", false, "print the version of pingo and exit")
+	lookupASN := flag.Bool("asn", false, "annotate the host and hops with their origin AS, looked up via Team Cymru's DNS service; hops are looked up in the background and annotated once known")
 	flag.Parse()
 
-	if len(flag.Args()) < 1 {
-		fmt.Fprintf(os.Stderr, "Usage: %s host\n", bin)
-		flag.PrintDefaults()
+	if *version {
+		info := pinger.ReadBuildInfo()
+		fmt.Printf("pingo %s (%s)\n", info, info.GoVersion)
+		return
+	}
+
+	comparing := flag.Arg(0) == "compare"
+	reflecting := flag.Arg(0) == "reflect"
+	responding := flag.Arg(0) == "respond"
+	bloating := flag.Arg(0) == "bufferbloat"
+	discovering := flag.Arg(0) == "srv" || flag.Arg(0) == "consul"
+	browsing := flag.Arg(0) == "discover"
+	checking := flag.Arg(0) == "nagios"
+	benchmarking := flag.Arg(0) == "bench"
+	switch flag.Arg(0) {
+	case "":
+		usageExit("")
+	case "compare":
+		if flag.NArg() != 3 {
+			usageExit("compare takes exactly two hosts")
+		}
+	case "reflect":
+		if flag.NArg() > 2 {
+			usageExit("reflect takes at most one address")
+		}
+	case "respond":
+		switch {
+		case flag.NArg() > 2:
+			usageExit("respond takes at most one address")
+		case *respondDelay < 0:
+			usageExit("-respond-delay cannot be negative")
+		case *respondLoss < 0 || *respondLoss > 100:
+			usageExit("-respond-loss must be between 0 and 100")
+		}
+	case "bufferbloat":
+		switch {
+		case flag.NArg() != 2:
+			usageExit("bufferbloat takes exactly one host")
+		case (*loadURL == "") == (*loadCmd == ""):
+			usageExit("bufferbloat takes exactly one of -load-url and -load-cmd")
+		}
+	case "srv", "consul":
+		switch {
+		case flag.NArg() != 2:
+			usageExit(fmt.Sprintf("%s takes exactly one service", flag.Arg(0)))
+		case *discoveryInterval <= 0:
+			usageExit("-discovery-interval must be positive")
+		}
+	case "discover":
+		switch {
+		case flag.NArg() > 2:
+			usageExit("discover takes at most one service")
+		case *discoveryInterval <= 0:
+			usageExit("-discovery-interval must be positive")
+		}
+	case "nagios":
+		if flag.NArg() < 2 {
+			usageExit("nagios takes a host")
+		}
+	case "bench":
+		switch {
+		case flag.NArg() < 2:
+			usageExit("bench takes at least one host")
+		case *benchRate <= 0:
+			usageExit("-bench-rate must be positive")
+		case *benchDuration <= 0:
+			usageExit("-bench-duration must be positive")
+		}
+	}
+
+	switch {
+	case *zabbixAddr != "" && *zabbixInterval <= 0:
+		usageExit("-zabbix-interval must be positive")
+	case *warnRTT > 0 && *critRTT > 0 && *warnRTT > *critRTT:
+		usageExit("-warn cannot exceed -crit")
+	case *dscp > 63:
+		usageExit("-Q must be between 0 and 63")
+	case *ecn != 0 && !pinger.ECN(*ecn).Capable():
+		usageExit("-E must be 1 for ECT(1) or 2 for ECT(0)")
+	case *output != "text" && *output != "tsv" && *output != "smokeping" && *output != "checkmk":
+		usageExit(fmt.Sprintf("unknown output format %q", *output))
+	case *unit != "auto" && rttScales[*unit] == rttScale{}:
+		usageExit(fmt.Sprintf("unknown unit %q", *unit))
+	case *successRTT > 0 && *successes == 0:
+		usageExit("-successes-rtt requires -successes")
+	}
+
+	if reflecting {
+		listenAddr := fmt.Sprintf(":%d", twamp.DefaultPort)
+		if flag.NArg() == 2 {
+			listenAddr = flag.Arg(1)
+		}
+		runReflector(listenAddr)
+		return
+	}
+
+	if responding {
+		listenAddr := defaultResponderAddr
+		if flag.NArg() == 2 {
+			listenAddr = flag.Arg(1)
+		}
+		runResponder(listenAddr, *respondDelay, *respondLoss/100)
+		return
+	}
+
+	opts := pinger.Options{
+		Count:             *count,
+		CountReplies:      *countReplies,
+		Successes:         *successes,
+		SuccessRTT:        *successRTT,
+		PacketSize:        *packetSize,
+		Timeout:           *timeout,
+		Linger:            *linger,
+		Warmup:            *warmup,
+		PacketPair:        *packetPair,
+		TTL:               *ttl,
+		Flows:             *flows,
+		FlowID:            uint16(*flowID),
+		ECN:               pinger.ECN(*ecn),
+		DSCP:              *dscp,
+		HistogramDigits:   *histDigits,
+		MaxSamples:        *maxSamples,
+		DiscardSamples:    *discardSamples,
+		BucketWidth:       *bucketWidth,
+		CoarseBucketWidth: *coarseBucketWidth,
+		OutageThreshold:   *outageThreshold,
+		FlapThreshold:     *flapThreshold,
+		FlapWindow:        *flapWindow,
+		AnomalyWindow:     *anomalyWindow,
+		AnomalyThreshold:  *anomalyThreshold,
+		WarnRTT:           *warnRTT,
+		CritRTT:           *critRTT,
+		Interface:         *iface,
+		TCPPort:           *tcpPort,
+		Unprivileged:      *unprivileged,
+		Broadcast:         *broadcast,
+		OneWay:            *oneWay,
+		TWAMPPort:         *twampPort,
+	}
+	if debug {
+		opts.Debug = os.Stderr
+		fmt.Fprintf(os.Stderr, "pingo %s\n", pinger.Version())
+	}
+	if *ipOptions != "" {
+		raw, err := hex.DecodeString(*ipOptions)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid IP options %q: %v\n", *ipOptions, err)
+			os.Exit(2)
+		}
+		opts.RawIPOptions = raw
+	}
+
+	var sources []net.IP
+	if *source != "" {
+		for _, s := range strings.Split(*source, ",") {
+			ip, err := sourceAddr(strings.TrimSpace(s))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "invalid source %q: %v\n", s, err)
+				os.Exit(2)
+			}
+			sources = append(sources, ip)
+		}
+		opts.Source = sources[0]
+	}
+
+	switch {
+	case *doh != "" && *dot != "":
+		fmt.Fprintln(os.Stderr, "only one of -doh and -dot can be specified")
 		os.Exit(2)
+	case *doh != "":
+		opts.Resolver = pinger.NewDoHResolver(*doh)
+	case *dot != "":
+		opts.Resolver = pinger.NewDoTResolver(*dot)
+	}
+
+	if comparing {
+		compareHosts(flag.Arg(1), flag.Arg(2), *unit, opts)
+		return
+	}
+
+	if browsing {
+		service := flag.Arg(1)
+		if *pingFound {
+			source := "mDNS devices"
+			if service != "" {
+				source = fmt.Sprintf("mDNS devices advertising %s", service)
+			}
+			watchTargets(source, mdnsDiscoverer(service, *mdnsWait), *discoveryInterval, *unit, opts)
+			return
+		}
+		devices, err := browseMDNS(service, *mdnsWait)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(2)
+		}
+		printDevices(devices)
+		return
+	}
+
+	if discovering {
+		name := flag.Arg(1)
+		if flag.Arg(0) == "consul" {
+			var tags []string
+			if *consulTags != "" {
+				tags = strings.Split(*consulTags, ",")
+			}
+			source := fmt.Sprintf("Consul service %s", name)
+			watchTargets(source, consulDiscoverer(*consulAddr, name, tags, *consulPassing), *discoveryInterval, *unit, opts)
+			return
+		}
+		source := fmt.Sprintf("SRV %s", name)
+		watchTargets(source, func() ([]string, error) { return discoverSRV(name) }, *discoveryInterval, *unit, opts)
+		return
+	}
+
+	if checking {
+		runNagios(flag.Args()[1:], *count, opts)
+		return
+	}
+
+	if benchmarking {
+		runBench(flag.Args()[1:], *benchRate, *benchDuration, *unit, opts)
+		return
+	}
+
+	if bloating {
+		host := flag.Arg(1)
+		addr, err := opts.Resolve(context.Background(), host)
+		if err != nil {
+			fmt.Printf("failed to resolve host %s: %v\n", host, err)
+			os.Exit(2)
+		}
+
+		load := commandLoad(*loadCmd)
+		if *loadURL != "" {
+			load = downloadLoad(*loadURL)
+		}
+		measureBufferbloat(host, addr, *unit, opts, load)
+		return
+	}
+
+	ps, err := parsePercentiles(*percentiles)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid percentiles %q: %v\n", *percentiles, err)
+		os.Exit(2)
+	}
+
+	var tmpl *template.Template
+	if *summaryTemplate != "" {
+		tmpl, err = template.New("summary").Parse(*summaryTemplate)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid summary template: %v\n", err)
+			os.Exit(2)
+		}
 	}
 
 	host := flag.Arg(0)
-	addr, err := pinger.Resolve(host)
+	addrs, err := opts.ResolveAll(context.Background(), host)
 	if err != nil {
-		fmt.Printf("failed to resolve host %s: %v\n", host, err)
+		fmt.Fprintf(os.Stderr, "failed to resolve host %s: %v\n", host, err)
 		os.Exit(2)
 	}
+	addr := addrs[0]
+	if *rotate {
+		opts.Rotate = addrs[1:]
+		if !*summaryJSON && *output == "text" {
+			fmt.Printf("ROTATE %s: %s\n", host, joinAddrs(addrs))
+		}
+	}
+
+	if *wakeMAC != "" {
+		mac, err := net.ParseMAC(*wakeMAC)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid MAC address %q: %v\n", *wakeMAC, err)
+			os.Exit(2)
+		}
+		if err := wake(mac, *wakeAddr); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		if !*summaryJSON && *output == "text" {
+			fmt.Printf("WAKE %s: sent magic packet to %s\n", mac, *wakeAddr)
+		}
+	}
+
+	if len(sources) > 1 {
+		compareSources(host, addr, sources, *unit, opts)
+		return
+	}
 
-	pinger := pinger.NewPinger(&pinger.Options{
-		Count:      *count,
-		PacketSize: *packetSize,
-		Timeout:    time.Duration(*timeout) * time.Second,
-	})
+	if *dscpSweep != "" {
+		values, err := parseDSCPs(*dscpSweep)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid DSCP values %q: %v\n", *dscpSweep, err)
+			os.Exit(2)
+		}
+		compareDSCP(host, addr, values, *unit, opts)
+		return
+	}
+
+	var db *geoip.DB
+	if *geoipDB != "" {
+		db, err = geoip.Open(strings.Split(*geoipDB, ",")...)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		defer db.Close()
+	}
+
+	var asns *asn.Client
+	if *lookupASN {
+		asns = asn.NewClient()
+	}
+
+	var out printer = &textPrinter{
+		host:    host,
+		addr:    addr,
+		flows:   *flows,
+		ecn:     *ecn,
+		dscp:    *dscp,
+		oneWay:  *oneWay || *twampPort != 0,
+		missing: *listMissing,
+		rotate:  *rotate,
+		buckets: *listBuckets,
+		unit:    *unit,
+		color:   isTerminal(os.Stdout) && os.Getenv("NO_COLOR") == "",
+		db:      db,
+		asns:    asns,
+	}
+	switch *output {
+	case "tsv":
+		out = &tsvPrinter{addr: addr, db: db}
+	case "smokeping":
+		out = &smokepingPrinter{}
+	case "checkmk":
+		warning, err := parseCheckThreshold(*checkmkWarn)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid -checkmk-warn threshold: %v\n", err)
+			os.Exit(2)
+		}
+		critical, err := parseCheckThreshold(*checkmkCrit)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid -checkmk-crit threshold: %v\n", err)
+			os.Exit(2)
+		}
+		out = &checkmkPrinter{host: host, warning: warning, critical: critical}
+	}
+
+	pinger := pinger.NewPinger(&opts)
 
 	done := make(chan struct{})
 	results, errors := pinger.Report()
 	stop := false
+	interrupted := false
 
-	fmt.Printf("PING %s: %d data bytes\n", addr, *packetSize)
+	if !*summaryJSON {
+		out.header(*packetSize)
+	}
 
 	go func(done chan struct{}) {
 		pinger.Ping(addr)
@@ -52,48 +466,251 @@ func main() {
 	sig := make(chan os.Signal, 1)
 	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
 
+	var tick <-chan time.Time
+	if *statsInterval > 0 && !*summaryJSON {
+		ticker := time.NewTicker(*statsInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	prev := pinger.Stats()
+
+	var zabbix *zabbixSender
+	var zabbixTick <-chan time.Time
+	zabbixPrev := prev
+	if *zabbixAddr != "" {
+		name := *zabbixHost
+		if name == "" {
+			if name, err = os.Hostname(); err != nil {
+				fmt.Fprintf(os.Stderr, "failed to get the host name for Zabbix: %v\n", err)
+				os.Exit(2)
+			}
+		}
+		zabbix = newZabbixSender(*zabbixAddr, name)
+		ticker := time.NewTicker(*zabbixInterval)
+		defer ticker.Stop()
+		zabbixTick = ticker.C
+	}
+
+	var bar *progress
+	reported := 0
+	if *showProgress && *count > 0 {
+		total := *count
+		if !*countReplies {
+			total += *warmup
+		}
+		bar = newProgress(os.Stderr, int(total))
+		bar.update(reported)
+	}
+
 	for !stop {
 		select {
 		case <-done:
+			// Ping reports unrecoverable errors right before returning,
+			// so make sure they are not missed.
+			if err, ok := <-errors; ok {
+				failPing(host, err)
+			}
 			stop = true
 		case <-sig:
+			// A second interrupt terminates right away, even if the
+			// request in flight hangs.
+			if interrupted {
+				pinger.StopWait(0)
+				stop = true
+				continue
+			}
+			interrupted = true
 			pinger.Stop()
+		case <-tick:
+			stats := pinger.Stats()
+			bar.clear()
+			out.interval(*statsInterval, stats.Since(prev))
+			bar.update(reported)
+			prev = stats
+		case <-zabbixTick:
+			stats := pinger.Stats()
+			items := zabbix.items(host, stats.Since(zabbixPrev), time.Now())
+			zabbixPrev = stats
+			// Pushes must not hold up the results, even if Zabbix is
+			// unresponsive.
+			go func() {
+				if err := zabbix.send(items); err != nil {
+					fmt.Fprintln(os.Stderr, err)
+				}
+			}()
 		case res, ok := <-results:
 			if !ok {
 				continue
 			}
 
-			if res.Timeout {
-				fmt.Printf("Request timeout for icmp_seq %d\n", res.Seq)
-			} else {
-				fmt.Printf("%d bytes from %v: icmp_seq=%d time=%.3f ms\n",
-					res.Size,
-					addr,
-					res.Seq,
-					math.TimeInMillis(res.RTT),
-				)
+			reported++
+			if *countReplies {
+				stats := pinger.Stats()
+				reported = stats.Received()
 			}
+			if *summaryJSON {
+				bar.update(reported)
+				continue
+			}
+			bar.clear()
+
+			out.result(res)
+			bar.update(reported)
 		case err, ok := <-errors:
 			if ok {
-				fmt.Printf("failed to ping %s: %v\n", host, err)
-				os.Exit(2)
+				failPing(host, err)
 			}
 		}
 	}
 
-	printStats(host, pinger.Stats())
+	bar.clear()
+
+	stats := pinger.Stats()
+	if zabbix != nil {
+		if err := zabbix.send(zabbix.items(host, stats.Since(zabbixPrev), time.Now())); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+	if *summaryJSON {
+		if err := json.NewEncoder(os.Stdout).Encode(newSummary(host, addr.String(), lookupLocation(db, addr), stats, ps)); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to print summary: %v\n", err)
+			os.Exit(2)
+		}
+	} else if tmpl != nil {
+		if err := printSummary(tmpl, newSummary(host, addr.String(), lookupLocation(db, addr), stats, ps)); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to print summary: %v\n", err)
+			os.Exit(2)
+		}
+	} else {
+		out.stats(stats, ps)
+	}
+
+	if *histLog != "" {
+		if err := writeHistogramLog(*histLog, host, stats); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to export histogram to %s: %v\n", *histLog, err)
+			os.Exit(2)
+		}
+	}
+
+	if *successes > 0 && stats.SuccessRun() < int(*successes) {
+		os.Exit(1)
+	}
+}
+
+// usageExit prints problem, if any, followed by the usage, and exits.
+func usageExit(problem string) {
+	if problem != "" {
+		fmt.Fprintln(os.Stderr, problem)
+	}
+	bin := os.Args[0]
+	fmt.Fprintf(os.Stderr, "Usage: %s host\n       %s compare hostA hostB\n       %s reflect [address]\n       %s respond [address]\n       %s bufferbloat -load-url url|-load-cmd command host\n       %s srv _service._proto.domain\n       %s consul service\n       %s discover [service]\n       %s nagios host -w rta,loss%% -c rta,loss%%\n       %s bench host [host...]\n", bin, bin, bin, bin, bin, bin, bin, bin, bin, bin)
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+// failPing reports err, which stopped host from being pinged, on stderr,
+// so that it does not mix with machine-readable output, and exits.
+func failPing(host string, err error) {
+	fmt.Fprintf(os.Stderr, "failed to ping %s: %v\n", host, err)
+	os.Exit(2)
+}
+
+// joinAddrs joins addrs into a comma-separated list.
+func joinAddrs(addrs []net.Addr) string {
+	s := make([]string, len(addrs))
+	for i, addr := range addrs {
+		s[i] = addr.String()
+	}
+	return strings.Join(s, ", ")
+}
+
+// sourceAddr parses s as an IPv4 address, or otherwise as the name of an
+// interface, in which case its first IPv4 address is returned.
+func sourceAddr(s string) (net.IP, error) {
+	if ip := net.ParseIP(s); ip != nil {
+		if ip.To4() == nil {
+			return nil, fmt.Errorf("not an IPv4 address")
+		}
+		return ip, nil
+	}
+
+	iface, err := net.InterfaceByName(s)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, err
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() != nil {
+			return ipNet.IP, nil
+		}
+	}
+	return nil, fmt.Errorf("no IPv4 address on interface %s", s)
 }
 
-func printStats(host string, stats pinger.Stats) {
-	fmt.Println()
-	fmt.Printf("--- %s ping statistics ---\n", host)
-	fmt.Printf(
-		"%d packets transmitted, %d packets received, %.1f%% packet loss\n",
-		stats.Transmitted(),
-		stats.Received(),
-		stats.PacketLoss(),
-	)
-
-	min, avg, max, stddev := stats.RTTStats()
-	fmt.Printf("round-trip min/avg/max/stddev = %.3f/%.3f/%.3f/%.3f ms\n", min, avg, max, stddev)
+// writeHistogramLog exports the RTT histogram in stats to the file at path.
+func writeHistogramLog(path string, host string, stats pinger.Stats) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := stats.WriteHistogramLog(f, host); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// parsePercentiles parses a comma-separated list of percentiles (0-100).
+func parsePercentiles(s string) ([]float64, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	var ps []float64
+	for _, f := range strings.Split(s, ",") {
+		p, err := strconv.ParseFloat(strings.TrimSpace(f), 64)
+		if err != nil {
+			return nil, err
+		}
+		if p < 0 || p > 100 {
+			return nil, fmt.Errorf("percentile %v out of range", p)
+		}
+		ps = append(ps, p)
+	}
+	return ps, nil
+}
+
+// parseDSCPs parses a comma-separated list of DSCP values.
+func parseDSCPs(s string) ([]uint, error) {
+	var values []uint
+	for _, f := range strings.Split(s, ",") {
+		v, err := strconv.ParseUint(strings.TrimSpace(f), 10, 8)
+		if err != nil {
+			return nil, err
+		}
+		if v > 63 {
+			return nil, fmt.Errorf("DSCP value %d out of range", v)
+		}
+		values = append(values, uint(v))
+	}
+	return values, nil
+}
+
+// printSummary prints the summary using tmpl, ending it with a newline.
+func printSummary(tmpl *template.Template, s summary) error {
+	var b strings.Builder
+	if err := tmpl.Execute(&b, s); err != nil {
+		return err
+	}
+
+	out := b.String()
+	if !strings.HasSuffix(out, "\n") {
+		out += "\n"
+	}
+	fmt.Print(out)
+	return nil
 }
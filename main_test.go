@@ -0,0 +1,74 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePercentiles(t *testing.T) {
+	tests := []struct {
+		desc     string
+		s        string
+		expected []float64
+		wantErr  bool
+	}{
+		{
+			desc:     "none",
+			s:        "",
+			expected: nil,
+		},
+		{
+			desc:     "single percentile",
+			s:        "99",
+			expected: []float64{99},
+		},
+		{
+			desc:     "fractional percentiles, with spaces",
+			s:        "50, 99.9,99.99",
+			expected: []float64{50, 99.9, 99.99},
+		},
+		{
+			desc:     "bounds",
+			s:        "0,100",
+			expected: []float64{0, 100},
+		},
+		{
+			desc:    "over 100",
+			s:       "50,101",
+			wantErr: true,
+		},
+		{
+			desc:    "negative",
+			s:       "-1",
+			wantErr: true,
+		},
+		{
+			desc:    "not a number",
+			s:       "p99",
+			wantErr: true,
+		},
+		{
+			desc:    "empty item",
+			s:       "50,,99",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			ps, err := parsePercentiles(tc.s)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("wanted an error, got %v", ps)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("wanted no error, got %v", err)
+			}
+			if !reflect.DeepEqual(ps, tc.expected) {
+				t.Errorf("wanted %v, got %v", tc.expected, ps)
+			}
+		})
+	}
+}
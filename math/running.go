@@ -0,0 +1,124 @@
+package math
+
+import "math"
+
+// Moments accumulates the mean and standard deviation of a population one
+// value at a time, in constant memory, using Welford's algorithm. The zero
+// value is an empty population.
+type Moments struct {
+	count int
+	mean  float64
+	m2    float64
+}
+
+// Add adds v to the population.
+func (m *Moments) Add(v float64) {
+	m.count++
+	delta := v - m.mean
+	m.mean += delta / float64(m.count)
+	m.m2 += delta * (v - m.mean)
+}
+
+// Count returns the number of values added.
+func (m Moments) Count() int {
+	return m.count
+}
+
+// Mean returns the mean of the values added, or zero if none were.
+func (m Moments) Mean() float64 {
+	return m.mean
+}
+
+// Sum returns the sum of the values added.
+func (m Moments) Sum() float64 {
+	return m.mean * float64(m.count)
+}
+
+// StdDev returns the standard deviation of the values added, or zero if
+// none were.
+func (m Moments) StdDev() float64 {
+	if m.count == 0 {
+		return 0
+	}
+	return math.Sqrt(m.m2 / float64(m.count))
+}
+
+// Sub returns the moments of the values added to m after prev, a previous
+// copy of it.
+func (m Moments) Sub(prev Moments) Moments {
+	n := m.count - prev.count
+	if n <= 0 {
+		return Moments{}
+	}
+	if prev.count == 0 {
+		return m
+	}
+
+	// Reverses the merging of the moments of two populations (Chan et al.).
+	mean := (m.mean*float64(m.count) - prev.mean*float64(prev.count)) / float64(n)
+	delta := mean - prev.mean
+	m2 := m.m2 - prev.m2 - delta*delta*float64(prev.count)*float64(n)/float64(m.count)
+	return Moments{count: n, mean: mean, m2: math.Max(m2, 0)}
+}
+
+// Regression accumulates the least squares fit of a line to a series of
+// points one point at a time, in constant memory, yielding the same line as
+// LinearTrend. The zero value is an empty series.
+type Regression struct {
+	count int
+	meanX float64
+	meanY float64
+	m2X   float64
+	coXY  float64
+}
+
+// Add adds the point (x, y) to the series.
+func (r *Regression) Add(x float64, y float64) {
+	r.count++
+	dx := x - r.meanX
+	r.meanX += dx / float64(r.count)
+	r.meanY += (y - r.meanY) / float64(r.count)
+	r.m2X += dx * (x - r.meanX)
+	r.coXY += dx * (y - r.meanY)
+}
+
+// Count returns the number of points added.
+func (r Regression) Count() int {
+	return r.count
+}
+
+// Trend returns the slope and the intercept of the line fitted to the
+// points added, along with whether they are spread over x for fitting one.
+// As with LinearTrend, the slope is zero and the intercept is the mean of
+// y if they are not.
+func (r Regression) Trend() (float64, float64, bool) {
+	if r.count < 2 || r.m2X == 0 {
+		return 0, r.meanY, false
+	}
+	slope := r.coXY / r.m2X
+	return slope, r.meanY - slope*r.meanX, true
+}
+
+// Sub returns the regression of the points added to r after prev, a
+// previous copy of it.
+func (r Regression) Sub(prev Regression) Regression {
+	n := r.count - prev.count
+	if n <= 0 {
+		return Regression{}
+	}
+	if prev.count == 0 {
+		return r
+	}
+
+	meanX := (r.meanX*float64(r.count) - prev.meanX*float64(prev.count)) / float64(n)
+	meanY := (r.meanY*float64(r.count) - prev.meanY*float64(prev.count)) / float64(n)
+	dx, dy := meanX-prev.meanX, meanY-prev.meanY
+	weight := float64(prev.count) * float64(n) / float64(r.count)
+	return Regression{
+		count: n,
+		meanX: meanX,
+		meanY: meanY,
+		m2X:   math.Max(r.m2X-prev.m2X-dx*dx*weight, 0),
+		coXY:  r.coXY - prev.coXY - dx*dy*weight,
+	}
+}
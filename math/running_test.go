@@ -0,0 +1,149 @@
+package math
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMoments(t *testing.T) {
+	tests := []struct {
+		desc       string
+		population []float64
+	}{
+		{
+			desc:       "empty population",
+			population: []float64{},
+		},
+		{
+			desc:       "single value",
+			population: []float64{4.22},
+		},
+		{
+			desc:       "several values",
+			population: []float64{6.44, 3.11, 5.33, 4.22},
+		},
+		{
+			desc:       "negative values",
+			population: []float64{-2, -4, -4, -4, -5, -5, -7, -9},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			var m Moments
+			for _, v := range tc.population {
+				m.Add(v)
+			}
+
+			expected := Summarize(tc.population)
+			if m.Count() != expected.Count {
+				t.Errorf("wanted count %v, got %v", expected.Count, m.Count())
+			}
+			if math.Abs(m.Mean()-expected.Mean) > 1e-9 || math.Abs(m.Sum()-expected.Sum) > 1e-9 {
+				t.Errorf("wanted mean/sum %v/%v, got %v/%v", expected.Mean, expected.Sum, m.Mean(), m.Sum())
+			}
+			if math.Abs(m.StdDev()-expected.StdDev) > 1e-9 {
+				t.Errorf("wanted stddev %v, got %v", expected.StdDev, m.StdDev())
+			}
+		})
+	}
+}
+
+func TestMomentsSub(t *testing.T) {
+	population := []float64{6.44, 3.11, 5.33, 4.22, -2, 7.5, 1}
+
+	for split := 0; split <= len(population); split++ {
+		var m, prev Moments
+		for i, v := range population {
+			if i == split {
+				prev = m
+			}
+			m.Add(v)
+		}
+		if split == len(population) {
+			prev = m
+		}
+
+		since := m.Sub(prev)
+		expected := Summarize(population[split:])
+		if since.Count() != expected.Count || math.Abs(since.Mean()-expected.Mean) > 1e-9 || math.Abs(since.StdDev()-expected.StdDev) > 1e-9 {
+			t.Errorf("after %d values: wanted %v/%v/%v, got %v/%v/%v", split, expected.Count, expected.Mean, expected.StdDev, since.Count(), since.Mean(), since.StdDev())
+		}
+	}
+}
+
+func TestRegression(t *testing.T) {
+	tests := []struct {
+		desc      string
+		xs        []float64
+		ys        []float64
+		slope     float64
+		intercept float64
+		ok        bool
+	}{
+		{
+			desc: "returns zeroes for no points",
+		},
+		{
+			desc:      "returns a flat line for a single point",
+			xs:        []float64{3},
+			ys:        []float64{4.2},
+			intercept: 4.2,
+		},
+		{
+			desc:      "fits points on a line",
+			xs:        []float64{0, 1, 2, 3},
+			ys:        []float64{1, 3, 5, 7},
+			slope:     2,
+			intercept: 1,
+			ok:        true,
+		},
+		{
+			desc:      "fits a decreasing trend",
+			xs:        []float64{1, 2, 3, 4, 5},
+			ys:        []float64{10, 9, 7, 6, 3},
+			slope:     -1.7,
+			intercept: 12.1,
+			ok:        true,
+		},
+		{
+			desc:      "returns a flat line when xs do not vary",
+			xs:        []float64{2, 2, 2},
+			ys:        []float64{1, 2, 3},
+			intercept: 2,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			var r Regression
+			for i := range tc.xs {
+				r.Add(tc.xs[i], tc.ys[i])
+			}
+
+			slope, intercept, ok := r.Trend()
+			if ok != tc.ok || math.Abs(slope-tc.slope) > 1e-9 || math.Abs(intercept-tc.intercept) > 1e-9 {
+				t.Errorf("wanted %f/%f (%v), got %f/%f (%v)", tc.slope, tc.intercept, tc.ok, slope, intercept, ok)
+			}
+		})
+	}
+}
+
+func TestRegressionSub(t *testing.T) {
+	xs := []float64{1, 2, 3, 4, 5, 6, 7}
+	ys := []float64{4, 4, 4, 10, 9, 7, 6}
+
+	var r, prev Regression
+	for i := range xs {
+		if i == 3 {
+			prev = r
+		}
+		r.Add(xs[i], ys[i])
+	}
+
+	slope, intercept, ok := r.Sub(prev).Trend()
+	expectedSlope, expectedIntercept := LinearTrend(xs[3:], ys[3:])
+	if !ok || math.Abs(slope-expectedSlope) > 1e-9 || math.Abs(intercept-expectedIntercept) > 1e-9 {
+		t.Errorf("wanted %f/%f, got %f/%f (%v)", expectedSlope, expectedIntercept, slope, intercept, ok)
+	}
+}
@@ -1,6 +1,9 @@
 package math
 
-import "math"
+import (
+	"math"
+	"sort"
+)
 
 // Min returns the minimum value in the given population.
 func Min(population []float64) float64 {
@@ -27,6 +30,58 @@ func Mean(population []float64) float64 {
 	return sum / float64(len(population))
 }
 
+// GeoMean calculates the geometric mean of the given population, suited to
+// averaging ratios. It is zero if any value is zero and NaN if any value is
+// negative, since the geometric mean of such populations is undefined.
+func GeoMean(population []float64) float64 {
+	if len(population) == 0 {
+		return 0
+	}
+
+	var sumLog float64
+	zero := false
+	for _, v := range population {
+		switch {
+		case v < 0:
+			return math.NaN()
+		case v == 0:
+			zero = true
+		default:
+			sumLog += math.Log(v)
+		}
+	}
+	if zero {
+		return 0
+	}
+	return math.Exp(sumLog / float64(len(population)))
+}
+
+// HarmonicMean calculates the harmonic mean of the given population, suited
+// to averaging rates. It is zero if any value is zero, as the harmonic mean
+// tends to zero when any value does, and NaN if any value is negative.
+func HarmonicMean(population []float64) float64 {
+	if len(population) == 0 {
+		return 0
+	}
+
+	var sumInv float64
+	zero := false
+	for _, v := range population {
+		switch {
+		case v < 0:
+			return math.NaN()
+		case v == 0:
+			zero = true
+		default:
+			sumInv += 1 / v
+		}
+	}
+	if zero {
+		return 0
+	}
+	return float64(len(population)) / sumInv
+}
+
 // StdDev calculates the standard deviation for the given population.
 func StdDev(population []float64) float64 {
 	mean := Mean(population)
@@ -40,6 +95,178 @@ func StdDev(population []float64) float64 {
 	return math.Sqrt(sumDist / float64(len(population)))
 }
 
+// TrimmedMean calculates the mean of the given population after discarding
+// the given fraction (0-0.5) of its lowest and highest values each, making
+// it robust against a few outliers. Trimming half of the values on each
+// side yields the median.
+func TrimmedMean(population []float64, fraction float64) float64 {
+	if len(population) == 0 {
+		return 0
+	}
+
+	fraction = math.Max(0, math.Min(0.5, fraction))
+	trim := int(fraction * float64(len(population)))
+	if 2*trim >= len(population) {
+		return Percentile(population, 50)
+	}
+
+	sorted := make([]float64, len(population))
+	copy(sorted, population)
+	sort.Float64s(sorted)
+	return Mean(sorted[trim : len(sorted)-trim])
+}
+
+// MAD calculates the median absolute deviation of the given population, a
+// measure of dispersion robust against outliers. Multiplied by 1.4826, it
+// estimates the standard deviation of normally distributed populations.
+func MAD(population []float64) float64 {
+	median := Percentile(population, 50)
+	deviations := make([]float64, len(population))
+	for i, v := range population {
+		deviations[i] = math.Abs(v - median)
+	}
+	return Percentile(deviations, 50)
+}
+
+// Variance calculates the variance of the given population.
+func Variance(population []float64) float64 {
+	if len(population) == 0 {
+		return 0
+	}
+	return sumSquaredDist(population) / float64(len(population))
+}
+
+// SampleVariance calculates the unbiased variance of the population the
+// given sample was drawn from, using Bessel's correction. It is zero for
+// samples of less than two values.
+func SampleVariance(sample []float64) float64 {
+	if len(sample) < 2 {
+		return 0
+	}
+	return sumSquaredDist(sample) / float64(len(sample)-1)
+}
+
+// Mode returns the most frequent value in the given population, or the
+// lowest of them if several are equally frequent.
+func Mode(population []float64) float64 {
+	counts := make(map[float64]int, len(population))
+	mode, max := 0.0, 0
+	for _, v := range population {
+		counts[v]++
+		if n := counts[v]; n > max || (n == max && v < mode) {
+			mode, max = v, n
+		}
+	}
+	return mode
+}
+
+// Percentile calculates the p-th percentile (0-100) of the given population,
+// linearly interpolating between the closest ranks.
+func Percentile(population []float64, p float64) float64 {
+	if len(population) == 0 {
+		return 0
+	}
+
+	sorted := make([]float64, len(population))
+	copy(sorted, population)
+	sort.Float64s(sorted)
+
+	p = math.Max(0, math.Min(100, p))
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
+
+// Summary holds the descriptive statistics of a population.
+type Summary struct {
+	Count  int
+	Min    float64
+	Max    float64
+	Mean   float64
+	StdDev float64
+	Sum    float64
+}
+
+// Summarize calculates the descriptive statistics of the given population
+// in a single pass, using Welford's algorithm for the standard deviation.
+// They are all zero for an empty population.
+func Summarize(population []float64) Summary {
+	if len(population) == 0 {
+		return Summary{}
+	}
+
+	s := Summary{
+		Min: math.MaxFloat64,
+		Max: -math.MaxFloat64,
+	}
+	var m2 float64
+	for _, v := range population {
+		s.Count++
+		s.Sum += v
+		s.Min = math.Min(s.Min, v)
+		s.Max = math.Max(s.Max, v)
+
+		delta := v - s.Mean
+		s.Mean += delta / float64(s.Count)
+		m2 += delta * (v - s.Mean)
+	}
+	s.StdDev = math.Sqrt(m2 / float64(s.Count))
+	return s
+}
+
+// sumSquaredDist returns the sum of the squared distances of the values in
+// the given population from its mean.
+func sumSquaredDist(population []float64) float64 {
+	mean := Mean(population)
+	return reduce(population, 0, func(v float64, acc float64) float64 {
+		return acc + (v-mean)*(v-mean)
+	})
+}
+
+// LinearTrend fits a line to the points (xs[i], ys[i]) by least squares,
+// returning its slope and intercept. The slope is zero, and the intercept
+// the mean of ys, if xs does not vary or the lengths of xs and ys differ.
+func LinearTrend(xs []float64, ys []float64) (float64, float64) {
+	if len(xs) != len(ys) {
+		return 0, Mean(ys)
+	}
+
+	meanX, meanY := Mean(xs), Mean(ys)
+	var cov, varX float64
+	for i := range xs {
+		cov += (xs[i] - meanX) * (ys[i] - meanY)
+		varX += (xs[i] - meanX) * (xs[i] - meanX)
+	}
+	if varX == 0 {
+		return 0, meanY
+	}
+
+	slope := cov / varX
+	return slope, meanY - slope*meanX
+}
+
+// Autocorrelation calculates the correlation (-1 to 1) of the given
+// population, e.g. a time series, with itself shifted by lag values. It
+// is zero if the lag is out of range or the population does not vary.
+func Autocorrelation(population []float64, lag int) float64 {
+	if lag < 0 || lag >= len(population) {
+		return 0
+	}
+
+	mean := Mean(population)
+	variance := sumSquaredDist(population)
+	if variance == 0 {
+		return 0
+	}
+
+	var cov float64
+	for i := 0; i+lag < len(population); i++ {
+		cov += (population[i] - mean) * (population[i+lag] - mean)
+	}
+	return cov / variance
+}
+
 type reducer func(v float64, acc float64) float64
 
 func reduce(population []float64, acc float64, fn reducer) float64 {
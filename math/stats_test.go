@@ -1,6 +1,7 @@
 package math
 
 import (
+	"math"
 	"testing"
 )
 
@@ -128,6 +129,84 @@ func TestMean(t *testing.T) {
 	}
 }
 
+func TestGeoMean(t *testing.T) {
+	tests := []struct {
+		desc       string
+		population []float64
+		expected   float64
+	}{
+		{
+			desc:       "returns zero for an empty population",
+			population: []float64{},
+			expected:   0,
+		},
+		{
+			desc:       "returns the geometric mean of the population",
+			population: []float64{1, 2, 4, 8},
+			expected:   2.82,
+		},
+		{
+			desc:       "returns zero if any value is zero",
+			population: []float64{1, 0, 4},
+			expected:   0,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			mean := round(GeoMean(tc.population))
+			if mean != tc.expected {
+				t.Errorf("wanted %f, got %f", tc.expected, mean)
+			}
+		})
+	}
+
+	t.Run("returns NaN if any value is negative", func(t *testing.T) {
+		if mean := GeoMean([]float64{1, -2, 0}); !math.IsNaN(mean) {
+			t.Errorf("wanted NaN, got %f", mean)
+		}
+	})
+}
+
+func TestHarmonicMean(t *testing.T) {
+	tests := []struct {
+		desc       string
+		population []float64
+		expected   float64
+	}{
+		{
+			desc:       "returns zero for an empty population",
+			population: []float64{},
+			expected:   0,
+		},
+		{
+			desc:       "returns the harmonic mean of the population",
+			population: []float64{1, 2, 4},
+			expected:   1.71,
+		},
+		{
+			desc:       "returns zero if any value is zero",
+			population: []float64{40, 0, 60},
+			expected:   0,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			mean := round(HarmonicMean(tc.population))
+			if mean != tc.expected {
+				t.Errorf("wanted %f, got %f", tc.expected, mean)
+			}
+		})
+	}
+
+	t.Run("returns NaN if any value is negative", func(t *testing.T) {
+		if mean := HarmonicMean([]float64{40, -60, 0}); !math.IsNaN(mean) {
+			t.Errorf("wanted NaN, got %f", mean)
+		}
+	})
+}
+
 func TestStdDev(t *testing.T) {
 	tests := []struct {
 		desc       string
@@ -161,6 +240,401 @@ func TestStdDev(t *testing.T) {
 	}
 }
 
+func TestTrimmedMean(t *testing.T) {
+	tests := []struct {
+		desc       string
+		population []float64
+		fraction   float64
+		expected   float64
+	}{
+		{
+			desc:       "returns zero for an empty population",
+			population: []float64{},
+			fraction:   0.1,
+			expected:   0,
+		},
+		{
+			desc:       "returns the mean without trimming",
+			population: []float64{1, 2, 3, 4, 100},
+			fraction:   0,
+			expected:   22,
+		},
+		{
+			desc:       "discards the outliers",
+			population: []float64{100, 2, 3, 4, 1, 3, 2, 4, 3, -50},
+			fraction:   0.1,
+			expected:   2.75,
+		},
+		{
+			desc:       "rounds the number of values to trim down",
+			population: []float64{1, 2, 3, 4, 100},
+			fraction:   0.1,
+			expected:   22,
+		},
+		{
+			desc:       "returns the median when trimming everything",
+			population: []float64{1, 2, 3, 100},
+			fraction:   0.5,
+			expected:   2.5,
+		},
+		{
+			desc:       "clamps the fraction",
+			population: []float64{1, 2, 100},
+			fraction:   0.9,
+			expected:   2,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			mean := TrimmedMean(tc.population, tc.fraction)
+			if mean != tc.expected {
+				t.Errorf("wanted %f, got %f", tc.expected, mean)
+			}
+		})
+	}
+}
+
+func TestMAD(t *testing.T) {
+	tests := []struct {
+		desc       string
+		population []float64
+		expected   float64
+	}{
+		{
+			desc:       "returns zero for an empty population",
+			population: []float64{},
+			expected:   0,
+		},
+		{
+			desc:       "returns zero for a single value",
+			population: []float64{4.2},
+			expected:   0,
+		},
+		{
+			desc:       "returns the median absolute deviation",
+			population: []float64{1, 1, 2, 2, 4, 6, 9},
+			expected:   1,
+		},
+		{
+			desc:       "is not skewed by a single outlier",
+			population: []float64{10, 11, 9, 10, 12, 10, 1000},
+			expected:   1,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			mad := MAD(tc.population)
+			if mad != tc.expected {
+				t.Errorf("wanted %f, got %f", tc.expected, mad)
+			}
+		})
+	}
+}
+
+func TestVariance(t *testing.T) {
+	tests := []struct {
+		desc       string
+		population []float64
+		expected   float64
+		sample     float64
+	}{
+		{
+			desc:       "returns zero for an empty population",
+			population: []float64{},
+			expected:   0,
+			sample:     0,
+		},
+		{
+			desc:       "returns zero for a single value",
+			population: []float64{4.22},
+			expected:   0,
+			sample:     0,
+		},
+		{
+			desc:       "returns the variance of the population",
+			population: []float64{2, 4, 4, 4, 5, 5, 7, 9},
+			expected:   4,
+			sample:     4.57,
+		},
+		{
+			desc:       "returns the variance of negative values",
+			population: []float64{-1, 1},
+			expected:   1,
+			sample:     2,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			variance := round(Variance(tc.population))
+			if variance != tc.expected {
+				t.Errorf("wanted %f, got %f", tc.expected, variance)
+			}
+			sample := round(SampleVariance(tc.population))
+			if sample != tc.sample {
+				t.Errorf("wanted sample variance %f, got %f", tc.sample, sample)
+			}
+		})
+	}
+}
+
+func TestMode(t *testing.T) {
+	tests := []struct {
+		desc       string
+		population []float64
+		expected   float64
+	}{
+		{
+			desc:       "returns zero for an empty population",
+			population: []float64{},
+			expected:   0,
+		},
+		{
+			desc:       "returns the single value",
+			population: []float64{4.2},
+			expected:   4.2,
+		},
+		{
+			desc:       "returns the most frequent value",
+			population: []float64{3, 7, 1, 7, 3, 7},
+			expected:   7,
+		},
+		{
+			desc:       "returns the lowest of equally frequent values",
+			population: []float64{5, -2, 5, -2, 9},
+			expected:   -2,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			mode := Mode(tc.population)
+			if mode != tc.expected {
+				t.Errorf("wanted %f, got %f", tc.expected, mode)
+			}
+		})
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	tests := []struct {
+		desc       string
+		population []float64
+		p          float64
+		expected   float64
+	}{
+		{
+			desc:       "returns zero for an empty population",
+			population: []float64{},
+			p:          50,
+			expected:   0,
+		},
+		{
+			desc:       "returns the single value",
+			population: []float64{4.2},
+			p:          99,
+			expected:   4.2,
+		},
+		{
+			desc:       "returns the median of an odd population",
+			population: []float64{5, 1, 3},
+			p:          50,
+			expected:   3,
+		},
+		{
+			desc:       "interpolates the median of an even population",
+			population: []float64{4, 1, 3, 2},
+			p:          50,
+			expected:   2.5,
+		},
+		{
+			desc:       "interpolates between the closest ranks",
+			population: []float64{10, 20, 30, 40, 50},
+			p:          90,
+			expected:   46,
+		},
+		{
+			desc:       "returns the min for the 0th percentile",
+			population: []float64{10, 20, 30},
+			p:          0,
+			expected:   10,
+		},
+		{
+			desc:       "returns the max for the 100th percentile",
+			population: []float64{10, 20, 30},
+			p:          100,
+			expected:   30,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			percentile := Percentile(tc.population, tc.p)
+			if percentile != tc.expected {
+				t.Errorf("wanted %f, got %f", tc.expected, percentile)
+			}
+		})
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	tests := []struct {
+		desc       string
+		population []float64
+		expected   Summary
+	}{
+		{
+			desc:       "returns zeroes for an empty population",
+			population: []float64{},
+			expected:   Summary{},
+		},
+		{
+			desc:       "summarizes a single value",
+			population: []float64{4.22},
+			expected:   Summary{Count: 1, Min: 4.22, Max: 4.22, Mean: 4.22, StdDev: 0, Sum: 4.22},
+		},
+		{
+			desc:       "summarizes the population",
+			population: []float64{6.44, 3.11, 5.33, 4.22},
+			expected:   Summary{Count: 4, Min: 3.11, Max: 6.44, Mean: 4.77, StdDev: 1.24, Sum: 19.1},
+		},
+		{
+			desc:       "summarizes negative values",
+			population: []float64{-2, -4, -4, -4, -5, -5, -7, -9},
+			expected:   Summary{Count: 8, Min: -9, Max: -2, Mean: -5, StdDev: 2, Sum: -40},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			s := Summarize(tc.population)
+			s.Mean, s.StdDev, s.Sum = round(s.Mean), round(s.StdDev), round(s.Sum)
+			if s != tc.expected {
+				t.Errorf("wanted %+v, got %+v", tc.expected, s)
+			}
+		})
+	}
+}
+
+func TestLinearTrend(t *testing.T) {
+	tests := []struct {
+		desc      string
+		xs        []float64
+		ys        []float64
+		slope     float64
+		intercept float64
+	}{
+		{
+			desc:      "returns zeroes for no points",
+			xs:        []float64{},
+			ys:        []float64{},
+			slope:     0,
+			intercept: 0,
+		},
+		{
+			desc:      "returns a flat line for a single point",
+			xs:        []float64{3},
+			ys:        []float64{4.2},
+			slope:     0,
+			intercept: 4.2,
+		},
+		{
+			desc:      "fits points on a line",
+			xs:        []float64{0, 1, 2, 3},
+			ys:        []float64{1, 3, 5, 7},
+			slope:     2,
+			intercept: 1,
+		},
+		{
+			desc:      "fits a decreasing trend",
+			xs:        []float64{1, 2, 3, 4, 5},
+			ys:        []float64{10, 9, 7, 6, 3},
+			slope:     -1.7,
+			intercept: 12.1,
+		},
+		{
+			desc:      "returns a flat line when xs do not vary",
+			xs:        []float64{2, 2, 2},
+			ys:        []float64{1, 2, 3},
+			slope:     0,
+			intercept: 2,
+		},
+		{
+			desc:      "returns a flat line for mismatched lengths",
+			xs:        []float64{1, 2},
+			ys:        []float64{1, 2, 3},
+			slope:     0,
+			intercept: 2,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			slope, intercept := LinearTrend(tc.xs, tc.ys)
+			if math.Abs(slope-tc.slope) > 1e-9 || math.Abs(intercept-tc.intercept) > 1e-9 {
+				t.Errorf("wanted %f/%f, got %f/%f", tc.slope, tc.intercept, slope, intercept)
+			}
+		})
+	}
+}
+
+func TestAutocorrelation(t *testing.T) {
+	tests := []struct {
+		desc       string
+		population []float64
+		lag        int
+		expected   float64
+	}{
+		{
+			desc:       "returns zero for an empty population",
+			population: []float64{},
+			lag:        1,
+			expected:   0,
+		},
+		{
+			desc:       "returns one without lag",
+			population: []float64{1, 5, 2, 8},
+			lag:        0,
+			expected:   1,
+		},
+		{
+			desc:       "returns zero for a lag out of range",
+			population: []float64{1, 5, 2, 8},
+			lag:        4,
+			expected:   0,
+		},
+		{
+			desc:       "returns zero for a constant population",
+			population: []float64{3, 3, 3, 3},
+			lag:        1,
+			expected:   0,
+		},
+		{
+			desc:       "correlates a periodic population at its period",
+			population: []float64{1, 1, 9, 1, 1, 9, 1, 1, 9},
+			lag:        3,
+			expected:   0.66,
+		},
+		{
+			desc:       "anticorrelates an alternating population",
+			population: []float64{1, -1, 1, -1},
+			lag:        1,
+			expected:   -0.75,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			r := round(Autocorrelation(tc.population, tc.lag))
+			if r != tc.expected {
+				t.Errorf("wanted %f, got %f", tc.expected, r)
+			}
+		})
+	}
+}
+
 // round truncates the given float64 to 2 decimal places.
 func round(n float64) float64 {
 	return float64(int(n*100)) / 100
@@ -0,0 +1,140 @@
+package math
+
+import (
+	"math"
+	"sort"
+)
+
+// DefaultCompression is the compression of a TDigest providing about 1%
+// accuracy on the median and better towards the extremes, in a few
+// hundred centroids.
+const DefaultCompression = 100
+
+// TDigest is a streaming estimator of the percentiles of a population,
+// using Dunning's merging t-digest. Values are clustered into centroids,
+// whose size is bounded by how close they are to the median, so that its
+// memory usage does not depend on the number of values added while the
+// extreme percentiles remain accurate. Populations of up to a few hundred
+// values are kept exactly. A TDigest is not safe for concurrent use.
+type TDigest struct {
+	compression float64
+	centroids   []centroid
+	buffer      []float64
+	count       int
+	min         float64
+	max         float64
+}
+
+// centroid is a cluster of values of a TDigest.
+type centroid struct {
+	mean   float64
+	weight float64
+}
+
+// NewTDigest returns an empty TDigest with the given compression, which
+// trades memory usage for accuracy.
+func NewTDigest(compression float64) *TDigest {
+	return &TDigest{
+		compression: math.Max(1, compression),
+	}
+}
+
+// Add adds the given value to the digest.
+func (d *TDigest) Add(v float64) {
+	if d.count == 0 || v < d.min {
+		d.min = v
+	}
+	if d.count == 0 || v > d.max {
+		d.max = v
+	}
+	d.count++
+
+	d.buffer = append(d.buffer, v)
+	if len(d.buffer) >= 4*int(d.compression) {
+		d.compress()
+	}
+}
+
+// Count returns the number of values added to the digest.
+func (d *TDigest) Count() int {
+	return d.count
+}
+
+// Percentile estimates the p-th percentile (0-100) of the values added to
+// the digest, linearly interpolating between the closest centroids the
+// same way Percentile does between the closest ranks.
+func (d *TDigest) Percentile(p float64) float64 {
+	d.compress()
+	if len(d.centroids) == 0 {
+		return 0
+	}
+
+	p = math.Max(0, math.Min(100, p))
+	rank := p / 100 * float64(d.count-1)
+
+	// Each centroid is placed at the rank of the middle of its values, and
+	// the minimum and maximum at the first and last ranks.
+	prevRank, prevMean := 0.0, d.min
+	var seen float64
+	for _, c := range d.centroids {
+		r := seen + (c.weight-1)/2
+		if rank <= r {
+			if r == prevRank {
+				return c.mean
+			}
+			return prevMean + (c.mean-prevMean)*(rank-prevRank)/(r-prevRank)
+		}
+		prevRank, prevMean = r, c.mean
+		seen += c.weight
+	}
+
+	last := float64(d.count - 1)
+	if last == prevRank {
+		return d.max
+	}
+	return prevMean + (d.max-prevMean)*(rank-prevRank)/(last-prevRank)
+}
+
+// Clone returns a copy of the digest that does not share any state with it.
+func (d *TDigest) Clone() *TDigest {
+	c := *d
+	c.centroids = append([]centroid(nil), d.centroids...)
+	c.buffer = append([]float64(nil), d.buffer...)
+	return &c
+}
+
+// compress merges the buffered values into the centroids, merging adjacent
+// centroids as long as their combined weight stays within the limit for
+// their position in the population.
+func (d *TDigest) compress() {
+	if len(d.buffer) == 0 {
+		return
+	}
+
+	all := make([]centroid, 0, len(d.centroids)+len(d.buffer))
+	all = append(all, d.centroids...)
+	for _, v := range d.buffer {
+		all = append(all, centroid{mean: v, weight: 1})
+	}
+	d.buffer = d.buffer[:0]
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].mean < all[j].mean
+	})
+
+	total := float64(d.count)
+	merged := []centroid{all[0]}
+	var seen float64
+	for _, c := range all[1:] {
+		last := &merged[len(merged)-1]
+		weight := last.weight + c.weight
+		q := (seen + weight/2) / total
+		if weight <= 4*total*q*(1-q)/d.compression {
+			last.mean += (c.mean - last.mean) * c.weight / weight
+			last.weight = weight
+			continue
+		}
+		seen += last.weight
+		merged = append(merged, c)
+	}
+	d.centroids = merged
+}
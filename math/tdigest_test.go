@@ -0,0 +1,122 @@
+package math
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestTDigestPercentile(t *testing.T) {
+	tests := []struct {
+		desc       string
+		population []float64
+		p          float64
+		expected   float64
+	}{
+		{
+			desc:       "returns zero for an empty population",
+			population: []float64{},
+			p:          50,
+			expected:   0,
+		},
+		{
+			desc:       "returns the single value",
+			population: []float64{4.22},
+			p:          99,
+			expected:   4.22,
+		},
+		{
+			desc:       "interpolates between the closest ranks",
+			population: []float64{4, 1, 3, 2},
+			p:          50,
+			expected:   2.5,
+		},
+		{
+			desc:       "returns the minimum",
+			population: []float64{4, 1, 3, 2},
+			p:          0,
+			expected:   1,
+		},
+		{
+			desc:       "returns the maximum",
+			population: []float64{4, 1, 3, 2},
+			p:          100,
+			expected:   4,
+		},
+		{
+			desc:       "clamps the percentile",
+			population: []float64{4, 1, 3, 2},
+			p:          150,
+			expected:   4,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			d := NewTDigest(DefaultCompression)
+			for _, v := range tc.population {
+				d.Add(v)
+			}
+
+			if p := d.Percentile(tc.p); p != tc.expected {
+				t.Errorf("wanted %f, got %f", tc.expected, p)
+			}
+		})
+	}
+}
+
+func TestTDigestMatchesSmallPopulations(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	population := make([]float64, 200)
+	d := NewTDigest(DefaultCompression)
+	for i := range population {
+		population[i] = r.ExpFloat64() * 10
+		d.Add(population[i])
+	}
+
+	for _, p := range []float64{1, 25, 50, 90, 99, 99.9} {
+		expected, got := round(Percentile(population, p)), round(d.Percentile(p))
+		if got != expected {
+			t.Errorf("p%v: wanted %f, got %f", p, expected, got)
+		}
+	}
+}
+
+func TestTDigestLargePopulations(t *testing.T) {
+	const n = 100000
+	r := rand.New(rand.NewSource(1))
+	d := NewTDigest(DefaultCompression)
+	for _, i := range r.Perm(n) {
+		d.Add(float64(i))
+	}
+
+	if d.Count() != n {
+		t.Errorf("wanted %v, got %v", n, d.Count())
+	}
+	if len(d.centroids)+len(d.buffer) > 10*DefaultCompression {
+		t.Errorf("wanted at most %v centroids, got %v", 10*DefaultCompression, len(d.centroids)+len(d.buffer))
+	}
+
+	for _, p := range []float64{1, 50, 90, 99, 99.9} {
+		expected := p / 100 * (n - 1)
+		if got := d.Percentile(p); math.Abs(got-expected) > 0.01*n*math.Min(p, 100-p)/50 {
+			t.Errorf("p%v: wanted %f, got %f", p, expected, got)
+		}
+	}
+}
+
+func TestTDigestClone(t *testing.T) {
+	d := NewTDigest(DefaultCompression)
+	d.Add(1)
+	d.Add(2)
+
+	c := d.Clone()
+	c.Add(100)
+
+	if p := d.Percentile(100); p != 2 {
+		t.Errorf("wanted %v, got %v", 2, p)
+	}
+	if p := c.Percentile(100); p != 100 {
+		t.Errorf("wanted %v, got %v", 100, p)
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	// mdnsAddr is the IPv4 multicast address and port of mDNS.
+	mdnsAddr = "224.0.0.251:5353"
+
+	// mdnsServices is the name whose PTR records enumerate the service
+	// types advertised on the local network (RFC 6763, section 9).
+	mdnsServices = "_services._dns-sd._udp.local."
+)
+
+// device is a host found on the local network through mDNS.
+type device struct {
+	addr net.IP
+
+	// names holds the host names and the service instance names
+	// advertised by the device.
+	names []string
+}
+
+// mdnsBrowser collects the records of the mDNS responses to its queries.
+type mdnsBrowser struct {
+	conn    *net.UDPConn
+	dst     *net.UDPAddr
+	queried map[string]bool
+
+	// instances maps instance names to their SRV target hosts, and hosts
+	// to their addresses. Names are lowercased, as they are case
+	// insensitive, while names holds them as advertised.
+	instances map[string]string
+	hosts     map[string][]net.IP
+	names     map[string]string
+}
+
+// browseMDNS browses the local network with mDNS for the instances of
+// service (e.g. _http._tcp), or of every service type advertised if empty,
+// waiting for responses until wait elapses. It returns the devices found,
+// sorted by address.
+//
+// Queries are sent from an ephemeral port, so that responders answer them
+// with unicast responses (RFC 6762, section 6.7), without having to share
+// the mDNS port with any local responder.
+func browseMDNS(service string, wait time.Duration) ([]device, error) {
+	dst, err := net.ResolveUDPAddr("udp4", mdnsAddr)
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve mDNS address: %v", err)
+	}
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot listen for mDNS responses: %v", err)
+	}
+	defer conn.Close()
+
+	b := &mdnsBrowser{
+		conn:      conn,
+		dst:       dst,
+		queried:   make(map[string]bool),
+		instances: make(map[string]string),
+		hosts:     make(map[string][]net.IP),
+		names:     make(map[string]string),
+	}
+
+	name := mdnsServices
+	if service != "" {
+		name = strings.TrimSuffix(service, ".") + ".local."
+	}
+	if err := b.query(name, dnsmessage.TypePTR); err != nil {
+		return nil, err
+	}
+
+	conn.SetReadDeadline(time.Now().Add(wait))
+	buf := make([]byte, 9000)
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if neterr, ok := err.(net.Error); ok && neterr.Timeout() {
+				break
+			}
+			return nil, fmt.Errorf("cannot read mDNS response: %v", err)
+		}
+
+		var msg dnsmessage.Message
+		if err := msg.Unpack(buf[:n]); err != nil || !msg.Response {
+			continue
+		}
+		if err := b.record(append(msg.Answers, msg.Additionals...)); err != nil {
+			return nil, err
+		}
+	}
+	return b.devices(), nil
+}
+
+// query sends a query for the records of the given name and type, unless
+// already sent.
+func (b *mdnsBrowser) query(name string, typ dnsmessage.Type) error {
+	key := fmt.Sprintf("%s/%v", strings.ToLower(name), typ)
+	if b.queried[key] {
+		return nil
+	}
+	b.queried[key] = true
+
+	n, err := dnsmessage.NewName(name)
+	if err != nil {
+		return fmt.Errorf("invalid mDNS name %q: %v", name, err)
+	}
+	msg := dnsmessage.Message{
+		Questions: []dnsmessage.Question{
+			{Name: n, Type: typ, Class: dnsmessage.ClassINET},
+		},
+	}
+	pkt, err := msg.Pack()
+	if err != nil {
+		return fmt.Errorf("cannot encode mDNS query for %s: %v", name, err)
+	}
+	if _, err := b.conn.WriteToUDP(pkt, b.dst); err != nil {
+		return fmt.Errorf("cannot send mDNS query for %s: %v", name, err)
+	}
+	return nil
+}
+
+// record records the given resource records, following them with the
+// queries needed for resolving service types down to instances, and
+// instances down to the addresses of their hosts.
+func (b *mdnsBrowser) record(rrs []dnsmessage.Resource) error {
+	var follow []dnsmessage.Question
+	for _, rr := range rrs {
+		name := strings.ToLower(rr.Header.Name.String())
+		b.names[name] = strings.TrimSuffix(rr.Header.Name.String(), ".")
+		switch body := rr.Body.(type) {
+		case *dnsmessage.PTRResource:
+			typ := dnsmessage.TypeSRV
+			if name == mdnsServices {
+				// A service type, whose instances are to be browsed.
+				typ = dnsmessage.TypePTR
+			}
+			follow = append(follow, dnsmessage.Question{Name: body.PTR, Type: typ})
+		case *dnsmessage.SRVResource:
+			host := strings.ToLower(body.Target.String())
+			b.instances[name] = host
+			follow = append(follow, dnsmessage.Question{Name: body.Target, Type: dnsmessage.TypeA})
+		case *dnsmessage.AResource:
+			ip := net.IP(body.A[:])
+			if !containsIP(b.hosts[name], ip) {
+				b.hosts[name] = append(b.hosts[name], ip)
+			}
+		}
+	}
+
+	for _, q := range follow {
+		if q.Type == dnsmessage.TypeA && len(b.hosts[strings.ToLower(q.Name.String())]) > 0 {
+			continue
+		}
+		if q.Type == dnsmessage.TypeSRV && b.instances[strings.ToLower(q.Name.String())] != "" {
+			continue
+		}
+		if err := b.query(q.Name.String(), q.Type); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// devices returns the devices found so far, sorted by address.
+func (b *mdnsBrowser) devices() []device {
+	byAddr := make(map[string]*device)
+	for host, ips := range b.hosts {
+		for _, ip := range ips {
+			d, ok := byAddr[ip.String()]
+			if !ok {
+				d = &device{addr: ip}
+				byAddr[ip.String()] = d
+			}
+			d.names = append(d.names, b.names[host])
+			for instance, target := range b.instances {
+				if target == host {
+					d.names = append(d.names, b.names[instance])
+				}
+			}
+		}
+	}
+
+	devices := make([]device, 0, len(byAddr))
+	for _, d := range byAddr {
+		sort.Strings(d.names)
+		devices = append(devices, *d)
+	}
+	sort.Slice(devices, func(i, j int) bool {
+		return bytes.Compare(devices[i].addr.To16(), devices[j].addr.To16()) < 0
+	})
+	return devices
+}
+
+// containsIP returns whether ips contains ip.
+func containsIP(ips []net.IP, ip net.IP) bool {
+	for _, other := range ips {
+		if other.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// mdnsDiscoverer returns a discoverer of the addresses of the devices
+// advertising service (or any service, if empty) through mDNS.
+func mdnsDiscoverer(service string, wait time.Duration) discoverer {
+	return func() ([]string, error) {
+		devices, err := browseMDNS(service, wait)
+		if err != nil {
+			return nil, err
+		}
+		hosts := make([]string, len(devices))
+		for i, d := range devices {
+			hosts[i] = d.addr.String()
+		}
+		sort.Strings(hosts)
+		return hosts, nil
+	}
+}
+
+// printDevices prints the devices found through mDNS, one per line.
+func printDevices(devices []device) {
+	if len(devices) == 0 {
+		fmt.Println("no devices found")
+		return
+	}
+
+	width := 0
+	for _, d := range devices {
+		if n := len(d.addr.String()); n > width {
+			width = n
+		}
+	}
+	for _, d := range devices {
+		fmt.Printf("%-*s  %s\n", width, d.addr, strings.Join(d.names, ", "))
+	}
+}
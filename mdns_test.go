@@ -0,0 +1,151 @@
+package main
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// newTestBrowser returns an mdnsBrowser sending its queries to a local
+// listener, which is returned along with it.
+func newTestBrowser(t *testing.T) (*mdnsBrowser, *net.UDPConn) {
+	t.Helper()
+	lo := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)}
+	dst, err := net.ListenUDP("udp4", lo)
+	if err != nil {
+		t.Fatalf("cannot listen: %v", err)
+	}
+	conn, err := net.ListenUDP("udp4", lo)
+	if err != nil {
+		dst.Close()
+		t.Fatalf("cannot listen: %v", err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		dst.Close()
+	})
+
+	return &mdnsBrowser{
+		conn:      conn,
+		dst:       dst.LocalAddr().(*net.UDPAddr),
+		queried:   make(map[string]bool),
+		instances: make(map[string]string),
+		hosts:     make(map[string][]net.IP),
+		names:     make(map[string]string),
+	}, dst
+}
+
+// packResponse packs an mDNS response with the given answers, and unpacks
+// it back into the records received.
+func packResponse(t *testing.T, answers ...dnsmessage.Resource) []dnsmessage.Resource {
+	t.Helper()
+	msg := dnsmessage.Message{
+		Header:  dnsmessage.Header{Response: true, Authoritative: true},
+		Answers: answers,
+	}
+	pkt, err := msg.Pack()
+	if err != nil {
+		t.Fatalf("cannot pack response: %v", err)
+	}
+	var received dnsmessage.Message
+	if err := received.Unpack(pkt); err != nil {
+		t.Fatalf("cannot unpack response: %v", err)
+	}
+	return received.Answers
+}
+
+// mdnsHeader returns the header of a record of name.
+func mdnsHeader(name string) dnsmessage.ResourceHeader {
+	return dnsmessage.ResourceHeader{Name: dnsmessage.MustNewName(name), Class: dnsmessage.ClassINET, TTL: 120}
+}
+
+func TestMDNSRecord(t *testing.T) {
+	b, dst := newTestBrowser(t)
+
+	// The instance is advertised first, and its SRV record is queried.
+	rrs := packResponse(t, dnsmessage.Resource{
+		Header: mdnsHeader("_http._tcp.local."),
+		Body:   &dnsmessage.PTRResource{PTR: dnsmessage.MustNewName("Printer._http._tcp.local.")},
+	})
+	if err := b.record(rrs); err != nil {
+		t.Fatalf("wanted no error, got %v", err)
+	}
+	expectQuery(t, dst, "Printer._http._tcp.local.", dnsmessage.TypeSRV)
+
+	// Its host is then queried, named differently than advertised.
+	rrs = packResponse(t, dnsmessage.Resource{
+		Header: mdnsHeader("Printer._http._tcp.local."),
+		Body:   &dnsmessage.SRVResource{Port: 80, Target: dnsmessage.MustNewName("MyPrinter.local.")},
+	})
+	if err := b.record(rrs); err != nil {
+		t.Fatalf("wanted no error, got %v", err)
+	}
+	expectQuery(t, dst, "MyPrinter.local.", dnsmessage.TypeA)
+
+	// The addresses of the host are merged regardless of the case of its
+	// name, and duplicates are ignored.
+	rrs = packResponse(t,
+		dnsmessage.Resource{
+			Header: mdnsHeader("myprinter.local."),
+			Body:   &dnsmessage.AResource{A: [4]byte{192, 168, 1, 20}},
+		},
+		dnsmessage.Resource{
+			Header: mdnsHeader("MYPRINTER.local."),
+			Body:   &dnsmessage.AResource{A: [4]byte{192, 168, 1, 20}},
+		},
+		dnsmessage.Resource{
+			Header: mdnsHeader("MyPrinter.local."),
+			Body:   &dnsmessage.AResource{A: [4]byte{192, 168, 1, 10}},
+		},
+	)
+	if err := b.record(rrs); err != nil {
+		t.Fatalf("wanted no error, got %v", err)
+	}
+
+	expected := []device{
+		{addr: net.IPv4(192, 168, 1, 10).To4(), names: []string{"MyPrinter.local", "Printer._http._tcp.local"}},
+		{addr: net.IPv4(192, 168, 1, 20).To4(), names: []string{"MyPrinter.local", "Printer._http._tcp.local"}},
+	}
+	if devices := b.devices(); !reflect.DeepEqual(devices, expected) {
+		t.Errorf("wanted %v, got %v", expected, devices)
+	}
+}
+
+func TestMDNSRecordServiceTypes(t *testing.T) {
+	b, dst := newTestBrowser(t)
+
+	rrs := packResponse(t, dnsmessage.Resource{
+		Header: mdnsHeader(mdnsServices),
+		Body:   &dnsmessage.PTRResource{PTR: dnsmessage.MustNewName("_ipp._tcp.local.")},
+	})
+	if err := b.record(rrs); err != nil {
+		t.Fatalf("wanted no error, got %v", err)
+	}
+	expectQuery(t, dst, "_ipp._tcp.local.", dnsmessage.TypePTR)
+}
+
+// expectQuery reads the next query from dst and checks it is for the
+// given name and type.
+func expectQuery(t *testing.T, dst *net.UDPConn, name string, typ dnsmessage.Type) {
+	t.Helper()
+	dst.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, 9000)
+	n, _, err := dst.ReadFromUDP(buf)
+	if err != nil {
+		t.Fatalf("cannot read query: %v", err)
+	}
+	var msg dnsmessage.Message
+	if err := msg.Unpack(buf[:n]); err != nil {
+		t.Fatalf("cannot unpack query: %v", err)
+	}
+	if len(msg.Questions) != 1 {
+		t.Fatalf("wanted a single question, got %v", msg.Questions)
+	}
+	q := msg.Questions[0]
+	if q.Name.String() != name || q.Type != typ {
+		t.Errorf("wanted a %v query for %s, got a %v query for %s", typ, name, q.Type, q.Name)
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/caiofilipini/pingo/math"
+	"github.com/caiofilipini/pingo/pinger"
+)
+
+// Exit codes of monitoring plugins, as expected by Nagios and Icinga, which
+// are also the states of CheckMK local checks.
+const (
+	nagiosOK       = 0
+	nagiosWarning  = 1
+	nagiosCritical = 2
+	nagiosUnknown  = 3
+)
+
+// defaultNagiosCount is the number of requests of a check when no count is
+// specified, as with check_ping.
+const defaultNagiosCount = 5
+
+// nagiosStates are the labels of the plugin states, indexed by exit code.
+var nagiosStates = [...]string{"OK", "WARNING", "CRITICAL", "UNKNOWN"}
+
+// checkThreshold is the round-trip average and packet loss, in percent, at
+// which a check reaches a given state.
+type checkThreshold struct {
+	rta  time.Duration
+	loss float64
+}
+
+// parseCheckThreshold parses a threshold in the form check_ping takes, e.g.
+// 100ms,5% or 100.0,5%, the latter in milliseconds.
+func parseCheckThreshold(s string) (checkThreshold, error) {
+	rta, loss, ok := strings.Cut(s, ",")
+	if !ok || !strings.HasSuffix(loss, "%") {
+		return checkThreshold{}, fmt.Errorf("expected rta,loss%%, e.g. 100ms,5%%")
+	}
+
+	var t checkThreshold
+	d, err := time.ParseDuration(rta)
+	if err != nil {
+		ms, msErr := strconv.ParseFloat(rta, 64)
+		if msErr != nil {
+			return checkThreshold{}, fmt.Errorf("invalid round-trip average %q: %v", rta, err)
+		}
+		d = time.Duration(ms * float64(time.Millisecond))
+	}
+	t.rta = d
+	t.loss, err = strconv.ParseFloat(strings.TrimSuffix(loss, "%"), 64)
+	if err != nil {
+		return checkThreshold{}, fmt.Errorf("invalid packet loss %q: %v", loss, err)
+	}
+	if t.rta < 0 || t.loss < 0 || t.loss > 100 {
+		return checkThreshold{}, fmt.Errorf("threshold %q out of range", s)
+	}
+	return t, nil
+}
+
+// exceeded returns whether a round-trip average of rta, if any replies were
+// received, and a packet loss of loss reach the threshold.
+func (t checkThreshold) exceeded(rta time.Duration, received bool, loss float64) bool {
+	return loss >= t.loss || (received && rta >= t.rta)
+}
+
+// runNagios runs pingo as a Nagios/Icinga plugin, replacing check_ping: it
+// pings the host given in args with opts, along with the thresholds, e.g.
+//
+//	host -w 100ms,5% -c 300ms,20%
+//
+// and prints a single status line with performance data, exiting with the
+// code of the resulting state. Anything preventing the check from being
+// carried out results in the UNKNOWN state.
+func runNagios(args []string, count uint, opts pinger.Options) {
+	fs := flag.NewFlagSet("nagios", flag.ContinueOnError)
+	fs.SetOutput(os.Stdout)
+	warn := fs.String("w", "", "round-trip average and packet loss at which the check is WARNING, e.g. 100ms,5%")
+	crit := fs.String("c", "", "round-trip average and packet loss at which the check is CRITICAL, e.g. 300ms,20%")
+	packets := fs.Uint("p", defaultNagiosCount, "number of packets to send; the global -c flag, given before the subcommand, overrides it")
+
+	// Flags may be given after the host, as is customary with plugins.
+	var hosts []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			os.Exit(nagiosUnknown)
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		hosts = append(hosts, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(hosts) != 1 || *warn == "" || *crit == "" {
+		nagiosExit(nagiosUnknown, "usage: nagios host -w rta,loss% -c rta,loss%")
+	}
+	warning, err := parseCheckThreshold(*warn)
+	if err != nil {
+		nagiosExit(nagiosUnknown, fmt.Sprintf("invalid warning threshold: %v", err))
+	}
+	critical, err := parseCheckThreshold(*crit)
+	if err != nil {
+		nagiosExit(nagiosUnknown, fmt.Sprintf("invalid critical threshold: %v", err))
+	}
+
+	host := hosts[0]
+	addr, err := opts.Resolve(context.Background(), host)
+	if err != nil {
+		nagiosExit(nagiosUnknown, fmt.Sprintf("failed to resolve host %s: %v", host, err))
+	}
+
+	opts.Count = count
+	if opts.Count == 0 {
+		opts.Count = *packets
+	}
+	stats, err := nagiosPing(addr, opts)
+	if err != nil {
+		nagiosExit(nagiosUnknown, fmt.Sprintf("failed to ping %s: %v", host, err))
+	}
+
+	res := newCheckResult(stats)
+	perfdata := fmt.Sprintf("pl=%g%%;%g;%g;0", res.loss, warning.loss, critical.loss)
+	if res.received {
+		perfdata = fmt.Sprintf("rta=%fms;%f;%f;%f ", math.TimeInMillis(res.rtts.Avg), math.TimeInMillis(warning.rta), math.TimeInMillis(critical.rta), 0.0) + perfdata
+	}
+	nagiosExit(checkState(res, warning, critical), formatCheck(res)+"|"+perfdata)
+}
+
+// checkResult is the outcome of the requests of a check.
+type checkResult struct {
+	loss     float64
+	received bool
+	rtts     pinger.RTTSummary
+}
+
+// newCheckResult returns the outcome of the requests of a check out of
+// their stats.
+func newCheckResult(stats pinger.Stats) checkResult {
+	return checkResult{
+		loss:     stats.PacketLoss(),
+		received: stats.Received() > 0,
+		rtts:     stats.RTTSummary(),
+	}
+}
+
+// checkState returns the state of a check of res against the warning and
+// critical thresholds. A check without any replies is critical.
+func checkState(res checkResult, warning checkThreshold, critical checkThreshold) int {
+	switch {
+	case !res.received || critical.exceeded(res.rtts.Avg, res.received, res.loss):
+		return nagiosCritical
+	case warning.exceeded(res.rtts.Avg, res.received, res.loss):
+		return nagiosWarning
+	default:
+		return nagiosOK
+	}
+}
+
+// formatCheck formats the packet loss and round-trip average of res for
+// the summary of a check.
+func formatCheck(res checkResult) string {
+	msg := fmt.Sprintf("Packet loss = %g%%", res.loss)
+	if res.received {
+		msg += fmt.Sprintf(", RTA = %.2f ms", math.TimeInMillis(res.rtts.Avg))
+	}
+	return msg
+}
+
+// nagiosPing pings addr with opts until done, returning the stats, or the
+// error that stopped it.
+func nagiosPing(addr net.Addr, opts pinger.Options) (pinger.Stats, error) {
+	p := pinger.NewPinger(&opts)
+	results, errs := p.Report()
+	go p.Ping(addr)
+
+	for range results {
+	}
+	if err, ok := <-errs; ok {
+		return pinger.Stats{}, err
+	}
+	return p.Stats(), nil
+}
+
+// nagiosExit prints the status line of state, followed by msg, and exits
+// with its code.
+func nagiosExit(state int, msg string) {
+	fmt.Printf("PING %s - %s\n", nagiosStates[state], msg)
+	os.Exit(state)
+}
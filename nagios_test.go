@@ -0,0 +1,136 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/caiofilipini/pingo/pinger"
+)
+
+func TestParseCheckThreshold(t *testing.T) {
+	tests := []struct {
+		desc     string
+		s        string
+		expected checkThreshold
+		wantErr  bool
+	}{
+		{
+			desc:     "milliseconds",
+			s:        "100ms,5%",
+			expected: checkThreshold{rta: 100 * time.Millisecond, loss: 5},
+		},
+		{
+			desc:     "seconds",
+			s:        "1.5s,20%",
+			expected: checkThreshold{rta: 1500 * time.Millisecond, loss: 20},
+		},
+		{
+			desc:     "plain milliseconds, as check_ping takes",
+			s:        "100.0,5%",
+			expected: checkThreshold{rta: 100 * time.Millisecond, loss: 5},
+		},
+		{
+			desc:     "fractional loss",
+			s:        "200ms,0.5%",
+			expected: checkThreshold{rta: 200 * time.Millisecond, loss: 0.5},
+		},
+		{
+			desc:    "missing loss",
+			s:       "100ms",
+			wantErr: true,
+		},
+		{
+			desc:    "loss without percent sign",
+			s:       "100ms,5",
+			wantErr: true,
+		},
+		{
+			desc:    "invalid round-trip average",
+			s:       "fast,5%",
+			wantErr: true,
+		},
+		{
+			desc:    "invalid loss",
+			s:       "100ms,some%",
+			wantErr: true,
+		},
+		{
+			desc:    "negative round-trip average",
+			s:       "-100ms,5%",
+			wantErr: true,
+		},
+		{
+			desc:    "loss over 100%",
+			s:       "100ms,150%",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			threshold, err := parseCheckThreshold(tc.s)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("wanted an error, got %+v", threshold)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("wanted no error, got %v", err)
+			}
+			if threshold != tc.expected {
+				t.Errorf("wanted %+v, got %+v", tc.expected, threshold)
+			}
+		})
+	}
+}
+
+func TestCheckState(t *testing.T) {
+	warning := checkThreshold{rta: 100 * time.Millisecond, loss: 5}
+	critical := checkThreshold{rta: 300 * time.Millisecond, loss: 20}
+
+	tests := []struct {
+		desc     string
+		res      checkResult
+		expected int
+	}{
+		{
+			desc:     "under both thresholds",
+			res:      checkResult{received: true, rtts: pinger.RTTSummary{Avg: 50 * time.Millisecond}},
+			expected: nagiosOK,
+		},
+		{
+			desc:     "round-trip average at the warning threshold",
+			res:      checkResult{received: true, rtts: pinger.RTTSummary{Avg: 100 * time.Millisecond}},
+			expected: nagiosWarning,
+		},
+		{
+			desc:     "loss over the warning threshold",
+			res:      checkResult{loss: 10, received: true, rtts: pinger.RTTSummary{Avg: 50 * time.Millisecond}},
+			expected: nagiosWarning,
+		},
+		{
+			desc:     "round-trip average over the critical threshold",
+			res:      checkResult{received: true, rtts: pinger.RTTSummary{Avg: 500 * time.Millisecond}},
+			expected: nagiosCritical,
+		},
+		{
+			desc:     "loss at the critical threshold",
+			res:      checkResult{loss: 20, received: true, rtts: pinger.RTTSummary{Avg: 50 * time.Millisecond}},
+			expected: nagiosCritical,
+		},
+		{
+			desc:     "no replies",
+			res:      checkResult{loss: 100},
+			expected: nagiosCritical,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := checkState(tc.res, warning, critical); got != tc.expected {
+				t.Errorf("wanted %v, got %v", nagiosStates[tc.expected], nagiosStates[got])
+			}
+		})
+	}
+}
@@ -0,0 +1,730 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/caiofilipini/pingo/asn"
+	"github.com/caiofilipini/pingo/geoip"
+	"github.com/caiofilipini/pingo/math"
+	"github.com/caiofilipini/pingo/pinger"
+)
+
+// printer prints the results of a run in a given output format.
+type printer interface {
+	// header prints what precedes the results.
+	header(size uint)
+
+	// result prints the result of a single request.
+	result(res pinger.Ping)
+
+	// interval prints the stats for the last interval of a run.
+	interval(interval time.Duration, stats pinger.Stats)
+
+	// stats prints the stats for the whole run, including the given
+	// percentiles.
+	stats(stats pinger.Stats, ps []float64)
+}
+
+// textPrinter prints human readable output, similar to ping(8).
+type textPrinter struct {
+	host    string
+	addr    net.Addr
+	flows   uint
+	ecn     uint
+	dscp    uint
+	oneWay  bool
+	missing bool
+	rotate  bool
+	buckets bool
+	unit    string
+	color   bool
+	db      *geoip.DB
+	asns    *asn.Client
+	state   pinger.State
+	method  pinger.Method
+}
+
+func (p *textPrinter) header(size uint) {
+	fmt.Printf("PING %s%s%s: %d data bytes\n", p.addr, formatLocation(p.db, p.addr), formatASN(p.asns, p.addr, true), size)
+}
+
+func (p *textPrinter) result(res pinger.Ping) {
+	if res.Method != p.method {
+		fmt.Printf("--- %s probed via %v\n", p.host, res.Method)
+		p.method = res.Method
+	}
+
+	// Requests are sent to other addresses of the host when rotating.
+	addr := p.addr
+	if res.Addr != nil {
+		addr = res.Addr
+	}
+
+	if res.Timeout {
+		fmt.Printf("Request timeout for icmp_seq %d%s\n", res.Seq, formatTarget(p.rotate, addr))
+	} else if res.TimeExceeded {
+		fmt.Printf("From %v%s%s: icmp_seq=%d Time to live exceeded%s\n", res.Hop.Addr, formatLocation(p.db, res.Hop.Addr), formatASN(p.asns, res.Hop.Addr, false), res.Hop.Seq, formatExtensions(res.Extensions))
+	} else if res.Unreachable {
+		fmt.Printf("Destination unreachable for icmp_seq %d%s%s%s\n", res.Seq, formatTarget(p.rotate, addr), formatMTU(res), formatExtensions(res.Extensions))
+	} else if res.Method == pinger.TCPConnect {
+		fmt.Printf("TCP handshake with %v: seq=%d time=%s%s%s%s\n", addr, res.Seq, formatRTTs(p.unit, math.TimeInMillis(res.RTT)), formatSeverity(res, p.color), formatAnomaly(res), formatWarmup(res))
+	} else {
+		fmt.Printf("%d bytes from %v%s: icmp_seq=%d%s%s%s%s time=%s%s%s%s%s%s\n",
+			res.Size,
+			responder(res, addr),
+			formatForeign(res, addr),
+			res.Seq,
+			formatTTL(res),
+			formatFlow(p.flows, res.Flow),
+			formatECN(p.ecn, res.ECN),
+			formatDSCP(p.dscp, res),
+			formatRTTs(p.unit, math.TimeInMillis(res.RTT)),
+			formatSeverity(res, p.color),
+			formatOneWay(p.oneWay, p.unit, res),
+			formatBandwidth(res),
+			formatAnomaly(res),
+			formatWarmup(res),
+		)
+	}
+
+	if r := res.Redirect; r != nil {
+		fmt.Printf("From %v: icmp_seq=%d Redirect %s(New nexthop: %v) for %v\n", r.From, res.Seq, redirectName(r.Code), r.Gateway, r.Destination)
+	}
+
+	if res.State != p.state {
+		fmt.Printf("--- %s is %v\n", p.host, res.State)
+		p.state = res.State
+	}
+}
+
+func (p *textPrinter) interval(interval time.Duration, stats pinger.Stats) {
+	min, avg, max, stddev := stats.RTTStats()
+	srtt, rttvar := stats.SmoothedRTT()
+	fmt.Printf(
+		"--- %s last %v: %d packets transmitted, %d packets received, %.1f%% packet loss, round-trip min/avg/max/stddev = %s, srtt/rttvar = %s\n",
+		p.host,
+		interval,
+		stats.Transmitted(),
+		stats.Received(),
+		stats.PacketLoss(),
+		formatRTTs(p.unit, min, avg, max, stddev),
+		formatRTTs(p.unit, srtt, rttvar),
+	)
+}
+
+func (p *textPrinter) stats(stats pinger.Stats, ps []float64) {
+	fmt.Println()
+	fmt.Printf("--- %s ping statistics ---\n", p.host)
+	fmt.Printf(
+		"%d packets transmitted, %d packets received, %.1f%% packet loss\n",
+		stats.Transmitted(),
+		stats.Received(),
+		stats.PacketLoss(),
+	)
+
+	if timeouts, errs := stats.Timeouts(), stats.Errors(); errs > 0 {
+		fmt.Printf("%d requests timed out, %d answered with errors\n", timeouts, errs)
+	}
+
+	if dups := stats.Duplicates(); dups > 0 {
+		fmt.Printf("%d duplicate replies discarded\n", dups)
+	}
+
+	if warmup := stats.Warmup(); warmup > 0 {
+		fmt.Printf("%d warmup packets excluded\n", warmup)
+	}
+
+	if bursts, max, mean := stats.LossBursts(); bursts > 0 {
+		fmt.Printf("%d loss bursts, max/mean = %d/%.1f packets, burstiness %.2f\n", bursts, max, mean, stats.Burstiness())
+	}
+
+	if stats.RateLimited() {
+		fmt.Println("evenly spaced losses at low latency: target may be rate-limiting ICMP")
+	}
+
+	if n := stats.OutageCount(); n > 0 {
+		outages := stats.Outages()
+		if len(outages) < n {
+			fmt.Printf("%d outages, the most recent %d of them:\n", n, len(outages))
+		} else {
+			fmt.Printf("%d outages:\n", n)
+		}
+		for _, o := range outages {
+			fmt.Println("  " + formatOutage(o))
+		}
+		if longest, ok := stats.LongestOutage(); ok && len(outages) < n {
+			fmt.Println("longest outage: " + formatOutage(longest))
+		}
+	}
+
+	min, avg, max, stddev := stats.RTTStats()
+	fmt.Printf("round-trip min/avg/max/stddev = %s\n", formatRTTs(p.unit, min, avg, max, stddev))
+
+	srtt, rttvar := stats.SmoothedRTT()
+	fmt.Printf("smoothed round-trip srtt/rttvar = %s\n", formatRTTs(p.unit, srtt, rttvar))
+
+	if trend, ok := stats.Trend(); ok {
+		scale := rttScaleOf(p.unit, trend)
+		fmt.Printf("round-trip trend = %+.*f %s/min\n", scale.digits, trend/scale.ms, scale.label)
+	}
+
+	if period, r := stats.Periodicity(); period > 0 {
+		fmt.Printf("periodic round-trip pattern every %d replies (autocorrelation %.2f)\n", period, r)
+	}
+
+	if bw, n := stats.Bandwidth(); n > 0 {
+		fmt.Printf("estimated bottleneck bandwidth = %s (median of %d packet pairs)\n", bitRate(bw), n)
+	}
+
+	if forward, reverse, ok := stats.OneWayDelays(); ok {
+		fmt.Printf("one-way forward/reverse avg = %s, asymmetry %s\n", formatRTTs(p.unit, forward, reverse), formatRTTs(p.unit, forward-reverse))
+	}
+
+	if responders := stats.Responders(); len(responders) > 1 {
+		addrs := make([]string, 0, len(responders))
+		for addr := range responders {
+			addrs = append(addrs, addr)
+		}
+		sort.Strings(addrs)
+
+		fmt.Printf("%d responders:\n", len(addrs))
+		for _, addr := range addrs {
+			fmt.Printf("  %s: %d replies\n", addr, responders[addr])
+		}
+	}
+
+	if corrupted := stats.Corrupted(); corrupted > 0 {
+		fmt.Printf("%d corrupted packets discarded, %d with invalid checksums\n", corrupted, stats.ChecksumErrors())
+	}
+
+	if anomalies := stats.Anomalies(); anomalies > 0 {
+		fmt.Printf("%d anomalous round-trips\n", anomalies)
+	}
+
+	if warn, crit := stats.Severities(); warn > 0 || crit > 0 {
+		fmt.Printf("%s warn and %s crit round-trips\n", colorize(fmt.Sprint(warn), p.color && warn > 0, colorWarn), colorize(fmt.Sprint(crit), p.color && crit > 0, colorCrit))
+	}
+
+	if p.buckets {
+		if buckets := stats.Buckets(); len(buckets) > 0 {
+			fmt.Printf("%d time buckets:\n", len(buckets))
+			for _, b := range buckets {
+				fmt.Println("  " + formatBucket(b, p.unit))
+			}
+		}
+	}
+
+	if p.missing {
+		if missing := stats.Missing(); len(missing) > 0 {
+			fmt.Printf("missing icmp_seq %s\n", formatSeqs(missing))
+		}
+		if late := stats.Late(); len(late) > 0 {
+			fmt.Printf("late icmp_seq %s\n", formatSeqs(late))
+		}
+	}
+
+	if len(ps) > 0 {
+		names := make([]string, len(ps))
+		for i := range ps {
+			names[i] = percentileName(ps[i])
+		}
+		fmt.Printf("round-trip %s = %s\n", strings.Join(names, "/"), formatRTTs(p.unit, stats.Percentiles(ps...)...))
+	}
+}
+
+// tsvPrinter prints one line of tab-separated values per result, with the
+// following columns, for consumption by scripts:
+//
+//	seq, status (reply, timeout, ttl-exceeded or unreachable), responder
+//	address, size in bytes, RTT in milliseconds, responder location
+//
+// Columns without a value for a given result are left empty, as is the
+// location unless db is set. Nothing else is printed, so the header and
+// stats are omitted.
+type tsvPrinter struct {
+	addr net.Addr
+	db   *geoip.DB
+}
+
+func (p *tsvPrinter) header(size uint) {}
+
+func (p *tsvPrinter) result(res pinger.Ping) {
+	var status, from, size, rtt, loc string
+	switch {
+	case res.Timeout:
+		status = "timeout"
+	case res.TimeExceeded:
+		status, from = "ttl-exceeded", res.Hop.Addr.String()
+		if l := lookupLocation(p.db, res.Hop.Addr); l != nil {
+			loc = l.String()
+		}
+	case res.Unreachable:
+		status = "unreachable"
+	default:
+		addr := responder(res, p.addr)
+		status, from = "reply", addr.String()
+		size, rtt = fmt.Sprint(res.Size), fmt.Sprintf("%.3f", math.TimeInMillis(res.RTT))
+		if l := lookupLocation(p.db, addr); l != nil {
+			loc = l.String()
+		}
+	}
+	fmt.Println(strings.Join([]string{fmt.Sprint(res.Seq), status, from, size, rtt, loc}, "\t"))
+}
+
+func (p *tsvPrinter) interval(interval time.Duration, stats pinger.Stats) {}
+
+func (p *tsvPrinter) stats(stats pinger.Stats, ps []float64) {}
+
+// smokepingPrinter prints the stats of each interval, and of the whole run,
+// as RRDtool update values laid out as Smokeping's probes lay them out, so
+// that they can be fed to Smokeping's RRD files:
+//
+//	time:uptime:loss:median:ping1:...:pingN
+//
+// where time is the Unix time the stats were printed at, uptime is always
+// unknown (U), loss is the number of requests lost, and pings are the
+// round-trip latencies in seconds, sorted, with as many unknown values
+// around them as requests lost. Latencies no longer retained, as with
+// Options.DiscardSamples, are reported as unknown too. Nothing else is
+// printed.
+type smokepingPrinter struct{}
+
+func (p *smokepingPrinter) header(size uint) {}
+
+func (p *smokepingPrinter) result(res pinger.Ping) {}
+
+func (p *smokepingPrinter) interval(interval time.Duration, stats pinger.Stats) {
+	fmt.Println(formatRRDUpdate(time.Now(), stats.Transmitted(), stats.Received(), stats.RTTs()))
+}
+
+func (p *smokepingPrinter) stats(stats pinger.Stats, ps []float64) {
+	fmt.Println(formatRRDUpdate(time.Now(), stats.Transmitted(), stats.Received(), stats.RTTs()))
+}
+
+// formatRRDUpdate formats the number of requests transmitted, of replies
+// received and the round-trip latencies kept of them as RRDtool update
+// values at t, in the layout of Smokeping's RRD files.
+func formatRRDUpdate(t time.Time, transmitted int, received int, rtts []time.Duration) string {
+	sort.Slice(rtts, func(i, j int) bool { return rtts[i] < rtts[j] })
+
+	median := "U"
+	if len(rtts) > 0 {
+		median = formatSeconds(rtts[len(rtts)/2])
+	}
+
+	// As Smokeping does, the latencies are centered among the unknown
+	// values standing for the requests lost.
+	unknown := transmitted - len(rtts)
+	if unknown < 0 {
+		unknown = 0
+	}
+	values := []string{fmt.Sprint(t.Unix()), "U", fmt.Sprint(transmitted - received), median}
+	for i := 0; i < unknown/2; i++ {
+		values = append(values, "U")
+	}
+	for _, rtt := range rtts {
+		values = append(values, formatSeconds(rtt))
+	}
+	for i := 0; i < unknown-unknown/2; i++ {
+		values = append(values, "U")
+	}
+	return strings.Join(values, ":")
+}
+
+// formatSeconds formats d in seconds, as Smokeping stores latencies.
+func formatSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.10e", d.Seconds())
+}
+
+// checkmkPrinter prints the stats of the whole run as a CheckMK local check
+// line, so that a short counted run can be called by the agent as is:
+//
+//	state "PING host" rta=...;warn;crit|pl=...;warn;crit|... summary
+//
+// The state is checked against the warning and critical thresholds, and
+// round-trip latencies are in seconds, as with CheckMK's own ping checks.
+// Nothing else is printed.
+type checkmkPrinter struct {
+	host     string
+	warning  checkThreshold
+	critical checkThreshold
+}
+
+func (p *checkmkPrinter) header(size uint) {}
+
+func (p *checkmkPrinter) result(res pinger.Ping) {}
+
+func (p *checkmkPrinter) interval(interval time.Duration, stats pinger.Stats) {}
+
+func (p *checkmkPrinter) stats(stats pinger.Stats, ps []float64) {
+	fmt.Println(p.format(newCheckResult(stats)))
+}
+
+// format formats the local check line of res.
+func (p *checkmkPrinter) format(res checkResult) string {
+	metrics := []string{fmt.Sprintf("pl=%g;%g;%g;0;100", res.loss, p.warning.loss, p.critical.loss)}
+	if res.received {
+		metrics = append([]string{
+			fmt.Sprintf("rta=%f;%f;%f;0", res.rtts.Avg.Seconds(), p.warning.rta.Seconds(), p.critical.rta.Seconds()),
+		}, metrics...)
+		metrics = append(metrics, fmt.Sprintf("rtmin=%f", res.rtts.Min.Seconds()), fmt.Sprintf("rtmax=%f", res.rtts.Max.Seconds()))
+	}
+	return fmt.Sprintf("%d %q %s %s", checkState(res, p.warning, p.critical), "PING "+p.host, strings.Join(metrics, "|"), formatCheck(res))
+}
+
+// responder returns the address res was received from, or addr, the one
+// of the host being pinged, if it is unknown.
+func responder(res pinger.Ping, addr net.Addr) net.Addr {
+	if res.From == nil {
+		return addr
+	}
+	return res.From
+}
+
+// formatFlow formats the flow identifier of a result, returning an empty
+// string if flow control is disabled.
+func formatFlow(flows uint, flow uint16) string {
+	if flows == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" flow=%#04x", flow)
+}
+
+// formatECN formats the ECN codepoint of a reply, returning an empty
+// string if ECN is disabled.
+func formatECN(ecn uint, codepoint pinger.ECN) string {
+	if ecn == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" ecn=%v", codepoint)
+}
+
+// formatDSCP formats the DSCP value of a reply, flagging it if it was
+// re-marked in transit. It returns an empty string if DSCP is disabled.
+func formatDSCP(dscp uint, res pinger.Ping) string {
+	if dscp == 0 {
+		return ""
+	}
+	if res.Remarked {
+		return fmt.Sprintf(" dscp=%d (re-marked from %d)", res.DSCP, dscp)
+	}
+	return fmt.Sprintf(" dscp=%d", res.DSCP)
+}
+
+// formatTTL returns the TTL of res, if known.
+func formatTTL(res pinger.Ping) string {
+	if res.TTL == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" ttl=%d", res.TTL)
+}
+
+// formatTarget returns the address a request was sent to, if rotating
+// among the addresses of the host.
+func formatTarget(rotate bool, addr net.Addr) string {
+	if !rotate {
+		return ""
+	}
+	return fmt.Sprintf(" to %v", addr)
+}
+
+// formatForeign returns a warning for replies received from an address
+// other than addr, the one of the host being pinged.
+func formatForeign(res pinger.Ping, addr net.Addr) string {
+	if !res.ForeignResponder {
+		return ""
+	}
+	return fmt.Sprintf(" (unexpected responder, pinged %v)", addr)
+}
+
+// redirectName returns the name of an ICMP Redirect code, as ping(8) does.
+func redirectName(code int) string {
+	switch code {
+	case 0:
+		return "Network"
+	case 1:
+		return "Host"
+	case 2:
+		return "Type of Service and Network"
+	case 3:
+		return "Type of Service and Host"
+	default:
+		return fmt.Sprintf("code %d", code)
+	}
+}
+
+// formatMTU returns the next-hop MTU reported by res, if any.
+func formatMTU(res pinger.Ping) string {
+	if res.NextHopMTU == 0 {
+		return ""
+	}
+	return fmt.Sprintf(": fragmentation needed, next-hop mtu=%d", res.NextHopMTU)
+}
+
+// formatOneWay returns the one-way delays estimated for res in unit, if
+// oneWay is set.
+func formatOneWay(oneWay bool, unit string, res pinger.Ping) string {
+	if !oneWay {
+		return ""
+	}
+	return fmt.Sprintf(" fwd=%s rev=%s", formatRTTs(unit, math.TimeInMillis(res.Forward)), formatRTTs(unit, math.TimeInMillis(res.Reverse)))
+}
+
+// ANSI escape sequences coloring terminal output.
+const (
+	colorWarn  = "\x1b[33m"
+	colorCrit  = "\x1b[31m"
+	colorReset = "\x1b[0m"
+)
+
+// colorize returns s in color, if enabled.
+func colorize(s string, enabled bool, color string) string {
+	if !enabled {
+		return s
+	}
+	return color + s + colorReset
+}
+
+// formatSeverity returns a marker for RTTs over the warn or crit thresholds,
+// colored if color is set.
+func formatSeverity(res pinger.Ping, color bool) string {
+	switch res.Severity {
+	case pinger.SeverityWarn:
+		return " " + colorize("(warn)", color, colorWarn)
+	case pinger.SeverityCrit:
+		return " " + colorize("(crit)", color, colorCrit)
+	default:
+		return ""
+	}
+}
+
+// isTerminal returns whether f is a terminal, e.g. for coloring output
+// written to it.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// formatAnomaly returns a marker for anomalous RTTs.
+func formatAnomaly(res pinger.Ping) string {
+	if !res.Anomalous {
+		return ""
+	}
+	return " (anomalous)"
+}
+
+// formatBandwidth returns the bottleneck bandwidth estimated for res, if
+// any.
+func formatBandwidth(res pinger.Ping) string {
+	if res.Bandwidth == 0 {
+		return ""
+	}
+	return " bw=" + bitRate(res.Bandwidth)
+}
+
+// bitRate formats bps, in bits per second, with the largest fitting unit.
+func bitRate(bps float64) string {
+	switch {
+	case bps >= 1e9:
+		return fmt.Sprintf("%.2f Gbit/s", bps/1e9)
+	case bps >= 1e6:
+		return fmt.Sprintf("%.2f Mbit/s", bps/1e6)
+	case bps >= 1e3:
+		return fmt.Sprintf("%.2f kbit/s", bps/1e3)
+	default:
+		return fmt.Sprintf("%.0f bit/s", bps)
+	}
+}
+
+// formatSeqs formats the given sequence numbers as a comma-separated list,
+// collapsing runs of consecutive ones into ranges, e.g. "3, 13, 20-24".
+func formatSeqs(seqs []int) string {
+	var parts []string
+	for i := 0; i < len(seqs); {
+		j := i
+		for j+1 < len(seqs) && seqs[j+1] == seqs[j]+1 {
+			j++
+		}
+		if j == i {
+			parts = append(parts, fmt.Sprint(seqs[i]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", seqs[i], seqs[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatWarmup returns a marker for warmup requests.
+func formatWarmup(res pinger.Ping) string {
+	if !res.Warmup {
+		return ""
+	}
+	return " (warmup)"
+}
+
+// lookupLocation looks up the location of addr in db, returning nil if db
+// is nil or nothing is known about addr.
+func lookupLocation(db *geoip.DB, addr net.Addr) *geoip.Location {
+	if db == nil {
+		return nil
+	}
+	ipAddr, err := pinger.IPAddrOf(addr)
+	if err != nil {
+		return nil
+	}
+
+	loc, err := db.Lookup(ipAddr.IP)
+	if err != nil || loc.String() == "" {
+		return nil
+	}
+	return &loc
+}
+
+// formatLocation looks up the location of addr in db, returning an empty
+// string if db is nil or nothing is known about addr.
+func formatLocation(db *geoip.DB, addr net.Addr) string {
+	loc := lookupLocation(db, addr)
+	if loc == nil {
+		return ""
+	}
+	return fmt.Sprintf(" [%v]", loc)
+}
+
+// formatASN looks up the origin AS of addr using client, returning an
+// empty string if client is nil or the lookup fails. Unless wait is true,
+// only cached results are used, and the lookup is otherwise started in
+// the background so that later results from addr include it.
+func formatASN(client *asn.Client, addr net.Addr, wait bool) string {
+	if client == nil {
+		return ""
+	}
+	ipAddr, err := pinger.IPAddrOf(addr)
+	if err != nil {
+		return ""
+	}
+
+	var info asn.Info
+	if wait {
+		if info, err = client.Lookup(ipAddr.IP); err != nil {
+			return ""
+		}
+	} else {
+		var ok bool
+		if info, ok = client.Cached(ipAddr.IP); !ok {
+			client.Prefetch(ipAddr.IP)
+			return ""
+		}
+	}
+	return fmt.Sprintf(" [%v]", info)
+}
+
+// formatExtensions formats the ICMP extension objects in ext similarly to
+// traceroute -e, returning an empty string if there are none.
+func formatExtensions(ext pinger.Extensions) string {
+	s := ""
+	for _, l := range ext.MPLSLabels {
+		s += fmt.Sprintf(" [MPLS: Lbl %d, TC %d, S %t, TTL %d]", l.Label, l.TC, l.S, l.TTL)
+	}
+	for _, i := range ext.Interfaces {
+		s += fmt.Sprintf(" [%s interface:", i.Role)
+		if i.Index != 0 {
+			s += fmt.Sprintf(" index %d", i.Index)
+		}
+		if i.Name != "" {
+			s += fmt.Sprintf(" name %s", i.Name)
+		}
+		if i.Addr != nil {
+			s += fmt.Sprintf(" addr %v", i.Addr)
+		}
+		if i.MTU != 0 {
+			s += fmt.Sprintf(" mtu %d", i.MTU)
+		}
+		s += "]"
+	}
+	return s
+}
+
+// formatBucket formats a time bucket as its start time and width,
+// followed by the number of requests answered and sent, and the loss and
+// round-trip latencies within it, in unit.
+func formatBucket(b pinger.Bucket, unit string) string {
+	if b.Received == 0 {
+		return fmt.Sprintf("%s (%v): %d/%d packets, %.1f%% loss", b.Start.Format(time.RFC3339), b.Width, b.Received, b.Transmitted, b.PacketLoss())
+	}
+	return fmt.Sprintf("%s (%v): %d/%d packets, %.1f%% loss, min/avg/max = %s", b.Start.Format(time.RFC3339), b.Width, b.Received, b.Transmitted, b.PacketLoss(),
+		formatRTTs(unit, math.TimeInMillis(b.Min), math.TimeInMillis(b.Avg()), math.TimeInMillis(b.Max)))
+}
+
+// rttScale is a unit round-trip latencies are printed in.
+type rttScale struct {
+	label string
+
+	// ms is the size of the unit in milliseconds.
+	ms float64
+
+	// digits is the number of decimal digits printed.
+	digits int
+}
+
+// rttScales are the units round-trip latencies can be printed in, by name.
+var rttScales = map[string]rttScale{
+	"us": {label: "µs", ms: 0.001, digits: 1},
+	"ms": {label: "ms", ms: 1, digits: 3},
+	"s":  {label: "s", ms: 1000, digits: 3},
+}
+
+// rttScaleOf returns the scale of unit, one of rttScales or auto. With
+// auto, the unit is picked by the largest of values, in milliseconds:
+// microseconds under a millisecond, seconds from a second on, and
+// milliseconds otherwise.
+func rttScaleOf(unit string, values ...float64) rttScale {
+	if scale, ok := rttScales[unit]; ok {
+		return scale
+	}
+
+	largest := 0.0
+	for _, v := range values {
+		if v < 0 {
+			v = -v
+		}
+		if v > largest {
+			largest = v
+		}
+	}
+	switch {
+	case largest < 1:
+		return rttScales["us"]
+	case largest >= 1000:
+		return rttScales["s"]
+	default:
+		return rttScales["ms"]
+	}
+}
+
+// formatRTTs formats values, round-trip latencies in milliseconds, in unit,
+// separated by slashes and followed by the label of the unit, e.g.
+// 87.2/91.0/120.4 µs. With auto, they are all printed in the same unit.
+func formatRTTs(unit string, values ...float64) string {
+	scale := rttScaleOf(unit, values...)
+	scaled := make([]string, len(values))
+	for i, v := range values {
+		scaled[i] = fmt.Sprintf("%.*f", scale.digits, v/scale.ms)
+	}
+	return strings.Join(scaled, "/") + " " + scale.label
+}
+
+// formatOutage formats an outage as its start and end times, followed by
+// its duration and the number of requests lost.
+func formatOutage(o pinger.Outage) string {
+	start := o.Start.Format(time.RFC3339)
+	if o.Ongoing() {
+		return fmt.Sprintf("%s - ongoing (%d packets lost)", start, o.Lost)
+	}
+	return fmt.Sprintf("%s - %s (%v, %d packets lost)", start, o.End.Format(time.RFC3339), o.Duration(), o.Lost)
+}
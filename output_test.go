@@ -0,0 +1,224 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/caiofilipini/pingo/pinger"
+)
+
+func TestFormatRRDUpdate(t *testing.T) {
+	at := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		desc        string
+		transmitted int
+		received    int
+		rtts        []time.Duration
+		expected    string
+	}{
+		{
+			desc:        "no loss",
+			transmitted: 3,
+			received:    3,
+			rtts:        []time.Duration{30 * time.Millisecond, 10 * time.Millisecond, 20 * time.Millisecond},
+			expected:    "1700000000:U:0:2.0000000000e-02:1.0000000000e-02:2.0000000000e-02:3.0000000000e-02",
+		},
+		{
+			desc:        "partial loss",
+			transmitted: 5,
+			received:    2,
+			rtts:        []time.Duration{20 * time.Millisecond, 10 * time.Millisecond},
+			expected:    "1700000000:U:3:2.0000000000e-02:U:1.0000000000e-02:2.0000000000e-02:U:U",
+		},
+		{
+			desc:        "total loss",
+			transmitted: 3,
+			received:    0,
+			expected:    "1700000000:U:3:U:U:U:U",
+		},
+		{
+			desc:        "replies without samples",
+			transmitted: 2,
+			received:    2,
+			expected:    "1700000000:U:0:U:U:U",
+		},
+		{
+			desc:     "nothing sent",
+			expected: "1700000000:U:0:U",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := formatRRDUpdate(at, tc.transmitted, tc.received, tc.rtts); got != tc.expected {
+				t.Errorf("wanted %s, got %s", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestCheckmkFormat(t *testing.T) {
+	p := &checkmkPrinter{
+		host:     "example.com",
+		warning:  checkThreshold{rta: 100 * time.Millisecond, loss: 5},
+		critical: checkThreshold{rta: 300 * time.Millisecond, loss: 20},
+	}
+
+	tests := []struct {
+		desc     string
+		res      checkResult
+		expected string
+	}{
+		{
+			desc: "OK",
+			res: checkResult{
+				received: true,
+				rtts:     pinger.RTTSummary{Min: 10 * time.Millisecond, Avg: 12500 * time.Microsecond, Max: 20 * time.Millisecond},
+			},
+			expected: `0 "PING example.com" rta=0.012500;0.100000;0.300000;0|pl=0;5;20;0;100|rtmin=0.010000|rtmax=0.020000 Packet loss = 0%, RTA = 12.50 ms`,
+		},
+		{
+			desc: "WARNING on loss",
+			res: checkResult{
+				loss:     10,
+				received: true,
+				rtts:     pinger.RTTSummary{Min: 10 * time.Millisecond, Avg: 10 * time.Millisecond, Max: 10 * time.Millisecond},
+			},
+			expected: `1 "PING example.com" rta=0.010000;0.100000;0.300000;0|pl=10;5;20;0;100|rtmin=0.010000|rtmax=0.010000 Packet loss = 10%, RTA = 10.00 ms`,
+		},
+		{
+			desc: "CRITICAL on round-trip average",
+			res: checkResult{
+				received: true,
+				rtts:     pinger.RTTSummary{Min: 400 * time.Millisecond, Avg: 500 * time.Millisecond, Max: 600 * time.Millisecond},
+			},
+			expected: `2 "PING example.com" rta=0.500000;0.100000;0.300000;0|pl=0;5;20;0;100|rtmin=0.400000|rtmax=0.600000 Packet loss = 0%, RTA = 500.00 ms`,
+		},
+		{
+			desc:     "CRITICAL without replies",
+			res:      checkResult{loss: 100},
+			expected: `2 "PING example.com" pl=100;5;20;0;100 Packet loss = 100%`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := p.format(tc.res); got != tc.expected {
+				t.Errorf("wanted %s, got %s", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestRTTScaleOf(t *testing.T) {
+	tests := []struct {
+		desc     string
+		unit     string
+		values   []float64
+		expected string
+	}{
+		{
+			desc:     "microseconds under a millisecond",
+			unit:     "auto",
+			values:   []float64{0.05, 0.087, 0.12},
+			expected: "µs",
+		},
+		{
+			desc:     "milliseconds from a millisecond on",
+			unit:     "auto",
+			values:   []float64{0.5, 1, 0.9},
+			expected: "ms",
+		},
+		{
+			desc:     "milliseconds under a second",
+			unit:     "auto",
+			values:   []float64{12.5, 999.9},
+			expected: "ms",
+		},
+		{
+			desc:     "seconds from a second on",
+			unit:     "auto",
+			values:   []float64{250, 1000},
+			expected: "s",
+		},
+		{
+			desc:     "largest value regardless of its sign",
+			unit:     "auto",
+			values:   []float64{0.2, -1500},
+			expected: "s",
+		},
+		{
+			desc:     "microseconds without values",
+			unit:     "auto",
+			expected: "µs",
+		},
+		{
+			desc:     "fixed microseconds",
+			unit:     "us",
+			values:   []float64{2500},
+			expected: "µs",
+		},
+		{
+			desc:     "fixed milliseconds",
+			unit:     "ms",
+			values:   []float64{0.01, 5000},
+			expected: "ms",
+		},
+		{
+			desc:     "fixed seconds",
+			unit:     "s",
+			values:   []float64{0.01},
+			expected: "s",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := rttScaleOf(tc.unit, tc.values...); got.label != tc.expected {
+				t.Errorf("wanted %s, got %s", tc.expected, got.label)
+			}
+		})
+	}
+}
+
+func TestFormatSeqs(t *testing.T) {
+	tests := []struct {
+		desc     string
+		seqs     []int
+		expected string
+	}{
+		{
+			desc:     "none",
+			expected: "",
+		},
+		{
+			desc:     "single",
+			seqs:     []int{7},
+			expected: "7",
+		},
+		{
+			desc:     "scattered",
+			seqs:     []int{1, 3, 5},
+			expected: "1, 3, 5",
+		},
+		{
+			desc:     "consecutive runs collapsed into ranges",
+			seqs:     []int{0, 1, 2, 5, 8, 9},
+			expected: "0-2, 5, 8-9",
+		},
+		{
+			desc:     "single run",
+			seqs:     []int{10, 11, 12, 13},
+			expected: "10-13",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := formatSeqs(tc.seqs); got != tc.expected {
+				t.Errorf("wanted %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package pinger
+
+import (
+	"time"
+
+	"github.com/caiofilipini/pingo/math"
+)
+
+const (
+	// DefaultAnomalyWindow is the default number of most recent RTTs the
+	// baseline for detecting anomalies is computed from.
+	DefaultAnomalyWindow = uint(30)
+
+	// DefaultAnomalyThreshold is the default z-score above which an RTT is
+	// considered anomalous.
+	DefaultAnomalyThreshold = 3.0
+
+	// minAnomalyBaseline is the minimum number of RTTs in the baseline
+	// before any RTT is considered anomalous.
+	minAnomalyBaseline = 10
+)
+
+// anomalyDetector flags RTTs deviating from a rolling baseline of the most
+// recent RTTs by more than a given number of standard deviations.
+type anomalyDetector struct {
+	threshold float64
+	window    int
+	baseline  []float64
+}
+
+// newAnomalyDetector returns an anomalyDetector with a baseline of the
+// given number of RTTs, flagging those with a z-score above threshold.
+func newAnomalyDetector(window uint, threshold float64) *anomalyDetector {
+	return &anomalyDetector{
+		threshold: threshold,
+		window:    int(window),
+	}
+}
+
+// anomalous returns whether rtt is anomalous, then adds it to the baseline,
+// so that a persistent shift in latency eventually becomes the new normal.
+func (d *anomalyDetector) anomalous(rtt time.Duration) bool {
+	v := math.TimeInMillis(rtt)
+
+	anomalous := false
+	if len(d.baseline) >= minAnomalyBaseline {
+		mean := math.Mean(d.baseline)
+		stddev := math.StdDev(d.baseline)
+		dev := v - mean
+		if dev < 0 {
+			dev = -dev
+		}
+		anomalous = stddev > 0 && dev/stddev > d.threshold
+	}
+
+	d.baseline = append(d.baseline, v)
+	if len(d.baseline) > d.window {
+		d.baseline = d.baseline[1:]
+	}
+	return anomalous
+}
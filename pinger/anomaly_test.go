@@ -0,0 +1,62 @@
+package pinger
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAnomalous(t *testing.T) {
+	tests := []struct {
+		desc     string
+		baseline []time.Duration
+		rtt      time.Duration
+		expected bool
+	}{
+		{
+			desc:     "not enough samples",
+			baseline: []time.Duration{10, 11, 10},
+			rtt:      100,
+			expected: false,
+		},
+		{
+			desc:     "within the baseline",
+			baseline: []time.Duration{10, 11, 10, 12, 10, 11, 10, 12, 10, 11},
+			rtt:      12,
+			expected: false,
+		},
+		{
+			desc:     "spike above the baseline",
+			baseline: []time.Duration{10, 11, 10, 12, 10, 11, 10, 12, 10, 11},
+			rtt:      50,
+			expected: true,
+		},
+		{
+			desc:     "constant baseline",
+			baseline: []time.Duration{10, 10, 10, 10, 10, 10, 10, 10, 10, 10},
+			rtt:      50,
+			expected: false,
+		},
+		{
+			desc: "spike outside the window",
+			baseline: []time.Duration{
+				500, 10, 11, 10, 12, 10, 11, 10, 12, 10, 11,
+				10, 11, 10, 12, 10, 11, 10, 12, 10, 11,
+			},
+			rtt:      50,
+			expected: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			d := newAnomalyDetector(20, DefaultAnomalyThreshold)
+			for _, rtt := range tc.baseline {
+				d.anomalous(rtt * time.Millisecond)
+			}
+
+			if a := d.anomalous(tc.rtt * time.Millisecond); a != tc.expected {
+				t.Errorf("wanted %v, got %v", tc.expected, a)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+//go:build darwin
+
+package pinger
+
+import (
+	"net"
+	"syscall"
+)
+
+// bindToDevice forces packets sent through conn out of the interface with
+// the given name, regardless of the routing policy, via IP_BOUND_IF.
+func bindToDevice(conn syscall.Conn, name string) error {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return err
+	}
+
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var serr error
+	err = raw.Control(func(fd uintptr) {
+		serr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_BOUND_IF, iface.Index)
+	})
+	if err != nil {
+		return err
+	}
+	return serr
+}
@@ -0,0 +1,25 @@
+//go:build linux
+
+package pinger
+
+import (
+	"syscall"
+)
+
+// bindToDevice forces packets sent through conn out of the interface with
+// the given name, regardless of the routing policy, via SO_BINDTODEVICE.
+func bindToDevice(conn syscall.Conn, name string) error {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var serr error
+	err = raw.Control(func(fd uintptr) {
+		serr = syscall.SetsockoptString(int(fd), syscall.SOL_SOCKET, syscall.SO_BINDTODEVICE, name)
+	})
+	if err != nil {
+		return err
+	}
+	return serr
+}
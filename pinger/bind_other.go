@@ -0,0 +1,14 @@
+//go:build !linux && !darwin
+
+package pinger
+
+import (
+	"fmt"
+	"runtime"
+	"syscall"
+)
+
+// bindToDevice is not supported outside of Linux and macOS.
+func bindToDevice(conn syscall.Conn, name string) error {
+	return fmt.Errorf("binding to an interface is not supported on %s", runtime.GOOS)
+}
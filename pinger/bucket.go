@@ -0,0 +1,137 @@
+package pinger
+
+import "time"
+
+const (
+	// DefaultBucketWidth is the default width of the time buckets
+	// requests are summarized into.
+	DefaultBucketWidth = time.Minute
+
+	// DefaultCoarseBucketWidth is the default width of the time buckets
+	// older buckets are downsampled into.
+	DefaultCoarseBucketWidth = time.Hour
+)
+
+// Bucket summarizes the requests sent within a period of time, e.g. a
+// minute, so that how the host behaved at any point of a long run can be
+// told after the fact.
+type Bucket struct {
+	// Start is when the bucket starts, aligned to its width, e.g. at the
+	// top of the minute.
+	Start time.Time
+
+	// Width is for how long the bucket lasts.
+	Width time.Duration
+
+	// Transmitted is the number of requests sent within the bucket.
+	Transmitted int
+
+	// Received is the number of those requests that were replied to.
+	Received int
+
+	// Min and Max are the minimum and maximum round-trip latencies of the
+	// replies.
+	Min time.Duration
+	Max time.Duration
+
+	// rttSum is the sum of the round-trip latencies of the replies.
+	rttSum time.Duration
+}
+
+// PacketLoss returns the percentage of the requests sent within the bucket
+// that were lost.
+func (b Bucket) PacketLoss() float64 {
+	if b.Transmitted == 0 {
+		return 0
+	}
+	return (1 - float64(b.Received)/float64(b.Transmitted)) * 100
+}
+
+// Avg returns the average round-trip latency of the replies, or zero if
+// there were none.
+func (b Bucket) Avg() time.Duration {
+	if b.Received == 0 {
+		return 0
+	}
+	return b.rttSum / time.Duration(b.Received)
+}
+
+// merge adds the requests summarized by other to the bucket.
+func (b *Bucket) merge(other Bucket) {
+	if other.Received > 0 {
+		if b.Received == 0 || other.Min < b.Min {
+			b.Min = other.Min
+		}
+		if other.Max > b.Max {
+			b.Max = other.Max
+		}
+	}
+	b.Transmitted += other.Transmitted
+	b.Received += other.Received
+	b.rttSum += other.rttSum
+}
+
+// Buckets returns the summaries of the time buckets, in chronological
+// order. At least Options.MaxSamples of the most recent buckets are kept,
+// while older ones are downsampled into at least as many coarser buckets,
+// of Options.CoarseBucketWidth, so that long-range trends remain available
+// in bounded memory. The most recent coarse bucket may overlap the oldest
+// fine ones, summarizing only the requests no longer in those. Buckets
+// within which no requests were sent are left out.
+func (s *Stats) Buckets() []Bucket {
+	return append(append([]Bucket(nil), s.coarse...), s.buckets...)
+}
+
+// recordBucket records a request sent at sentAt into its time bucket,
+// along with its rtt if it was replied to. No buckets are kept with
+// Options.DiscardSamples set.
+func (s *Stats) recordBucket(sentAt time.Time, rtt time.Duration, received bool) {
+	if s.maxSamples == 0 || s.bucketWidth <= 0 {
+		return
+	}
+
+	start := sentAt.Truncate(s.bucketWidth)
+	i := len(s.buckets) - 1
+	// Requests are mostly recorded in the order they were sent, but
+	// replies may still be outstanding when the next bucket starts.
+	for i >= 0 && s.buckets[i].Start.After(start) {
+		i--
+	}
+	if i < 0 || !s.buckets[i].Start.Equal(start) {
+		i++
+		s.buckets = append(s.buckets, Bucket{})
+		copy(s.buckets[i+1:], s.buckets[i:])
+		s.buckets[i] = Bucket{Start: start, Width: s.bucketWidth}
+	}
+
+	request := Bucket{Transmitted: 1}
+	if received {
+		request = Bucket{Transmitted: 1, Received: 1, Min: rtt, Max: rtt, rttSum: rtt}
+	}
+	s.buckets[i].merge(request)
+
+	if s.full(len(s.buckets)) {
+		from := s.recentFrom(len(s.buckets))
+		s.downsample(s.buckets[:from])
+		s.buckets = append([]Bucket(nil), s.buckets[from:]...)
+	}
+}
+
+// downsample merges buckets, the oldest ones no longer kept, into coarser
+// buckets, unless those would not be any coarser.
+func (s *Stats) downsample(buckets []Bucket) {
+	if s.coarseWidth <= s.bucketWidth {
+		return
+	}
+
+	for _, b := range buckets {
+		start := b.Start.Truncate(s.coarseWidth)
+		if n := len(s.coarse); n == 0 || !s.coarse[n-1].Start.Equal(start) {
+			s.coarse = append(s.coarse, Bucket{Start: start, Width: s.coarseWidth})
+		}
+		s.coarse[len(s.coarse)-1].merge(b)
+	}
+	if s.full(len(s.coarse)) {
+		s.coarse = append([]Bucket(nil), s.coarse[s.recentFrom(len(s.coarse)):]...)
+	}
+}
@@ -0,0 +1,81 @@
+package pinger
+
+import (
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+// Category classifies the outcome of a request, so that consumers can tell
+// replies apart from errors without inspecting the other fields of Ping.
+type Category int
+
+const (
+	// Reply means the host replied.
+	Reply Category = iota
+
+	// ForeignReply means a reply was received from an address other than
+	// the one of the host, see Ping.ForeignResponder.
+	ForeignReply
+
+	// GroupReply means a member of the multicast group or broadcast
+	// domain being pinged replied.
+	GroupReply
+
+	// NoReply means the request timed out.
+	NoReply
+
+	// TTLExceeded means a hop along the path reported that the TTL of the
+	// request was exceeded.
+	TTLExceeded
+
+	// DestinationUnreachable means the host was reported unreachable.
+	DestinationUnreachable
+)
+
+// String returns a human readable name for the category.
+func (c Category) String() string {
+	switch c {
+	case Reply:
+		return "reply"
+	case ForeignReply:
+		return "foreign reply"
+	case GroupReply:
+		return "group reply"
+	case NoReply:
+		return "no reply"
+	case TTLExceeded:
+		return "ttl exceeded"
+	case DestinationUnreachable:
+		return "destination unreachable"
+	default:
+		return "unknown"
+	}
+}
+
+// categorize returns the category of ping, a response to a request sent to
+// a multicast group or broadcast address if group is set.
+func categorize(ping Ping, group bool) Category {
+	switch {
+	case ping.Timeout:
+		return NoReply
+	case ping.TimeExceeded:
+		return TTLExceeded
+	case ping.Unreachable:
+		return DestinationUnreachable
+	case group:
+		return GroupReply
+	case ping.ForeignResponder:
+		return ForeignReply
+	default:
+		return Reply
+	}
+}
+
+// typeAndCode returns the ICMP type and code of res.
+func typeAndCode(res *icmp.Message) (int, int) {
+	typ, ok := res.Type.(ipv4.ICMPType)
+	if !ok {
+		return 0, res.Code
+	}
+	return int(typ), res.Code
+}
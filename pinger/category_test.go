@@ -0,0 +1,63 @@
+package pinger
+
+import (
+	"net"
+	"testing"
+)
+
+func TestCategorize(t *testing.T) {
+	from := &net.IPAddr{IP: net.IPv4(192, 168, 0, 1)}
+
+	tests := []struct {
+		desc     string
+		ping     Ping
+		group    bool
+		expected Category
+	}{
+		{
+			desc:     "echo reply",
+			ping:     Ping{From: from},
+			expected: Reply,
+		},
+		{
+			desc:     "echo reply from a foreign responder",
+			ping:     Ping{From: from, ForeignResponder: true},
+			expected: ForeignReply,
+		},
+		{
+			desc:     "echo reply from a group member",
+			ping:     Ping{From: from},
+			group:    true,
+			expected: GroupReply,
+		},
+		{
+			desc:     "timeout",
+			ping:     Ping{Timeout: true},
+			expected: NoReply,
+		},
+		{
+			desc:     "timeout pinging a group",
+			ping:     Ping{Timeout: true},
+			group:    true,
+			expected: NoReply,
+		},
+		{
+			desc:     "time exceeded",
+			ping:     Ping{From: from, TimeExceeded: true},
+			expected: TTLExceeded,
+		},
+		{
+			desc:     "destination unreachable",
+			ping:     Ping{From: from, Unreachable: true},
+			expected: DestinationUnreachable,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			if c := categorize(tc.ping, tc.group); c != tc.expected {
+				t.Errorf("wanted %v, got %v", tc.expected, c)
+			}
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package pinger
+
+import (
+	"encoding/hex"
+	"fmt"
+)
+
+// debugf logs a formatted message to Options.Debug, if set.
+func (p *pinger) debugf(format string, args ...interface{}) {
+	if p.opts.Debug == nil {
+		return
+	}
+	fmt.Fprintf(p.opts.Debug, format+"\n", args...)
+}
+
+// dump logs a formatted message to Options.Debug, if set, followed by a
+// hex dump of b.
+func (p *pinger) dump(b []byte, format string, args ...interface{}) {
+	if p.opts.Debug == nil {
+		return
+	}
+	p.debugf(format, args...)
+	fmt.Fprint(p.opts.Debug, hex.Dump(b))
+}
@@ -0,0 +1,15 @@
+//go:build !linux && !darwin
+
+package pinger
+
+import (
+	"fmt"
+	"net"
+	"runtime"
+	"syscall"
+)
+
+// listenDatagram is not supported outside of Linux and macOS.
+func listenDatagram(src net.IP, control func(string, string, syscall.RawConn) error) (*net.UDPConn, error) {
+	return nil, fmt.Errorf("datagram ICMP sockets are not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,51 @@
+//go:build linux || darwin
+
+package pinger
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+)
+
+// listenDatagram opens an unprivileged datagram ICMP socket bound to src,
+// or to any address if src is nil. If control is not nil, it is called
+// with the socket before binding it, with the udp4 network.
+func listenDatagram(src net.IP, control func(string, string, syscall.RawConn) error) (*net.UDPConn, error) {
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM, syscall.IPPROTO_ICMP)
+	if err != nil {
+		return nil, err
+	}
+	f := os.NewFile(uintptr(fd), "icmp")
+	defer f.Close()
+
+	sa := &syscall.SockaddrInet4{}
+	if src != nil {
+		copy(sa.Addr[:], src.To4())
+	}
+	if control != nil {
+		raw, err := f.SyscallConn()
+		if err != nil {
+			return nil, err
+		}
+		addr := &net.UDPAddr{IP: net.IP(sa.Addr[:])}
+		if err := control("udp4", addr.String(), raw); err != nil {
+			return nil, err
+		}
+	}
+	if err := syscall.Bind(fd, sa); err != nil {
+		return nil, err
+	}
+
+	conn, err := net.FilePacketConn(f)
+	if err != nil {
+		return nil, err
+	}
+	udpConn, ok := conn.(*net.UDPConn)
+	if !ok {
+		conn.Close()
+		return nil, fmt.Errorf("unexpected connection type %T", conn)
+	}
+	return udpConn, nil
+}
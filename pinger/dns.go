@@ -0,0 +1,183 @@
+package pinger
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	// dnsTimeout is the timeout of lookups through encrypted resolvers.
+	dnsTimeout = 5 * time.Second
+
+	// dotPort is the default port of DNS over TLS resolvers.
+	dotPort = "853"
+
+	// dnsMessageType is the media type of DNS messages sent over HTTPS.
+	dnsMessageType = "application/dns-message"
+
+	// maxDNSMessageLen is the maximum length of DNS messages.
+	maxDNSMessageLen = 0xffff
+)
+
+// dohResolver resolves hosts with DNS over HTTPS.
+type dohResolver struct {
+	url    string
+	client *http.Client
+}
+
+// NewDoHResolver returns a Resolver looking up the IPv4 addresses of hosts
+// with DNS over HTTPS (RFC 8484) through the resolver at url, e.g.
+// https://1.1.1.1/dns-query.
+func NewDoHResolver(url string) Resolver {
+	return &dohResolver{
+		url:    url,
+		client: &http.Client{Timeout: dnsTimeout},
+	}
+}
+
+func (r *dohResolver) Resolve(ctx context.Context, host string) ([]net.Addr, error) {
+	return lookup(host, func(query []byte) ([]byte, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.url, bytes.NewReader(query))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", dnsMessageType)
+		req.Header.Set("Accept", dnsMessageType)
+
+		res, err := r.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("resolver answered %s", res.Status)
+		}
+		return io.ReadAll(io.LimitReader(res.Body, maxDNSMessageLen))
+	})
+}
+
+// dotResolver resolves hosts with DNS over TLS.
+type dotResolver struct {
+	addr   string
+	config *tls.Config
+}
+
+// NewDoTResolver returns a Resolver looking up the IPv4 addresses of hosts
+// with DNS over TLS (RFC 7858) through the resolver at addr, e.g. 1.1.1.1
+// or dns.example.com:853, whose certificate is verified against its host.
+func NewDoTResolver(addr string) Resolver {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, dotPort)
+	}
+	host, _, _ := net.SplitHostPort(addr)
+	return &dotResolver{
+		addr:   addr,
+		config: &tls.Config{ServerName: host},
+	}
+}
+
+func (r *dotResolver) Resolve(ctx context.Context, host string) ([]net.Addr, error) {
+	return lookup(host, func(query []byte) ([]byte, error) {
+		ctx, cancel := context.WithTimeout(ctx, dnsTimeout)
+		defer cancel()
+
+		dialer := &tls.Dialer{Config: r.config}
+		conn, err := dialer.DialContext(ctx, "tcp", r.addr)
+		if err != nil {
+			return nil, err
+		}
+		defer conn.Close()
+		if deadline, ok := ctx.Deadline(); ok {
+			conn.SetDeadline(deadline)
+		}
+		return exchangeStream(conn, query)
+	})
+}
+
+// exchangeStream sends query through the stream conn and reads the
+// response, both prefixed with their length as over TCP (RFC 1035,
+// section 4.2.2).
+func exchangeStream(conn io.ReadWriter, query []byte) ([]byte, error) {
+	msg := make([]byte, 2+len(query))
+	binary.BigEndian.PutUint16(msg, uint16(len(query)))
+	copy(msg[2:], query)
+	if _, err := conn.Write(msg); err != nil {
+		return nil, err
+	}
+
+	var n uint16
+	if err := binary.Read(conn, binary.BigEndian, &n); err != nil {
+		return nil, err
+	}
+	res := make([]byte, n)
+	if _, err := io.ReadFull(conn, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// lookup looks up the IPv4 addresses of host, sending the query for its A
+// records through exchange, which returns the response. IP addresses are
+// returned as they are, without any lookup.
+func lookup(host string, exchange func(query []byte) ([]byte, error)) ([]net.Addr, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		if ip.To4() == nil {
+			return nil, fmt.Errorf("no IPv4 address found for %s", host)
+		}
+		return []net.Addr{&net.IPAddr{IP: ip.To4()}}, nil
+	}
+
+	name, err := dnsmessage.NewName(strings.TrimSuffix(host, ".") + ".")
+	if err != nil {
+		return nil, fmt.Errorf("invalid host name %q: %v", host, err)
+	}
+	id := uint16(rand.Intn(0x10000))
+	query, err := (&dnsmessage.Message{
+		Header: dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{
+			{Name: name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET},
+		},
+	}).Pack()
+	if err != nil {
+		return nil, fmt.Errorf("cannot encode query for %s: %v", host, err)
+	}
+
+	b, err := exchange(query)
+	if err != nil {
+		return nil, fmt.Errorf("cannot look up %s: %v", host, err)
+	}
+	var res dnsmessage.Message
+	if err := res.Unpack(b); err != nil {
+		return nil, fmt.Errorf("cannot decode response for %s: %v", host, err)
+	}
+	if !res.Response || res.ID != id {
+		return nil, fmt.Errorf("cannot look up %s: unexpected response", host)
+	}
+	if res.RCode != dnsmessage.RCodeSuccess {
+		return nil, fmt.Errorf("cannot look up %s: %v", host, res.RCode)
+	}
+
+	// Answers may include the CNAME records leading to the addresses,
+	// which are of no interest.
+	var addrs []net.Addr
+	for _, rr := range res.Answers {
+		if a, ok := rr.Body.(*dnsmessage.AResource); ok {
+			addrs = append(addrs, &net.IPAddr{IP: net.IP(a.A[:])})
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no IPv4 address found for %s", host)
+	}
+	return addrs, nil
+}
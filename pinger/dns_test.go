@@ -0,0 +1,125 @@
+package pinger
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// answer returns the response to query, answering with the A records of
+// ips, or with rcode if there are none.
+func answer(t *testing.T, query []byte, rcode dnsmessage.RCode, ips ...net.IP) []byte {
+	var msg dnsmessage.Message
+	if err := msg.Unpack(query); err != nil {
+		t.Fatalf("cannot decode query: %v", err)
+	}
+	msg.Response = true
+	msg.RCode = rcode
+	for _, ip := range ips {
+		var a [4]byte
+		copy(a[:], ip.To4())
+		msg.Answers = append(msg.Answers, dnsmessage.Resource{
+			Header: dnsmessage.ResourceHeader{Name: msg.Questions[0].Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET},
+			Body:   &dnsmessage.AResource{A: a},
+		})
+	}
+	b, err := msg.Pack()
+	if err != nil {
+		t.Fatalf("cannot encode response: %v", err)
+	}
+	return b
+}
+
+func TestDoHResolver(t *testing.T) {
+	tests := []struct {
+		desc     string
+		host     string
+		rcode    dnsmessage.RCode
+		ips      []net.IP
+		expected []net.Addr
+		ok       bool
+	}{
+		{
+			desc:     "resolves a name",
+			host:     "example.com",
+			ips:      []net.IP{net.IPv4(10, 0, 0, 1), net.IPv4(10, 0, 0, 2)},
+			expected: []net.Addr{&net.IPAddr{IP: net.IPv4(10, 0, 0, 1).To4()}, &net.IPAddr{IP: net.IPv4(10, 0, 0, 2).To4()}},
+			ok:       true,
+		},
+		{
+			desc:     "returns IP addresses as they are",
+			host:     "192.0.2.1",
+			expected: []net.Addr{&net.IPAddr{IP: net.IPv4(192, 0, 2, 1).To4()}},
+			ok:       true,
+		},
+		{
+			desc:  "fails for unknown names",
+			host:  "unknown.example.com",
+			rcode: dnsmessage.RCodeNameError,
+			ok:    false,
+		},
+		{
+			desc: "fails for names without addresses",
+			host: "example.com",
+			ok:   false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Content-Type") != dnsMessageType {
+					http.Error(w, "unsupported media type", http.StatusUnsupportedMediaType)
+					return
+				}
+				query, _ := io.ReadAll(r.Body)
+				w.Header().Set("Content-Type", dnsMessageType)
+				w.Write(answer(t, query, tc.rcode, tc.ips...))
+			}))
+			defer srv.Close()
+
+			addrs, err := NewDoHResolver(srv.URL).Resolve(context.Background(), tc.host)
+			if (err == nil) != tc.ok {
+				t.Fatalf("wanted %v, got %v (%v)", tc.ok, err == nil, err)
+			}
+			if tc.ok && !reflect.DeepEqual(addrs, tc.expected) {
+				t.Errorf("wanted %v, got %v", tc.expected, addrs)
+			}
+		})
+	}
+}
+
+func TestExchangeStream(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+
+	go func() {
+		defer server.Close()
+		var n [2]byte
+		if _, err := io.ReadFull(server, n[:]); err != nil {
+			return
+		}
+		query := make([]byte, int(n[0])<<8|int(n[1]))
+		if _, err := io.ReadFull(server, query); err != nil {
+			return
+		}
+		res := answer(t, query, dnsmessage.RCodeSuccess, net.IPv4(10, 0, 0, 1))
+		server.Write(append([]byte{byte(len(res) >> 8), byte(len(res))}, res...))
+	}()
+
+	addrs, err := lookup("example.com", func(query []byte) ([]byte, error) {
+		return exchangeStream(client, query)
+	})
+	if err != nil {
+		t.Fatalf("cannot look up: %v", err)
+	}
+	if expected := []net.Addr{&net.IPAddr{IP: net.IPv4(10, 0, 0, 1).To4()}}; !reflect.DeepEqual(addrs, expected) {
+		t.Errorf("wanted %v, got %v", expected, addrs)
+	}
+}
@@ -0,0 +1,75 @@
+package pinger
+
+import (
+	"bytes"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+// replyWindowSize is the number of most recent requests tracked for
+// detecting duplicate and late replies.
+const replyWindowSize = 1024
+
+// echoHeaderLen is the length of the header of ICMP echo messages.
+const echoHeaderLen = 8
+
+// replyWindow tracks which of the most recent requests were replied to,
+// telling duplicate replies apart from late ones.
+type replyWindow struct {
+	// seqs holds, for each slot, the sequence number of the request
+	// replied to plus one, so that the zero value means no reply.
+	seqs [replyWindowSize]int
+}
+
+// add records a reply to the request identified by seq.
+func (w *replyWindow) add(seq int) {
+	w.seqs[seq%replyWindowSize] = seq + 1
+}
+
+// replied returns whether the request identified by seq was replied to.
+func (w *replyWindow) replied(seq int) bool {
+	return w.seqs[seq%replyWindowSize] == seq+1
+}
+
+// earlierReply returns the logical sequence number of b if it is an echo
+// reply to one of the most recent requests of the pinger sent before seq,
+// which is either a duplicate or a late reply.
+func (p *pinger) earlierReply(b []byte, seq int) (int, bool) {
+	res, err := icmp.ParseMessage(ipv4Proto, b)
+	if err != nil || res.Type != ipv4.ICMPTypeEchoReply {
+		return 0, false
+	}
+	pkt, ok := res.Body.(*icmp.Echo)
+	if !ok || pkt.ID != p.id {
+		return 0, false
+	}
+	earlier := logicalSeq(pkt.Seq, seq)
+	if earlier == seq || earlier < 0 || seq-earlier > replyWindowSize {
+		return 0, false
+	}
+	return earlier, true
+}
+
+// intactPayload returns whether data, the payload of an echo reply to the
+// request of pktSize bytes sent at sentAt, is the one sent.
+func (p *pinger) intactPayload(data []byte, pktSize int, sentAt time.Time) bool {
+	if len(data) != pktSize-echoHeaderLen {
+		return false
+	}
+	// Both requests of a packet pair share the sequence number, so the
+	// timestamp may be that of the second one.
+	if !p.opts.PacketPair && !bytes.Equal(data[:timeByteSize], timeToBytes(sentAt)) {
+		return false
+	}
+	for i := timeByteSize; i < len(data); i++ {
+		if p.opts.Flows != 0 && i < timeByteSize+flowByteSize {
+			continue
+		}
+		if data[i] != 1 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,167 @@
+package pinger
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+func TestEarlierReply(t *testing.T) {
+	p := &pinger{id: 42}
+
+	reply := func(id int, seq int) []byte {
+		b, err := (&icmp.Message{
+			Type: ipv4.ICMPTypeEchoReply,
+			Body: &icmp.Echo{ID: id, Seq: seq, Data: []byte("pingo")},
+		}).Marshal(nil)
+		if err != nil {
+			t.Fatalf("cannot create packet: %v", err)
+		}
+		return b
+	}
+
+	tests := []struct {
+		desc     string
+		b        []byte
+		seq      int
+		expected int
+		ok       bool
+	}{
+		{
+			desc:     "reply to an earlier request",
+			b:        reply(42, 1),
+			seq:      4,
+			expected: 1,
+			ok:       true,
+		},
+		{
+			desc: "reply to the current request",
+			b:    reply(42, 4),
+			seq:  4,
+			ok:   false,
+		},
+		{
+			desc: "reply to another process",
+			b:    reply(7, 1),
+			seq:  4,
+			ok:   false,
+		},
+		{
+			desc: "reply to a request out of the window",
+			b:    reply(42, 3),
+			seq:  3 + replyWindowSize + 1,
+			ok:   false,
+		},
+		{
+			desc:     "reply to a request before the wraparound",
+			b:        reply(42, seqSpace-2),
+			seq:      seqSpace + 1,
+			expected: seqSpace - 2,
+			ok:       true,
+		},
+		{
+			desc:     "reply to an earlier request after the wraparound",
+			b:        reply(42, 1),
+			seq:      2*seqSpace + 4,
+			expected: 2*seqSpace + 1,
+			ok:       true,
+		},
+		{
+			desc: "reply to the current request after the wraparound",
+			b:    reply(42, 4),
+			seq:  seqSpace + 4,
+			ok:   false,
+		},
+		{
+			desc: "reply to a request not sent yet",
+			b:    reply(42, 7),
+			seq:  4,
+			ok:   false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			seq, ok := p.earlierReply(tc.b, tc.seq)
+			if ok != tc.ok {
+				t.Fatalf("wanted %v, got %v", tc.ok, ok)
+			}
+			if ok && seq != tc.expected {
+				t.Errorf("wanted %v, got %v", tc.expected, seq)
+			}
+		})
+	}
+}
+
+func TestReplyWindow(t *testing.T) {
+	var w replyWindow
+	w.add(1)
+	w.add(3 + replyWindowSize)
+
+	if !w.replied(1) {
+		t.Errorf("wanted icmp_seq 1 to be replied to")
+	}
+	if w.replied(2) {
+		t.Errorf("wanted icmp_seq 2 not to be replied to")
+	}
+	if w.replied(3) {
+		t.Errorf("wanted icmp_seq 3 to be evicted from the window")
+	}
+}
+
+func TestIntactPayload(t *testing.T) {
+	sentAt := time.Unix(1500000000, 0)
+	pkt, err := createPacket(42, 3, int(DefaultPacketSize), sentAt)
+	if err != nil {
+		t.Fatalf("cannot create packet: %v", err)
+	}
+	data := func(fn func(b []byte) []byte) []byte {
+		b := append([]byte(nil), pkt[echoHeaderLen:]...)
+		return fn(b)
+	}
+
+	tests := []struct {
+		desc     string
+		opts     Options
+		data     []byte
+		expected bool
+	}{
+		{
+			desc:     "intact payload",
+			data:     data(func(b []byte) []byte { return b }),
+			expected: true,
+		},
+		{
+			desc:     "truncated payload",
+			data:     data(func(b []byte) []byte { return b[:len(b)-1] }),
+			expected: false,
+		},
+		{
+			desc:     "altered trailing byte",
+			data:     data(func(b []byte) []byte { b[len(b)-1] = 0; return b }),
+			expected: false,
+		},
+		{
+			desc:     "altered timestamp",
+			data:     data(func(b []byte) []byte { b[0]++; return b }),
+			expected: false,
+		},
+		{
+			desc:     "bytes rewritten to keep the flow",
+			opts:     Options{Flows: 2},
+			data:     data(func(b []byte) []byte { b[timeByteSize] = 0xbe; return b }),
+			expected: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			p := &pinger{opts: &tc.opts}
+			if intact := p.intactPayload(tc.data, len(pkt), sentAt); intact != tc.expected {
+				t.Errorf("wanted %v, got %v", tc.expected, intact)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package pinger
+
+// ECN is an Explicit Congestion Notification codepoint, carried in the two
+// least significant bits of the IP TOS byte (RFC 3168).
+type ECN int
+
+const (
+	// NotECT marks packets of transports that are not ECN-capable.
+	NotECT ECN = iota
+
+	// ECT1 is the ECN-Capable Transport (1) codepoint.
+	ECT1
+
+	// ECT0 is the ECN-Capable Transport (0) codepoint.
+	ECT0
+
+	// CE is the Congestion Experienced codepoint, set by routers
+	// instead of dropping packets.
+	CE
+)
+
+// ecnMask is the mask of the ECN bits in the TOS byte.
+const ecnMask = 0x03
+
+// Capable returns whether e is one of the ECN-Capable Transport
+// codepoints, the only ones outgoing packets may be marked with.
+func (e ECN) Capable() bool {
+	return e == ECT0 || e == ECT1
+}
+
+// String returns the conventional name for the codepoint.
+func (e ECN) String() string {
+	switch e {
+	case NotECT:
+		return "Not-ECT"
+	case ECT1:
+		return "ECT(1)"
+	case ECT0:
+		return "ECT(0)"
+	case CE:
+		return "CE"
+	default:
+		return "unknown"
+	}
+}
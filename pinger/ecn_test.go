@@ -0,0 +1,45 @@
+package pinger
+
+import "testing"
+
+func TestECNCapable(t *testing.T) {
+	tests := []struct {
+		desc     string
+		ecn      ECN
+		expected bool
+	}{
+		{
+			desc:     "Not-ECT",
+			ecn:      NotECT,
+			expected: false,
+		},
+		{
+			desc:     "ECT(1)",
+			ecn:      ECT1,
+			expected: true,
+		},
+		{
+			desc:     "ECT(0)",
+			ecn:      ECT0,
+			expected: true,
+		},
+		{
+			desc:     "CE",
+			ecn:      CE,
+			expected: false,
+		},
+		{
+			desc:     "out of range",
+			ecn:      7,
+			expected: false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			if actual := tc.ecn.Capable(); actual != tc.expected {
+				t.Errorf("wanted %v, got %v", tc.expected, actual)
+			}
+		})
+	}
+}
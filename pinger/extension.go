@@ -0,0 +1,120 @@
+package pinger
+
+import (
+	"net"
+
+	"golang.org/x/net/icmp"
+)
+
+// InterfaceRole identifies the role of the interface described by an
+// InterfaceInfo extension object (RFC 5837).
+type InterfaceRole int
+
+const (
+	// IncomingInterface is the interface upon which the datagram arrived.
+	IncomingInterface InterfaceRole = iota
+
+	// SubIPInterface is the sub-IP component of the incoming interface.
+	SubIPInterface
+
+	// OutgoingInterface is the interface through which the datagram
+	// would have been forwarded.
+	OutgoingInterface
+
+	// NextHopInterface is the IP next hop to which the datagram would
+	// have been forwarded.
+	NextHopInterface
+)
+
+// String returns a human readable name for the role.
+func (r InterfaceRole) String() string {
+	switch r {
+	case IncomingInterface:
+		return "incoming"
+	case SubIPInterface:
+		return "sub-ip"
+	case OutgoingInterface:
+		return "outgoing"
+	case NextHopInterface:
+		return "next-hop"
+	default:
+		return "unknown"
+	}
+}
+
+// Extensions holds the ICMP extension objects (RFC 4884) carried by
+// Time Exceeded and Destination Unreachable responses.
+type Extensions struct {
+	// MPLSLabels is the incoming MPLS label stack (RFC 4950).
+	MPLSLabels []MPLSLabel
+
+	// Interfaces holds the interface information objects (RFC 5837).
+	Interfaces []InterfaceInfo
+}
+
+// MPLSLabel represents an MPLS label stack entry.
+type MPLSLabel struct {
+	// Label is the label value.
+	Label int
+
+	// TC is the traffic class (formerly known as EXP).
+	TC int
+
+	// S is whether this entry is the bottom of the stack.
+	S bool
+
+	// TTL is the MPLS time to live.
+	TTL int
+}
+
+// InterfaceInfo describes an interface of the router that generated the
+// ICMP error message. Fields not included by the router are left zeroed.
+type InterfaceInfo struct {
+	// Role is the role of the interface.
+	Role InterfaceRole
+
+	// Index is the ifIndex of the interface.
+	Index int
+
+	// Name is the name of the interface.
+	Name string
+
+	// MTU is the MTU of the interface.
+	MTU int
+
+	// Addr is the IP address of the interface.
+	Addr net.IP
+}
+
+// parseExtensions converts the extensions parsed by the icmp package
+// into Extensions, ignoring objects of unknown classes.
+func parseExtensions(exts []icmp.Extension) Extensions {
+	var e Extensions
+	for _, ext := range exts {
+		switch ext := ext.(type) {
+		case *icmp.MPLSLabelStack:
+			for _, l := range ext.Labels {
+				e.MPLSLabels = append(e.MPLSLabels, MPLSLabel{
+					Label: l.Label,
+					TC:    l.TC,
+					S:     l.S,
+					TTL:   l.TTL,
+				})
+			}
+		case *icmp.InterfaceInfo:
+			info := InterfaceInfo{
+				Role: InterfaceRole(ext.Type >> 6),
+			}
+			if ext.Interface != nil {
+				info.Index = ext.Interface.Index
+				info.Name = ext.Interface.Name
+				info.MTU = ext.Interface.MTU
+			}
+			if ext.Addr != nil {
+				info.Addr = ext.Addr.IP
+			}
+			e.Interfaces = append(e.Interfaces, info)
+		}
+	}
+	return e
+}
@@ -0,0 +1,53 @@
+package pinger
+
+import "encoding/binary"
+
+const (
+	// flowOffset is the offset in the ICMP message of the two bytes used
+	// to keep the checksum constant: right after the header and the
+	// timestamp in the payload.
+	flowOffset = 8 + timeByteSize
+
+	// flowByteSize is the number of payload bytes needed for keeping the
+	// checksum constant.
+	flowByteSize = 2
+)
+
+// flowFor returns the flow identifier to be used for the request
+// identified by seq, cycling through opts.Flows consecutive identifiers
+// starting at opts.FlowID.
+func flowFor(opts *Options, seq int) uint16 {
+	return opts.FlowID + uint16(seq%int(opts.Flows))
+}
+
+// setFlow rewrites the marshaled ICMP message pkt so that its checksum
+// equals flow, compensating for the change in the payload bytes at
+// flowOffset. Per-flow load balancers hash the first bytes of the ICMP
+// header (including the checksum), so requests sharing a flow follow the
+// same path, as in Paris traceroute.
+func setFlow(pkt []byte, flow uint16) {
+	sum := binary.BigEndian.Uint16(pkt[2:4])
+	old := binary.BigEndian.Uint16(pkt[flowOffset:])
+	binary.BigEndian.PutUint16(pkt[flowOffset:], onesAdd(onesAdd(sum, old), ^flow))
+	binary.BigEndian.PutUint16(pkt[2:4], flow)
+}
+
+// onesAdd adds a and b using one's complement arithmetic.
+func onesAdd(a, b uint16) uint16 {
+	s := uint32(a) + uint32(b)
+	return uint16(s&0xffff + s>>16)
+}
+
+// validChecksum returns whether the one's complement sum of b, including
+// its checksum field, is all ones, i.e. whether the ICMP message b was
+// received intact.
+func validChecksum(b []byte) bool {
+	var sum uint16
+	for i := 0; i+1 < len(b); i += 2 {
+		sum = onesAdd(sum, binary.BigEndian.Uint16(b[i:]))
+	}
+	if len(b)%2 == 1 {
+		sum = onesAdd(sum, uint16(b[len(b)-1])<<8)
+	}
+	return sum == 0xffff
+}
@@ -0,0 +1,47 @@
+package pinger
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+func TestSetFlow(t *testing.T) {
+	opts := &Options{Flows: 3, FlowID: 0xbeef}
+	now := time.Unix(1500000000, 0)
+
+	for seq := 0; seq < 6; seq++ {
+		pkt, err := createPacket(42, seq, int(DefaultPacketSize), now.Add(time.Duration(seq)*time.Second))
+		if err != nil {
+			t.Fatalf("cannot create packet: %v", err)
+		}
+
+		flow := flowFor(opts, seq)
+		setFlow(pkt, flow)
+
+		if expected := 0xbeef + uint16(seq%3); flow != expected {
+			t.Errorf("wanted flow %#x for icmp_seq %d, got %#x", expected, seq, flow)
+		}
+		if sum := binary.BigEndian.Uint16(pkt[2:4]); sum != flow {
+			t.Errorf("wanted checksum %#x for icmp_seq %d, got %#x", flow, seq, sum)
+		}
+		if !validChecksum(pkt) {
+			t.Errorf("wanted a valid checksum for icmp_seq %d", seq)
+		}
+	}
+}
+
+func TestValidChecksum(t *testing.T) {
+	pkt, err := createPacket(42, 3, int(DefaultPacketSize), time.Unix(1500000000, 0))
+	if err != nil {
+		t.Fatalf("cannot create packet: %v", err)
+	}
+	if !validChecksum(pkt) {
+		t.Errorf("wanted a valid checksum for an intact packet")
+	}
+
+	pkt[len(pkt)-1] ^= 0xff
+	if validChecksum(pkt) {
+		t.Errorf("wanted an invalid checksum for a corrupted packet")
+	}
+}
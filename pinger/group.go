@@ -0,0 +1,83 @@
+package pinger
+
+import (
+	"net"
+
+	"golang.org/x/net/ipv4"
+)
+
+// isGroup returns whether addr is a multicast group or, if broadcast is
+// enabled, a broadcast address, whose members may all answer the same
+// request.
+func (p *pinger) isGroup(addr net.Addr) bool {
+	if p.opts.Broadcast {
+		return true
+	}
+	ipAddr, err := IPAddrOf(addr)
+	return err == nil && ipAddr.IP.IsMulticast()
+}
+
+// Responders returns the number of echo replies received from each
+// responder, keyed by their addresses, which is mostly useful when pinging
+// multicast groups or broadcast addresses.
+func (s *Stats) Responders() map[string]int {
+	responders := make(map[string]int, len(s.responders))
+	for addr, n := range s.responders {
+		responders[addr] = n
+	}
+	return responders
+}
+
+// recordResponder counts an echo reply received from addr.
+func (s *Stats) recordResponder(addr net.Addr) {
+	if s.responders == nil {
+		s.responders = make(map[string]int)
+	}
+	s.responders[addr.String()]++
+}
+
+// collect reads the replies to the request seq from further members of a
+// group, until the request times out. Only the first reply to a request,
+// read by recv, is accounted for in the stats.
+func (p *pinger) collect(conn *icmpConn, seq int, pktSize int) []Ping {
+	buf := readBuffer(pktSize)
+	oob := make([]byte, oobBufferSize)
+
+	var pings []Ping
+	for {
+		n, oobn, peer, err := conn.readMsg(buf, oob)
+		if err != nil {
+			return pings
+		}
+
+		resBytes := stripIPv4Header(buf[:n])
+		p.dump(resBytes, "received %d bytes from %v:", len(resBytes), peer)
+		if !validChecksum(resBytes) {
+			p.debugf("rejected: invalid checksum")
+			p.updateStats(func(s *Stats) {
+				s.checksumErrors++
+			})
+			continue
+		}
+		res, pkt, err := p.parse(seq, resBytes)
+		if err != nil {
+			p.debugf("rejected: %v", err)
+			continue
+		}
+		if res.Type != ipv4.ICMPTypeEchoReply {
+			p.debugf("rejected: not an echo reply: %v", res.Type)
+			continue
+		}
+
+		typ, code := typeAndCode(res)
+		pings = append(pings, Ping{
+			Seq:        seq,
+			Size:       len(resBytes),
+			RTT:        p.clock.Now().Sub(bytesToTime(pkt.Data[:timeByteSize])),
+			From:       peer,
+			Type:       typ,
+			Code:       code,
+			ReceivedAt: p.receivedAt(oob[:oobn]),
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package pinger
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+)
+
+const (
+	// DefaultHistogramDigits is the default number of significant value
+	// digits of the RTT histogram.
+	DefaultHistogramDigits = uint(3)
+
+	// histogramMin is the lowest RTT discernible by the histogram.
+	histogramMin = time.Microsecond
+
+	// histogramMax is the highest RTT trackable by the histogram.
+	histogramMax = time.Hour
+
+	// histogramLogVersion is the version of the HdrHistogram log format
+	// written by WriteHistogramLog.
+	histogramLogVersion = "1.3"
+)
+
+// newHistogram returns a new HDR histogram for recording RTTs in
+// nanoseconds with the given number of significant value digits (1-5).
+func newHistogram(digits uint) *hdrhistogram.Histogram {
+	return hdrhistogram.New(int64(histogramMin), int64(histogramMax), int(digits))
+}
+
+// Histogram returns a copy of the HDR histogram RTTs are recorded into,
+// in nanoseconds.
+func (s *Stats) Histogram() *hdrhistogram.Histogram {
+	return hdrhistogram.Import(s.hist.Export())
+}
+
+// histogramSince returns a histogram of the values recorded into h after
+// prev, a previous copy of it.
+func histogramSince(h *hdrhistogram.Histogram, prev *hdrhistogram.Histogram) *hdrhistogram.Histogram {
+	snapshot := h.Export()
+	for i, n := range prev.Export().Counts {
+		snapshot.Counts[i] -= n
+	}
+	return hdrhistogram.Import(snapshot)
+}
+
+// WriteHistogramLog writes the RTT histogram to w in the HdrHistogram log
+// format, as a single interval covering the whole run, so that it can be
+// merged and analyzed with the existing HdrHistogram tooling. The interval
+// is tagged with tag, unless it is empty. Values are logged in nanoseconds,
+// with the interval max in milliseconds as per the format's convention.
+func (s *Stats) WriteHistogramLog(w io.Writer, tag string) error {
+	payload, err := s.hist.Encode(hdrhistogram.V2CompressedEncodingCookieBase)
+	if err != nil {
+		return fmt.Errorf("cannot encode histogram: %v", err)
+	}
+
+	if tag != "" {
+		tag = fmt.Sprintf("Tag=%s,", tag)
+	}
+	start := s.startedAt.UnixNano() / int64(time.Millisecond)
+	_, err = fmt.Fprintf(w,
+		"#[Histogram log format version %s]\n"+
+			"#[StartTime: %.3f (seconds since epoch), %s]\n"+
+			"\"StartTimestamp\",\"Interval_Length\",\"Interval_Max\",\"Interval_Compressed_Histogram\"\n"+
+			"%s%.3f,%.3f,%.3f,%s\n",
+		histogramLogVersion,
+		float64(start)/1000, s.startedAt.Format(time.RFC3339),
+		tag, 0.0, s.stoppedAt.Sub(s.startedAt).Seconds(), float64(s.hist.Max())/float64(time.Millisecond), payload,
+	)
+	return err
+}
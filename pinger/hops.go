@@ -0,0 +1,20 @@
+package pinger
+
+// initialTTLs are the initial TTLs commonly used by operating systems,
+// e.g. 64 by Linux and macOS, 128 by Windows and 255 by network devices.
+var initialTTLs = []int{32, 64, 128, 255}
+
+// EstimatedHops estimates the number of hops the echo reply went through,
+// assuming it was sent with the smallest common initial TTL not below
+// the one it arrived with. It returns -1 if the TTL is unknown.
+func (p Ping) EstimatedHops() int {
+	if p.TTL <= 0 {
+		return -1
+	}
+	for _, initial := range initialTTLs {
+		if p.TTL <= initial {
+			return initial - p.TTL
+		}
+	}
+	return -1
+}
@@ -0,0 +1,45 @@
+package pinger
+
+import "testing"
+
+func TestEstimatedHops(t *testing.T) {
+	tests := []struct {
+		desc     string
+		ttl      int
+		expected int
+	}{
+		{
+			desc:     "unknown TTL",
+			ttl:      0,
+			expected: -1,
+		},
+		{
+			desc:     "initial TTL of 64",
+			ttl:      57,
+			expected: 7,
+		},
+		{
+			desc:     "initial TTL of 128",
+			ttl:      116,
+			expected: 12,
+		},
+		{
+			desc:     "initial TTL of 255",
+			ttl:      255,
+			expected: 0,
+		},
+		{
+			desc:     "initial TTL of 32",
+			ttl:      30,
+			expected: 2,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			if hops := (Ping{TTL: tc.ttl}).EstimatedHops(); hops != tc.expected {
+				t.Errorf("wanted %d, got %d", tc.expected, hops)
+			}
+		})
+	}
+}
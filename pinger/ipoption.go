@@ -0,0 +1,57 @@
+package pinger
+
+import "fmt"
+
+const (
+	// OptionEOL marks the end of the IPv4 options list.
+	OptionEOL = byte(0)
+
+	// OptionNOP is a no-operation option, used for padding.
+	OptionNOP = byte(1)
+
+	// OptionRecordRoute asks routers to record their addresses.
+	OptionRecordRoute = byte(7)
+
+	// OptionTimestamp asks routers to record timestamps.
+	OptionTimestamp = byte(68)
+
+	// maxIPOptionsLen is the maximum length of the IPv4 options.
+	maxIPOptionsLen = 40
+)
+
+// IPOption is an option (RFC 791) to be included in the IPv4 header of
+// outgoing packets.
+type IPOption struct {
+	// Type is the option type, including the copied flag and class.
+	Type byte
+
+	// Data is the option data, following the type and length octets. It
+	// must be empty for OptionEOL and OptionNOP, which have neither.
+	Data []byte
+}
+
+// marshalIPOptions encodes opts followed by raw, the bytes of further
+// options already encoded, padding them to a multiple of 4 bytes.
+func marshalIPOptions(opts []IPOption, raw []byte) ([]byte, error) {
+	var b []byte
+	for _, o := range opts {
+		if o.Type == OptionEOL || o.Type == OptionNOP {
+			b = append(b, o.Type)
+			continue
+		}
+		if len(o.Data) > maxIPOptionsLen-2 {
+			return nil, fmt.Errorf("option %d too long: %d bytes", o.Type, len(o.Data))
+		}
+		b = append(b, o.Type, byte(len(o.Data)+2))
+		b = append(b, o.Data...)
+	}
+	b = append(b, raw...)
+
+	for len(b)%4 != 0 {
+		b = append(b, OptionEOL)
+	}
+	if len(b) > maxIPOptionsLen {
+		return nil, fmt.Errorf("options too long: %d bytes, max %d", len(b), maxIPOptionsLen)
+	}
+	return b, nil
+}
@@ -0,0 +1,60 @@
+package pinger
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMarshalIPOptions(t *testing.T) {
+	tests := []struct {
+		desc     string
+		opts     []IPOption
+		raw      []byte
+		expected []byte
+		wantErr  bool
+	}{
+		{
+			desc:     "encodes nothing without options",
+			expected: nil,
+		},
+		{
+			desc:     "encodes type and length, padding to 4 bytes",
+			opts:     []IPOption{{Type: OptionRecordRoute, Data: []byte{4, 0, 0, 0, 0}}},
+			expected: []byte{7, 7, 4, 0, 0, 0, 0, 0},
+		},
+		{
+			desc:     "encodes single-byte options without length",
+			opts:     []IPOption{{Type: OptionNOP}},
+			expected: []byte{1, 0, 0, 0},
+		},
+		{
+			desc:     "appends raw options",
+			opts:     []IPOption{{Type: OptionNOP}},
+			raw:      []byte{0x94, 0x04, 0x00, 0x00},
+			expected: []byte{1, 0x94, 0x04, 0x00, 0x00, 0, 0, 0},
+		},
+		{
+			desc:    "rejects options longer than 40 bytes",
+			raw:     make([]byte, 41),
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			b, err := marshalIPOptions(tc.opts, tc.raw)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("wanted an error, got %v", b)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("wanted no error, got %v", err)
+			}
+			if !bytes.Equal(b, tc.expected) {
+				t.Errorf("wanted %v, got %v", tc.expected, b)
+			}
+		})
+	}
+}
@@ -0,0 +1,262 @@
+package pinger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+const (
+	// loadSweepInterval is the interval between sweeps of the requests
+	// outstanding for longer than the timeout during a load run.
+	loadSweepInterval = 100 * time.Millisecond
+
+	// loadRateTolerance is the fraction of the target rate below which
+	// the sender is considered the bottleneck of a load run.
+	loadRateTolerance = 0.95
+
+	// loadReadBuffer is the size requested for the receive buffer of the
+	// socket of a load run.
+	loadReadBuffer = 4 << 20
+)
+
+// LoadReport summarizes a run sending requests at a fixed rate.
+type LoadReport struct {
+	// Rate is the target rate, in requests per second.
+	Rate float64
+
+	// Achieved is the rate requests were actually sent at.
+	Achieved float64
+
+	// Elapsed is the time spent sending requests.
+	Elapsed time.Duration
+
+	// SendErrors is the number of requests that could not be sent, e.g.
+	// because the socket buffer was full.
+	SendErrors int
+
+	// MaxLag is the longest a request was sent behind its schedule.
+	MaxLag time.Duration
+
+	// Stats holds the stats of the requests sent to each target, in the
+	// order of the targets.
+	Stats []Stats
+}
+
+// SenderLimited returns whether the sender itself was the bottleneck of
+// the run, unable to send requests at the target rate, so that the loss
+// and latencies measured do not reflect the network at that rate.
+func (r *LoadReport) SenderLimited() bool {
+	return r.SendErrors > 0 || r.Achieved < r.Rate*loadRateTolerance
+}
+
+// loadRequest is a request outstanding during a load run.
+type loadRequest struct {
+	target int
+	sentAt time.Time
+}
+
+// loadTracker matches replies to the requests outstanding during a load
+// run, by their 16-bit sequence numbers, recording their outcome in the
+// stats of their targets. It is safe for concurrent use.
+type loadTracker struct {
+	mu          sync.Mutex
+	timeout     time.Duration
+	outstanding map[int]loadRequest
+	stats       []*Stats
+}
+
+// newLoadTracker returns a loadTracker recording into stats, one per
+// target, and considering requests lost after timeout.
+func newLoadTracker(stats []*Stats, timeout time.Duration) *loadTracker {
+	return &loadTracker{
+		timeout:     timeout,
+		outstanding: make(map[int]loadRequest),
+		stats:       stats,
+	}
+}
+
+// sent records request seq as sent to target at sentAt. Should seq still
+// be outstanding after wrapping around, the earlier request is lost.
+func (t *loadTracker) sent(seq int, target int, sentAt time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if req, ok := t.outstanding[seq]; ok {
+		t.stats[req.target].incTimeout(req.sentAt)
+	}
+	t.outstanding[seq] = loadRequest{target: target, sentAt: sentAt}
+}
+
+// unsent forgets request seq, which could not be sent after all.
+func (t *loadTracker) unsent(seq int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.outstanding, seq)
+}
+
+// replied records the reply to request seq, received at receivedAt,
+// returning whether it was outstanding.
+func (t *loadTracker) replied(seq int, receivedAt time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	req, ok := t.outstanding[seq]
+	if !ok {
+		return false
+	}
+	delete(t.outstanding, seq)
+	rtt := receivedAt.Sub(req.sentAt)
+	if rtt > t.timeout {
+		t.stats[req.target].incTimeout(req.sentAt)
+		return true
+	}
+	t.stats[req.target].incSuccess(rtt, req.sentAt)
+	return true
+}
+
+// sweep records the requests outstanding for longer than the timeout at
+// now as lost.
+func (t *loadTracker) sweep(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for seq, req := range t.outstanding {
+		if now.Sub(req.sentAt) > t.timeout {
+			t.stats[req.target].incTimeout(req.sentAt)
+			delete(t.outstanding, seq)
+		}
+	}
+}
+
+// Load sends ICMP echo requests to addrs in turn at a fixed rate, in
+// requests per second, for duration or until ctx is done, without waiting
+// for the replies to previous requests, e.g. for stress testing network
+// gear. Replies are awaited for up to opts.Timeout after the last request
+// is sent. Only opts.PacketSize, opts.Timeout, opts.Source, opts.Control,
+// opts.Unprivileged and the options shaping the stats are taken into
+// account.
+func Load(ctx context.Context, addrs []net.Addr, rate float64, duration time.Duration, opts Options) (*LoadReport, error) {
+	if len(addrs) == 0 || rate <= 0 || duration <= 0 {
+		return nil, errors.New("load runs need targets, a rate and a duration")
+	}
+	opts.TWAMPPort = 0
+	opts.TCPPort = 0
+	p := NewPinger(&opts).(*pinger)
+
+	conn, err := p.listen()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	// Replies pile up at high rates, and those overflowing the socket
+	// buffer would be counted as lost in the network.
+	if buffered, ok := conn.socket.(interface{ SetReadBuffer(int) error }); ok {
+		buffered.SetReadBuffer(loadReadBuffer)
+	}
+
+	stats := make([]*Stats, len(addrs))
+	for i := range stats {
+		stats[i] = newStats(opts.HistogramDigits, opts.OutageThreshold, opts.maxSamples(), opts.BucketWidth, opts.CoarseBucketWidth)
+	}
+	tracker := newLoadTracker(stats, opts.Timeout)
+
+	// Replies are as long as the requests.
+	probe, err := createPacket(p.id, 0, int(opts.PacketSize), time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("cannot encode packet: %v", err)
+	}
+	received := make(chan struct{})
+	go func() {
+		defer close(received)
+		p.receiveLoad(conn, tracker, len(probe))
+	}()
+
+	report := &LoadReport{Rate: rate}
+	interval := time.Duration(float64(time.Second) / rate)
+	start := time.Now()
+	for i := 0; ctx.Err() == nil; i++ {
+		due := start.Add(time.Duration(i) * interval)
+		if due.Sub(start) >= duration {
+			break
+		}
+		if d := time.Until(due); d > 0 {
+			time.Sleep(d)
+		}
+
+		now := time.Now()
+		if lag := now.Sub(due); lag > report.MaxLag {
+			report.MaxLag = lag
+		}
+		seq := wireSeq(i)
+		target := i % len(addrs)
+		pkt, err := createPacket(p.id, seq, int(opts.PacketSize), now)
+		if err != nil {
+			return nil, fmt.Errorf("cannot encode packet: %v", err)
+		}
+		tracker.sent(seq, target, now)
+		if err := conn.writeTo(pkt, addrs[target]); err != nil {
+			tracker.unsent(seq)
+			report.SendErrors++
+		}
+	}
+	report.Elapsed = time.Since(start)
+
+	// The replies to the last requests are awaited before the requests
+	// still outstanding are deemed lost.
+	select {
+	case <-ctx.Done():
+	case <-time.After(opts.Timeout):
+	}
+	conn.Close()
+	<-received
+	tracker.sweep(time.Now().Add(opts.Timeout + 1))
+
+	sent := 0
+	report.Stats = make([]Stats, len(stats))
+	for i, s := range stats {
+		s.startedAt, s.stoppedAt = start, start.Add(report.Elapsed)
+		report.Stats[i] = s.snapshot()
+		sent += s.Transmitted()
+	}
+	if report.Elapsed > 0 {
+		report.Achieved = float64(sent) / report.Elapsed.Seconds()
+	}
+	return report, nil
+}
+
+// receiveLoad reads the replies to the requests of a load run until conn
+// is closed, recording them with tracker, along with the requests that
+// time out in the meantime.
+func (p *pinger) receiveLoad(conn *icmpConn, tracker *loadTracker, pktSize int) {
+	buf := readBuffer(pktSize)
+	lastSweep := time.Now()
+	for {
+		conn.SetReadDeadline(time.Now().Add(loadSweepInterval))
+		n, _, err := conn.ReadFrom(buf)
+		now := time.Now()
+		if now.Sub(lastSweep) >= loadSweepInterval {
+			tracker.sweep(now)
+			lastSweep = now
+		}
+		if err != nil {
+			if neterr, ok := err.(net.Error); ok && neterr.Timeout() {
+				continue
+			}
+			return
+		}
+
+		res, err := icmp.ParseMessage(ipv4Proto, stripIPv4Header(buf[:n]))
+		if err != nil || res.Type != ipv4.ICMPTypeEchoReply {
+			continue
+		}
+		// Datagram sockets only receive replies to their own requests,
+		// while raw ones receive every reply to the host.
+		if echo, ok := res.Body.(*icmp.Echo); ok && (conn.method == DatagramICMP || echo.ID == p.id) {
+			tracker.replied(echo.Seq, now)
+		}
+	}
+}
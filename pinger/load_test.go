@@ -0,0 +1,118 @@
+package pinger
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoadTracker(t *testing.T) {
+	stats := []*Stats{
+		newStats(DefaultHistogramDigits, DefaultOutageThreshold, DefaultMaxSamples, DefaultBucketWidth, DefaultCoarseBucketWidth),
+		newStats(DefaultHistogramDigits, DefaultOutageThreshold, DefaultMaxSamples, DefaultBucketWidth, DefaultCoarseBucketWidth),
+	}
+	tracker := newLoadTracker(stats, time.Second)
+	start := time.Now()
+
+	tracker.sent(0, 0, start)
+	tracker.sent(1, 1, start)
+	tracker.sent(2, 0, start)
+	tracker.sent(3, 1, start)
+	tracker.unsent(3)
+
+	if !tracker.replied(0, start.Add(10*time.Millisecond)) {
+		t.Error("wanted the reply to seq 0 to be matched")
+	}
+	if tracker.replied(0, start.Add(20*time.Millisecond)) {
+		t.Error("wanted a duplicate reply to seq 0 not to be matched")
+	}
+	if tracker.replied(3, start.Add(10*time.Millisecond)) {
+		t.Error("wanted a reply to unsent seq 3 not to be matched")
+	}
+	// A reply arriving after the timeout is lost all the same.
+	tracker.replied(1, start.Add(2*time.Second))
+
+	tracker.sweep(start.Add(500 * time.Millisecond))
+	if stats[0].Transmitted() != 1 {
+		t.Errorf("wanted seq 2 to be outstanding before the timeout, got %d requests", stats[0].Transmitted())
+	}
+	tracker.sweep(start.Add(2 * time.Second))
+
+	tests := []struct {
+		desc        string
+		stats       *Stats
+		transmitted int
+		received    int
+	}{
+		{
+			desc:        "first target",
+			stats:       stats[0],
+			transmitted: 2,
+			received:    1,
+		},
+		{
+			desc:        "second target",
+			stats:       stats[1],
+			transmitted: 1,
+			received:    0,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			if tc.stats.Transmitted() != tc.transmitted || tc.stats.Received() != tc.received {
+				t.Errorf("wanted %d/%d, got %d/%d", tc.received, tc.transmitted, tc.stats.Received(), tc.stats.Transmitted())
+			}
+		})
+	}
+	if rtts := stats[0].RTTs(); len(rtts) != 1 || rtts[0] != 10*time.Millisecond {
+		t.Errorf("wanted RTTs [10ms], got %v", rtts)
+	}
+}
+
+func TestLoadTrackerWraparound(t *testing.T) {
+	stats := []*Stats{newStats(DefaultHistogramDigits, DefaultOutageThreshold, DefaultMaxSamples, DefaultBucketWidth, DefaultCoarseBucketWidth)}
+	tracker := newLoadTracker(stats, time.Second)
+	start := time.Now()
+
+	tracker.sent(5, 0, start)
+	tracker.sent(5, 0, start.Add(time.Millisecond))
+	tracker.replied(5, start.Add(3*time.Millisecond))
+
+	if stats[0].Transmitted() != 2 || stats[0].Received() != 1 {
+		t.Errorf("wanted 1/2, got %d/%d", stats[0].Received(), stats[0].Transmitted())
+	}
+	if rtts := stats[0].RTTs(); len(rtts) != 1 || rtts[0] != 2*time.Millisecond {
+		t.Errorf("wanted the reply matched to the latest request, got %v", rtts)
+	}
+}
+
+func TestSenderLimited(t *testing.T) {
+	tests := []struct {
+		desc     string
+		report   LoadReport
+		expected bool
+	}{
+		{
+			desc:     "not limited at the target rate",
+			report:   LoadReport{Rate: 5000, Achieved: 4990},
+			expected: false,
+		},
+		{
+			desc:     "limited under the target rate",
+			report:   LoadReport{Rate: 5000, Achieved: 3000},
+			expected: true,
+		},
+		{
+			desc:     "limited by send errors",
+			report:   LoadReport{Rate: 5000, Achieved: 5000, SendErrors: 3},
+			expected: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			if actual := tc.report.SenderLimited(); actual != tc.expected {
+				t.Errorf("wanted %v, got %v", tc.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package pinger
+
+import "time"
+
+// LossBursts returns, respectively, the number of bursts of consecutive lost
+// packets, and the max and mean number of packets lost per burst.
+func (s *Stats) LossBursts() (int, int, float64) {
+	bursts, max, sum := s.burstCount, s.burstMax, s.burstSum
+	if s.lossRun > 0 {
+		bursts++
+		sum += s.lossRun
+		if s.lossRun > max {
+			max = s.lossRun
+		}
+	}
+	if bursts == 0 {
+		return 0, 0, 0
+	}
+	return bursts, max, float64(sum) / float64(bursts)
+}
+
+// Burstiness estimates how bursty the packet loss is according to the
+// Gilbert-Elliott model, as 1/(p+r), where p is the probability of losing a
+// packet after a packet was received, and r is the probability of receiving
+// a packet after a packet was lost. Random (i.e. independent) loss yields
+// values around 1, while greater values mean losses are concentrated in
+// bursts. It returns zero if no packets were lost or received.
+func (s *Stats) Burstiness() float64 {
+	bursts, _, _ := s.LossBursts()
+	lost := s.totalCount - s.successCount
+	if bursts == 0 || s.successCount == 0 {
+		return 0
+	}
+
+	p := float64(bursts) / float64(s.successCount)
+	r := float64(s.burstCount) / float64(lost)
+	if p+r == 0 {
+		return 0
+	}
+	return 1 / (p + r)
+}
+
+// recordLoss extends the ongoing burst of lost packets with a request
+// sent at the given time.
+func (s *Stats) recordLoss(sentAt time.Time) {
+	if s.lossRun == 0 {
+		s.lossStart = sentAt
+	}
+	s.lossRun++
+}
+
+// recordReceived ends the ongoing burst of lost packets, if any, recording
+// it as an outage if it was long enough.
+func (s *Stats) recordReceived(sentAt time.Time) {
+	if s.lossRun == 0 {
+		return
+	}
+
+	if s.inOutage() {
+		s.recordOutage(Outage{
+			Start: s.lossStart,
+			End:   sentAt,
+			Lost:  s.lossRun,
+		})
+	}
+	s.burstCount++
+	s.burstSum += s.lossRun
+	if s.lossRun > s.burstMax {
+		s.burstMax = s.lossRun
+	}
+	if s.maxSamples > 0 {
+		s.bursts = append(s.bursts, s.lossRun)
+		if s.full(len(s.bursts)) {
+			s.bursts = append([]int(nil), s.bursts[s.recentFrom(len(s.bursts)):]...)
+		}
+	}
+	s.lossRun = 0
+}
+
+// burstsSince records into since the bursts completed after prev and the
+// size of the ongoing one, discounting the packets prev already accounted
+// for. If not every burst completed after prev was kept, the max burst is
+// bounded by the packets lost in them, rather than exact.
+func (s *Stats) burstsSince(prev Stats, since *Stats) {
+	count := s.burstCount - prev.burstCount
+	bursts := append([]int(nil), s.bursts[lastFrom(len(s.bursts), count):]...)
+	sum := s.burstSum - prev.burstSum
+	lossRun := s.lossRun
+
+	if prev.lossRun > 0 {
+		if count > 0 {
+			sum -= prev.lossRun
+			if len(bursts) == count {
+				bursts[0] -= prev.lossRun
+				if bursts[0] == 0 {
+					bursts = bursts[1:]
+					count--
+				}
+			}
+		} else {
+			lossRun -= prev.lossRun
+		}
+	}
+
+	since.bursts, since.burstCount, since.burstSum, since.lossRun = bursts, count, sum, lossRun
+	for _, b := range bursts {
+		if b > since.burstMax {
+			since.burstMax = b
+		}
+	}
+	if len(bursts) < count {
+		since.burstMax = s.burstMax
+		if sum < since.burstMax {
+			since.burstMax = sum
+		}
+	}
+}
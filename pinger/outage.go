@@ -0,0 +1,109 @@
+package pinger
+
+import "time"
+
+// DefaultOutageThreshold is the default number of consecutive requests
+// that must be lost for the host to be considered unreachable.
+const DefaultOutageThreshold = uint(3)
+
+// Outage represents a period during which the host was unreachable, i.e.
+// at least Options.OutageThreshold consecutive requests were lost.
+type Outage struct {
+	// Start is when the first lost request was sent.
+	Start time.Time
+
+	// End is when the first request answered after the outage was sent,
+	// or zero if the outage is still ongoing.
+	End time.Time
+
+	// Lost is the number of requests lost during the outage.
+	Lost int
+}
+
+// Ongoing returns whether the host is still unreachable.
+func (o Outage) Ongoing() bool {
+	return o.End.IsZero()
+}
+
+// Duration returns for how long the host was unreachable, or zero if the
+// outage is still ongoing.
+func (o Outage) Duration() time.Duration {
+	if o.Ongoing() {
+		return 0
+	}
+	return o.End.Sub(o.Start)
+}
+
+// Outages returns the most recent outages detected so far, including the
+// ongoing one, if any, in chronological order. Up to Options.MaxSamples of
+// them are kept, or Options.FlapThreshold if more, for detecting flapping.
+func (s *Stats) Outages() []Outage {
+	outages := s.outages[:len(s.outages):len(s.outages)]
+	if s.inOutage() {
+		outages = append(outages, Outage{
+			Start: s.lossStart,
+			Lost:  s.lossRun,
+		})
+	}
+	return outages
+}
+
+// OutageCount returns the number of outages detected so far, including the
+// ongoing one, if any, even if only the most recent are kept.
+func (s *Stats) OutageCount() int {
+	if s.inOutage() {
+		return s.outageCount + 1
+	}
+	return s.outageCount
+}
+
+// LongestOutage returns the longest of the outages that ended so far, if
+// any, even if it is no longer kept.
+func (s *Stats) LongestOutage() (Outage, bool) {
+	return s.longestOutage, s.outageCount > 0
+}
+
+// recordOutage records an outage that ended, keeping only the most recent
+// ones, amortizing their trimming as for other samples.
+func (s *Stats) recordOutage(o Outage) {
+	s.outageCount++
+	if s.outageCount == 1 || o.Duration() > s.longestOutage.Duration() {
+		s.longestOutage = o
+	}
+
+	kept := s.maxSamples
+	if s.minOutages > kept {
+		kept = s.minOutages
+	}
+	if kept == 0 {
+		return
+	}
+	s.outages = append(s.outages, o)
+	if len(s.outages) >= 2*kept {
+		s.outages = append([]Outage(nil), s.outages[lastFrom(len(s.outages), kept):]...)
+	}
+}
+
+// outagesSince records into since the longest of the outages that ended
+// after prev. If it is no longer kept, and neither is the longest of the
+// whole run, it is the longest of those kept instead.
+func (s *Stats) outagesSince(prev Stats, since *Stats) {
+	if since.outageCount == 0 {
+		return
+	}
+	if s.longestOutage != prev.longestOutage {
+		since.longestOutage = s.longestOutage
+		return
+	}
+	for i, o := range since.outages {
+		if i == 0 || o.Duration() > since.longestOutage.Duration() {
+			since.longestOutage = o
+		}
+	}
+}
+
+// inOutage returns whether the ongoing burst of lost packets is long
+// enough to be considered an outage.
+func (s *Stats) inOutage() bool {
+	return s.lossRun > 0 && s.lossRun >= s.outageThreshold
+}
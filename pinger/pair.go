@@ -0,0 +1,67 @@
+package pinger
+
+import (
+	"time"
+
+	"golang.org/x/net/ipv4"
+)
+
+// measurePair estimates the bottleneck bandwidth out of the time between
+// the arrival of the replies to the requests of the packet pair of ping.
+func (p *pinger) measurePair(conn *icmpConn, ping *Ping, pktSize int) {
+	second, ok := p.recvPair(conn, ping.Seq, pktSize)
+	if !ok {
+		return
+	}
+	if bw, ok := pairBandwidth(ping.Size, ping.ReceivedAt, second); ok {
+		ping.Bandwidth = bw
+		p.updateStats(func(s *Stats) {
+			s.bandwidths.Add(bw)
+		})
+	}
+}
+
+// recvPair waits for the reply to the second request of the packet pair
+// seq, returning when it arrived. Unlike recv, it does not record anything
+// in the stats, as the pair counts as a single request.
+func (p *pinger) recvPair(conn *icmpConn, seq int, pktSize int) (time.Time, bool) {
+	buf := readBuffer(pktSize)
+	oob := make([]byte, oobBufferSize)
+
+	for {
+		n, oobn, peer, err := conn.readMsg(buf, oob)
+		if err != nil {
+			return time.Time{}, false
+		}
+		arrival := p.receivedAt(oob[:oobn])
+
+		resBytes := stripIPv4Header(buf[:n])
+		p.dump(resBytes, "received %d bytes from %v:", len(resBytes), peer)
+		if !validChecksum(resBytes) {
+			p.debugf("rejected: invalid checksum")
+			continue
+		}
+		res, _, err := p.parse(seq, resBytes)
+		if err != nil {
+			p.debugf("rejected: %v", err)
+			continue
+		}
+		if res.Type != ipv4.ICMPTypeEchoReply {
+			p.debugf("rejected: not an echo reply: %v", res.Type)
+			continue
+		}
+		return arrival, true
+	}
+}
+
+// pairBandwidth estimates the bottleneck bandwidth, in bits per second,
+// out of the spacing between the replies to a packet pair, each carrying
+// size bytes of ICMP: the bottleneck link spreads back-to-back packets
+// apart by the time it takes to transmit one of them.
+func pairBandwidth(size int, first time.Time, second time.Time) (float64, bool) {
+	gap := second.Sub(first)
+	if gap <= 0 {
+		return 0, false
+	}
+	return float64((size+ipv4.HeaderLen)*8) / gap.Seconds(), true
+}
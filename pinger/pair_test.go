@@ -0,0 +1,48 @@
+package pinger
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPairBandwidth(t *testing.T) {
+	first := time.Unix(1500000000, 0)
+
+	tests := []struct {
+		desc     string
+		size     int
+		gap      time.Duration
+		expected float64
+		ok       bool
+	}{
+		{
+			desc:     "100 Mbit/s bottleneck",
+			size:     1480,
+			gap:      120 * time.Microsecond,
+			expected: 100e6,
+			ok:       true,
+		},
+		{
+			desc:     "1 Gbit/s bottleneck",
+			size:     980,
+			gap:      8 * time.Microsecond,
+			expected: 1e9,
+			ok:       true,
+		},
+		{
+			desc: "replies reordered",
+			size: 1480,
+			gap:  -time.Microsecond,
+			ok:   false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			bw, ok := pairBandwidth(tc.size, first, first.Add(tc.gap))
+			if ok != tc.ok || bw != tc.expected {
+				t.Errorf("wanted %v (%v), got %v (%v)", tc.expected, tc.ok, bw, ok)
+			}
+		})
+	}
+}
@@ -1,9 +1,13 @@
 package pinger
 
 import (
+	"encoding/binary"
 	"fmt"
+	"io"
 	"math/rand"
 	"net"
+	"sync"
+	"syscall"
 	"time"
 
 	"golang.org/x/net/icmp"
@@ -27,6 +31,23 @@ const (
 	// timeByteSize is the number of bytes used to represent the timestamp
 	// in the payload.
 	timeByteSize = 8
+
+	// maxIPv4HeaderLen is the maximum length of an IPv4 header, including
+	// options.
+	maxIPv4HeaderLen = 60
+
+	// oobBufferSize is the size of the buffer used for reading socket
+	// control messages.
+	oobBufferSize = 128
+
+	// fragmentationNeeded is the code of Destination Unreachable messages
+	// reporting that a packet needs fragmentation, but DF is set.
+	fragmentationNeeded = 4
+
+	// readBufferSize is the minimum size of the buffer used for reading
+	// responses, large enough to fit ICMP error messages quoting the
+	// original datagram and carrying extension objects.
+	readBufferSize = 1500
 )
 
 func init() {
@@ -40,9 +61,18 @@ type Pinger interface {
 	Ping(addr net.Addr)
 
 	// Stop signals the Pinger to stop sending ping requests to the host.
-	// After a call to Stop(), Ping() is expected to return.
+	// After a call to Stop(), Ping() is expected to return, once the reply
+	// to the request in flight, if any, is received or times out, so that
+	// it is still counted. Stop may be called more than once.
 	Stop()
 
+	// StopWait stops the Pinger like Stop(), but forcibly terminates the
+	// request in flight if Ping() has not returned within timeout, e.g.
+	// because the network interface disappeared. It returns whether Ping()
+	// returned gracefully; if not, Ping() returns shortly after, without
+	// reporting any error.
+	StopWait(timeout time.Duration) bool
+
 	// Report returns the pair of channels where results will be reported to:
 	// 1) a channel of type Ping for successful requests (including temporary errors, e.g. timeouts)
 	// 2) a channel of type error for unrecoverable errors
@@ -51,6 +81,11 @@ type Pinger interface {
 	// Stats returns the packet statistics accumulated for the host being
 	// pinged.
 	Stats() Stats
+
+	// Recent returns the most recent results reported, up to
+	// Options.RecentResults of them, oldest first, so that consumers
+	// attaching after the run started can still show recent history.
+	Recent() []Ping
 }
 
 // Options defines the options for a Pinger.
@@ -59,14 +94,224 @@ type Options struct {
 	// The default timeout is 1 second.
 	Timeout time.Duration
 
+	// PacketPair enables the experimental estimation of the bottleneck
+	// bandwidth towards the host: each request is sent as a pair of
+	// back-to-back echo requests, whose replies are spread apart by the
+	// bottleneck link. Estimates are more accurate with larger packets,
+	// and are only made for ICMP requests to unicast hosts.
+	PacketPair bool
+
+	// Warmup sets the number of initial requests whose results are
+	// reported, but excluded from the stats, as they are often skewed by
+	// e.g. ARP resolution and cold caches along the path. They are sent
+	// in addition to Count.
+	Warmup uint
+
+	// Linger sets how long to wait for the reply to the final request of
+	// a run with a Count of requests, if longer than Timeout, so that it
+	// is not lost on slow links.
+	// The default is 0, which means Timeout is used.
+	Linger time.Duration
+
 	// Count sets the number of packets to be sent/received.
 	// The default count is 0, which means ping requests will be sent
 	// indefinitely.
 	Count uint
 
+	// CountReplies sets whether Count is the number of replies to be
+	// received, rather than the number of requests to be sent, e.g. for
+	// collecting a given number of samples regardless of losses.
+	CountReplies bool
+
+	// Successes sets the number of consecutive replies after which Ping
+	// stops, e.g. for gating on a host being reachable without letting a
+	// flapping one through. Replies to warmup requests do not count.
+	// The default is 0, which means Ping does not stop on replies.
+	Successes uint
+
+	// SuccessRTT sets the RTT replies must be under to count towards
+	// Successes; slower ones break the run of replies like losses do.
+	// The default is 0, which means replies count regardless of their RTT.
+	SuccessRTT time.Duration
+
+	// RecentResults sets the number of most recent results kept for
+	// Recent.
+	// The default is 0, which means no results are kept.
+	RecentResults uint
+
 	// PacketSize sets the size of packets to be sent/received.
 	// The default packet size is 56 bytes.
 	PacketSize uint
+
+	// Source sets the source address of outgoing packets.
+	// The default is nil, which means the system picks one.
+	Source net.IP
+
+	// Interface sets the name of the network interface outgoing packets
+	// are sent through, regardless of the routing policy. Unlike Source,
+	// it forces the egress interface even with policy routing in play.
+	// The default is empty, which means the routing table decides.
+	Interface string
+
+	// Control is called with the socket requests are sent through when
+	// it is created, before it is bound, e.g. for setting socket options
+	// pingo does not wrap, such as SO_MARK, like net.Dialer.Control. The
+	// network is ip4 for raw ICMP sockets, udp4 for datagram ICMP and
+	// TWAMP Light sockets, and tcp4 for each connection of TCP probing.
+	// An error returned fails opening the socket.
+	// The default is nil, which means sockets are used as created.
+	Control func(network, address string, c syscall.RawConn) error
+
+	// Resolver resolves host names to the addresses to ping, e.g. for
+	// caching lookups, overriding the hosts file or faking them in tests.
+	// The default is nil, which means the system resolver is used.
+	Resolver Resolver
+
+	// Rotate sets further addresses of the host, e.g. all the ones its
+	// name resolves to, for requests to be sent to in turn, following the
+	// address given to Ping, approximating what clients experience with
+	// DNS round-robin.
+	// The default is nil, which means every request is sent to the
+	// address given to Ping.
+	Rotate []net.Addr
+
+	// IPOptions sets the options included in the IPv4 header of outgoing
+	// packets.
+	IPOptions []IPOption
+
+	// RawIPOptions sets further options included in the IPv4 header of
+	// outgoing packets, already encoded, for shapes IPOption can't
+	// express. They follow IPOptions, and are padded to a multiple of
+	// 4 bytes, up to 40 bytes in total.
+	RawIPOptions []byte
+
+	// TWAMPPort sets the UDP port of a TWAMP Light reflector running on
+	// the host to send test packets to, instead of ICMP echo requests.
+	// Along with round-trips, one-way delays are then estimated out of the
+	// timestamps of the reflected packets, assuming synchronized clocks.
+	TWAMPPort uint
+
+	// OneWay sets whether to send ICMP Timestamp requests instead of echo
+	// requests, estimating the forward and reverse one-way delays out of
+	// the timestamps of the replies. Those estimates have a resolution of
+	// a millisecond and assume the clocks of both ends are synchronized.
+	// PacketSize and Flows are ignored when it is set.
+	OneWay bool
+
+	// Debug sets where to log hex dumps of the ICMP messages sent and
+	// received, along with why received messages were rejected, if any.
+	// The default is nil, which means nothing is logged.
+	Debug io.Writer
+
+	// Broadcast enables pinging broadcast addresses, collecting the replies
+	// of every host answering each request.
+	Broadcast bool
+
+	// Unprivileged sets whether to send requests through datagram ICMP
+	// sockets right away, rather than only when raw ones are not
+	// permitted.
+	Unprivileged bool
+
+	// TCPPort enables falling back to timing TCP handshakes with the given
+	// port when neither raw nor datagram ICMP sockets are permitted, in
+	// which case options specific to ICMP are ignored.
+	// The default is 0, which means there is no TCP fallback.
+	TCPPort uint
+
+	// TTL sets the IP time to live of outgoing packets.
+	// The default TTL is 0, which means the system default is used.
+	TTL uint
+
+	// Flows sets the number of flows requests are cycled across. Within
+	// a flow, every request carries the same ICMP checksum, so per-flow
+	// load balancers keep it on a single path.
+	// The default is 0, which means requests are sent without flow control.
+	Flows uint
+
+	// FlowID sets the identifier (i.e. the ICMP checksum) of the first
+	// flow when Flows is set.
+	FlowID uint16
+
+	// ECN sets the ECN codepoint of outgoing packets, either ECT0 or ECT1,
+	// and enables reporting the codepoint of echo replies, on Linux only.
+	// Ping fails with any other codepoint, as it does with a DSCP over 63.
+	// The default is NotECT, which means packets are not marked.
+	ECN ECN
+
+	// DSCP sets the Differentiated Services codepoint (0-63) of outgoing
+	// packets and enables reporting the codepoint of echo replies, on
+	// Linux only.
+	// The default is 0, which means packets are not marked.
+	DSCP uint
+
+	// HistogramDigits sets the number of significant value digits (1-5)
+	// of the HDR histogram RTTs are recorded into.
+	// The default is 3.
+	HistogramDigits uint
+
+	// MaxSamples sets the number of most recent round-trip latencies,
+	// loss bursts and sequence numbers of missing and late replies kept
+	// in the stats, which bounds their memory use on endless runs. Other
+	// stats are accumulated over the whole run regardless.
+	// The default is 10000.
+	MaxSamples uint
+
+	// DiscardSamples sets whether no samples are kept in the stats at
+	// all, but only counters and streaming aggregates, e.g. for
+	// minimizing memory use on embedded devices pinging for weeks. RTTs,
+	// Missing, Late and Buckets return nothing then, Outages keeps only
+	// as many recent outages as FlapThreshold needs, RateLimited is
+	// always false and no periodic pattern of latencies is detected.
+	// Counters, percentiles, RTTStats, OutageCount and LongestOutage still
+	// cover the whole run.
+	DiscardSamples bool
+
+	// BucketWidth sets the width of the time buckets requests are
+	// summarized into, e.g. for telling how the host behaved over the
+	// last hour of a long run.
+	// The default is 1 minute.
+	BucketWidth time.Duration
+
+	// CoarseBucketWidth sets the width of the time buckets that buckets
+	// no longer kept are downsampled into, so that long-range trends
+	// remain available. Buckets are not downsampled if it is not wider
+	// than BucketWidth.
+	// The default is 1 hour.
+	CoarseBucketWidth time.Duration
+
+	// OutageThreshold sets the number of consecutive requests that must
+	// be lost for the host to be considered unreachable, starting an
+	// outage.
+	// The default is 3.
+	OutageThreshold uint
+
+	// FlapThreshold sets the number of outages that must start within
+	// FlapWindow for the host to be considered flapping, rather than
+	// just down.
+	// The default is 3.
+	FlapThreshold uint
+
+	// FlapWindow sets the window within which outages are counted for
+	// detecting flapping.
+	// The default is 10 minutes.
+	FlapWindow time.Duration
+
+	// AnomalyWindow sets the number of most recent RTTs the baseline for
+	// detecting latency anomalies is computed from.
+	// The default is 30.
+	AnomalyWindow uint
+
+	// AnomalyThreshold sets the z-score (i.e. the number of standard
+	// deviations from the mean of the baseline) above which an RTT is
+	// considered anomalous.
+	// The default is 3.
+	AnomalyThreshold float64
+
+	// WarnRTT and CritRTT set the RTTs at or above which replies are of
+	// SeverityWarn and SeverityCrit, respectively.
+	// If not specified, replies are of SeverityOK regardless.
+	WarnRTT time.Duration
+	CritRTT time.Duration
 }
 
 // setDefaults sets each option to its default value in case one
@@ -81,41 +326,215 @@ func (o *Options) setDefaults() {
 	if o.PacketSize <= 0 {
 		o.PacketSize = DefaultPacketSize
 	}
+	if o.HistogramDigits <= 0 {
+		o.HistogramDigits = DefaultHistogramDigits
+	}
+	if o.OutageThreshold <= 0 {
+		o.OutageThreshold = DefaultOutageThreshold
+	}
+	if o.MaxSamples <= 0 {
+		o.MaxSamples = DefaultMaxSamples
+	}
+	if o.BucketWidth <= 0 {
+		o.BucketWidth = DefaultBucketWidth
+	}
+	if o.CoarseBucketWidth <= 0 {
+		o.CoarseBucketWidth = DefaultCoarseBucketWidth
+	}
+	if o.FlapThreshold <= 0 {
+		o.FlapThreshold = DefaultFlapThreshold
+	}
+	if o.FlapWindow <= 0 {
+		o.FlapWindow = DefaultFlapWindow
+	}
+	if o.AnomalyWindow <= 0 {
+		o.AnomalyWindow = DefaultAnomalyWindow
+	}
+	if o.AnomalyThreshold <= 0 {
+		o.AnomalyThreshold = DefaultAnomalyThreshold
+	}
+}
+
+// validateTOS returns an error if the DSCP or ECN options do not fit in
+// the TOS byte, or if ECN is not a codepoint packets may be marked with.
+func (o *Options) validateTOS() error {
+	if o.DSCP > 63 {
+		return fmt.Errorf("invalid DSCP %d: must be between 0 and 63", o.DSCP)
+	}
+	if o.ECN != NotECT && !o.ECN.Capable() {
+		return fmt.Errorf("invalid ECN codepoint %d: must be ECT(0) or ECT(1)", o.ECN)
+	}
+	return nil
 }
 
-// Resolve resolves the given host to a net.Addr.
-func Resolve(host string) (net.Addr, error) {
-	return net.ResolveIPAddr("ip4:icmp", host)
+// tos returns the TOS byte of outgoing packets, combining the DSCP and
+// ECN options.
+func (o *Options) tos() int {
+	return int(o.DSCP&0x3f)<<2 | int(o.ECN)&ecnMask
+}
+
+// maxSamples returns the number of most recent samples kept in the stats.
+func (o *Options) maxSamples() uint {
+	if o.DiscardSamples {
+		return 0
+	}
+	return o.MaxSamples
 }
 
 // Ping represents a ping request/response.
 type Ping struct {
-	// Seq is the sequence number.
+	// Seq is the sequence number, which keeps growing past the 16 bits
+	// of the one carried on the wire.
 	Seq int
 
 	// Size is the number of bytes in the response.
 	Size int
 
+	// From is the address the response was received from.
+	From net.Addr
+
+	// Addr is the address the request was sent to, which varies when
+	// Options.Rotate is set.
+	Addr net.Addr
+
+	// Type and Code are the ICMP type and code of the response. They are
+	// zero for timeouts and for requests not sent through ICMP, see
+	// Category.
+	Type int
+	Code int
+
+	// Category classifies the response.
+	Category Category
+
+	// ForeignResponder is whether an echo reply was received from an
+	// address other than the one of the host being pinged (e.g. from a
+	// middlebox or NAT answering on its behalf, or a spoofed reply). It
+	// is never set for multicast groups or broadcast addresses.
+	ForeignResponder bool
+
 	// RTT is the duration for the round trip.
 	RTT time.Duration
 
+	// SentAt is the time the request was sent at.
+	SentAt time.Time
+
+	// ReceivedAt is the time the response was received at, as reported by
+	// the kernel when possible. It is zero for timeouts.
+	ReceivedAt time.Time
+
+	// Forward and Reverse are the estimated one-way delays towards the host
+	// and back, only reported when either OneWay or TWAMPPort is set in
+	// Options.
+	Forward time.Duration
+	Reverse time.Duration
+
+	// TTL is the IP time to live of the echo reply, or zero if unknown.
+	TTL int
+
+	// Flow is the flow identifier of the request, if flow control is
+	// enabled.
+	Flow uint16
+
+	// ECN is the ECN codepoint of the echo reply, only reported when ECN
+	// is set in Options. A CE codepoint means the reply (or the request it
+	// echoes) was marked by a congested router along the path.
+	ECN ECN
+
+	// DSCP is the Differentiated Services codepoint of the echo reply,
+	// only reported when DSCP is set in Options.
+	DSCP int
+
+	// Anomalous is whether RTT deviates from the recent ones by more than
+	// Options.AnomalyThreshold standard deviations.
+	Anomalous bool
+
+	// Severity is how bad RTT is, according to Options.WarnRTT and
+	// Options.CritRTT.
+	Severity Severity
+
+	// Remarked is whether DSCP differs from the codepoint of the request,
+	// i.e. whether it was re-marked in transit.
+	Remarked bool
+
 	// Timeout is whether or not the request timed out.
 	Timeout bool
+
+	// TimeExceeded is whether a Time Exceeded message was received in
+	// response to the request.
+	TimeExceeded bool
+
+	// Unreachable is whether a Destination Unreachable message was
+	// received in response to the request.
+	Unreachable bool
+
+	// Redirect is the ICMP Redirect received in response to the request,
+	// if any, which is reported along with the actual response.
+	Redirect *Redirect
+
+	// NextHopMTU is the MTU of the next hop advertised by a Destination
+	// Unreachable response with the Fragmentation Needed code, i.e. the
+	// largest packet that can be forwarded without being fragmented.
+	NextHopMTU int
+
+	// Hop identifies the router that reported the expiry of the request's
+	// TTL in transit. It is only set when TimeExceeded is true.
+	Hop *Hop
+
+	// Extensions holds the ICMP extension objects carried by a Time
+	// Exceeded or Destination Unreachable response.
+	Extensions Extensions
+
+	// State is the state of the host after the request.
+	State State
+
+	// Method is how the request was sent.
+	Method Method
+
+	// Bandwidth is the bottleneck bandwidth estimated out of the packet
+	// pair of the request, in bits per second, when PacketPair is set in
+	// Options. It is 0 if the reply to the second request was not
+	// received.
+	Bandwidth float64
+
+	// Warmup is whether the request was one of the Options.Warmup ones,
+	// excluded from the stats.
+	Warmup bool
+}
+
+// Hop identifies a router along the path to the host being pinged.
+type Hop struct {
+	// Addr is the address of the router that reported the TTL expiry.
+	Addr net.Addr
+
+	// Seq is the sequence number of the expired request, whose 16 bits
+	// on the wire were quoted by the router.
+	Seq int
 }
 
 // NewPinger accepts an Options object and returns a new Pinger
 // configured with the given options.
 func NewPinger(opts *Options) Pinger {
 	opts.setDefaults()
-	return &pinger{
+	p := &pinger{
 		id:         rand.Intn(maxID),
 		opts:       opts,
 		reportChan: make(chan Ping), // TODO: use buffer?
 		errChan:    make(chan error, 1),
-		stop:       make(chan struct{}, 1),
-		stats:      &Stats{},
+		stop:       make(chan struct{}),
+		force:      make(chan struct{}),
+		done:       make(chan struct{}),
+		stats:      newStats(opts.HistogramDigits, opts.OutageThreshold, opts.maxSamples(), opts.BucketWidth, opts.CoarseBucketWidth),
 		clock:      defaultClock{},
+		anomalies:  newAnomalyDetector(opts.AnomalyWindow, opts.AnomalyThreshold),
+		recent:     newRecentResults(opts.RecentResults),
+	}
+	if opts.Warmup > 0 {
+		p.warmup = newStats(opts.HistogramDigits, opts.OutageThreshold, opts.maxSamples(), opts.BucketWidth, opts.CoarseBucketWidth)
+		p.warmup.minOutages = int(opts.FlapThreshold)
 	}
+	// Flapping is detected out of the most recent outages.
+	p.stats.minOutages = int(opts.FlapThreshold)
+	return p
 }
 
 // pinger is the default implementation for Pinger.
@@ -125,8 +544,17 @@ type pinger struct {
 	reportChan chan Ping
 	errChan    chan error
 	stats      *Stats
+	statsMu    sync.Mutex
 	stop       chan struct{}
+	stopOnce   sync.Once
+	force      chan struct{}
+	forceOnce  sync.Once
+	done       chan struct{}
 	clock      clock
+	anomalies  *anomalyDetector
+	warmup     *Stats
+	replies    replyWindow
+	recent     *recentResults
 }
 
 // Report returns the pair of channels used for reporting.
@@ -134,122 +562,687 @@ func (p *pinger) Report() (<-chan Ping, <-chan error) {
 	return p.reportChan, p.errChan
 }
 
-// Stats returns the stats for the pinger.
+// Stats returns a snapshot of the stats for the pinger.
 func (p *pinger) Stats() Stats {
-	return *p.stats
+	p.statsMu.Lock()
+	defer p.statsMu.Unlock()
+	return p.stats.snapshot()
+}
+
+// Recent returns the most recent results reported, oldest first.
+func (p *pinger) Recent() []Ping {
+	return p.recent.list()
+}
+
+// updateStats applies fn to the stats while holding the stats lock. While
+// warming up, fn is applied to throwaway stats instead.
+func (p *pinger) updateStats(fn func(s *Stats)) {
+	p.statsMu.Lock()
+	defer p.statsMu.Unlock()
+	if p.warmup != nil {
+		fn(p.warmup)
+		return
+	}
+	fn(p.stats)
+}
+
+// endWarmup starts recording the stats, from now on.
+func (p *pinger) endWarmup() {
+	p.statsMu.Lock()
+	defer p.statsMu.Unlock()
+	p.warmup = nil
+	p.stats.warmupCount = int(p.opts.Warmup)
+	p.stats.startedAt = p.clock.Now()
 }
 
 // Ping uses Go's x/net/icmp package to send ping packets to the given addr.
 // Ping is a blocking operation.
 func (p *pinger) Ping(addr net.Addr) {
+	defer close(p.done)
 	defer close(p.reportChan)
 	defer close(p.errChan)
 
-	conn, err := icmp.ListenPacket("ip4:icmp", "")
+	p.updateStats(func(s *Stats) {
+		s.startedAt = p.clock.Now()
+	})
+	defer p.updateStats(func(s *Stats) {
+		s.stoppedAt = p.clock.Now()
+	})
+
+	if err := p.opts.validateTOS(); err != nil {
+		p.errChan <- err
+		return
+	}
+
+	conn, err := p.listen()
 	if err != nil {
-		p.errChan <- fmt.Errorf("cannot connect to addr %s: %v", addr, err)
+		if _, ok := err.(*PermissionError); !ok {
+			err = fmt.Errorf("cannot connect to addr %s: %v", addr, err)
+		}
+		p.errChan <- err
 		return
 	}
 	defer conn.Close()
 
+	// Closing the connection unblocks any read or write in flight when
+	// forcibly stopped.
+	go func() {
+		select {
+		case <-p.force:
+			conn.Close()
+		case <-p.done:
+		}
+	}()
+
+	if err := p.configure(conn); err != nil {
+		p.errChan <- err
+		return
+	}
+
 	seq := 0
 	for {
 		select {
 		case <-p.stop:
 			return
 		default:
-			ping, err := p.ping(conn, addr, seq)
+			target := p.target(addr, seq)
+			pings, err := p.ping(conn, target, seq)
+			if p.forced() {
+				return
+			}
 			if err != nil {
 				p.errChan <- err
 				return
 			}
 
-			p.reportChan <- ping
+			warmup := p.warmup != nil
+			for _, ping := range pings {
+				ping.Addr = target
+				ping.Warmup = warmup
+				p.recent.add(ping)
+				p.reportChan <- ping
+			}
 			seq++
 
-			if p.opts.Count != 0 && int(p.opts.Count) == seq {
+			if warmup && seq == int(p.opts.Warmup) {
+				p.endWarmup()
+			}
+
+			p.countSuccesses(pings)
+
+			if p.reachedCount(seq) || p.reachedSuccesses() {
 				p.Stop()
 			} else {
-				time.Sleep(time.Second)
+				p.wait(time.Second)
 			}
 		}
 	}
 }
 
+// target returns the address request seq is sent to, cycling through addr
+// and Options.Rotate.
+func (p *pinger) target(addr net.Addr, seq int) net.Addr {
+	i := seq % (len(p.opts.Rotate) + 1)
+	if i == 0 {
+		return addr
+	}
+	return p.opts.Rotate[i-1]
+}
+
+// configure applies the options to the socket of conn, if any.
+func (p *pinger) configure(conn *icmpConn) error {
+	if conn.socket == nil {
+		return nil
+	}
+
+	if p.opts.Interface != "" {
+		if err := bindToDevice(conn, p.opts.Interface); err != nil {
+			return fmt.Errorf("cannot bind to interface %s: %v", p.opts.Interface, err)
+		}
+	}
+	if p.opts.Broadcast {
+		if err := setBroadcast(conn); err != nil {
+			return fmt.Errorf("cannot enable broadcast: %v", err)
+		}
+	}
+	if len(p.opts.IPOptions) > 0 || len(p.opts.RawIPOptions) > 0 {
+		b, err := marshalIPOptions(p.opts.IPOptions, p.opts.RawIPOptions)
+		if err != nil {
+			return fmt.Errorf("cannot encode IP options: %v", err)
+		}
+		if err := setIPOptions(conn, b); err != nil {
+			return fmt.Errorf("cannot set IP options: %v", err)
+		}
+	}
+	if err := setRecvTTL(conn); err != nil {
+		return fmt.Errorf("cannot enable TTL reporting: %v", err)
+	}
+	if err := setRecvTimestamp(conn); err != nil {
+		return fmt.Errorf("cannot enable timestamping: %v", err)
+	}
+	// The ipv4 package only recognizes the sockets of the net package, so
+	// it is given the one wrapped by conn.
+	if p.opts.TTL != 0 {
+		if err := ipv4.NewConn(conn.socket).SetTTL(int(p.opts.TTL)); err != nil {
+			return fmt.Errorf("cannot set TTL to %d: %v", p.opts.TTL, err)
+		}
+	}
+	if tos := p.opts.tos(); tos != 0 {
+		if err := ipv4.NewConn(conn.socket).SetTOS(tos); err != nil {
+			return fmt.Errorf("cannot set TOS to %#02x: %v", tos, err)
+		}
+		if err := setRecvTOS(conn); err != nil {
+			return fmt.Errorf("cannot enable TOS reporting: %v", err)
+		}
+	}
+	return nil
+}
+
 // Stop signals the Pinger to stop sending ping requests to the host.
 func (p *pinger) Stop() {
-	p.stop <- struct{}{}
+	p.stopOnce.Do(func() {
+		close(p.stop)
+	})
+}
+
+// reachedCount returns whether Count is reached after sending seq
+// requests.
+func (p *pinger) reachedCount(seq int) bool {
+	if p.opts.Count == 0 {
+		return false
+	}
+	if !p.opts.CountReplies {
+		return int(p.opts.Count) == seq-int(p.opts.Warmup)
+	}
+
+	// Replies received while warming up do not count, so the recorded
+	// stats are read rather than those updated at the moment.
+	p.statsMu.Lock()
+	defer p.statsMu.Unlock()
+	return p.stats.Received() >= int(p.opts.Count)
 }
 
-func (p *pinger) ping(conn net.PacketConn, addr net.Addr, seq int) (Ping, error) {
-	pktSize, err := p.send(conn, addr, seq)
+// countSuccesses extends the run of consecutive replies under
+// Options.SuccessRTT with the responses to a request, or breaks it if none
+// of them qualifies.
+func (p *pinger) countSuccesses(pings []Ping) {
+	success := false
+	for _, ping := range pings {
+		reply := ping.Category == Reply || ping.Category == GroupReply
+		if reply && (p.opts.SuccessRTT == 0 || ping.RTT < p.opts.SuccessRTT) {
+			success = true
+		}
+	}
+	p.updateStats(func(s *Stats) {
+		if success {
+			s.successRun++
+		} else {
+			s.successRun = 0
+		}
+	})
+}
+
+// reachedSuccesses returns whether Options.Successes consecutive replies
+// were received.
+func (p *pinger) reachedSuccesses() bool {
+	if p.opts.Successes == 0 {
+		return false
+	}
+
+	p.statsMu.Lock()
+	defer p.statsMu.Unlock()
+	return p.stats.successRun >= int(p.opts.Successes)
+}
+
+// timeout returns how long to wait for the response to the request seq.
+func (p *pinger) timeout(seq int) time.Duration {
+	final := p.opts.Count != 0 && !p.opts.CountReplies && seq == int(p.opts.Warmup+p.opts.Count)-1
+	if final && p.opts.Linger > p.opts.Timeout {
+		return p.opts.Linger
+	}
+	return p.opts.Timeout
+}
+
+// StopWait stops the Pinger, forcibly if Ping does not return within
+// timeout.
+func (p *pinger) StopWait(timeout time.Duration) bool {
+	p.Stop()
+	select {
+	case <-p.done:
+		return true
+	case <-time.After(timeout):
+	}
+
+	p.forceOnce.Do(func() {
+		close(p.force)
+	})
+	return false
+}
+
+// forced returns whether the Pinger was forcibly stopped.
+func (p *pinger) forced() bool {
+	select {
+	case <-p.force:
+		return true
+	default:
+		return false
+	}
+}
+
+// wait waits for d to elapse before sending the next request, unless
+// stopped in the meantime.
+func (p *pinger) wait(d time.Duration) {
+	select {
+	case <-p.stop:
+	case <-time.After(d):
+	}
+}
+
+// ping sends the request seq to addr, returning its response, followed by
+// those of other members if addr is a multicast group.
+func (p *pinger) ping(conn *icmpConn, addr net.Addr, seq int) ([]Ping, error) {
+	if conn.method == TCPConnect {
+		ping, err := p.pingTCP(addr, seq)
+		if err != nil {
+			return nil, err
+		}
+		ping.Method = TCPConnect
+		ping.Category = categorize(ping, false)
+		return []Ping{ping}, nil
+	}
+
+	sentAt := p.clock.Now()
+	var ping Ping
+	var pktSize int
+	var err error
+	if conn.method == TWAMPLight {
+		ping, err = p.pingTWAMP(conn, addr, seq, sentAt)
+	} else {
+		if pktSize, err = p.send(conn, addr, seq, sentAt); err != nil {
+			return nil, fmt.Errorf("cannot send ping packet for icmp_seq %d: %v", seq, err)
+		}
+		pair := p.opts.PacketPair && !p.isGroup(addr)
+		if pair {
+			if _, err = p.send(conn, addr, seq, p.clock.Now()); err != nil {
+				return nil, fmt.Errorf("cannot send ping packet for icmp_seq %d: %v", seq, err)
+			}
+		}
+		ping, err = p.recv(conn, seq, pktSize, sentAt)
+		if err == nil && pair && isReply(ping) {
+			p.measurePair(conn, &ping, pktSize)
+		}
+	}
 	if err != nil {
-		return Ping{}, fmt.Errorf("cannot send ping packet for icmp_seq %d: %v", seq, err)
+		return nil, err
 	}
 
-	return p.recv(conn, seq, pktSize)
+	pings := []Ping{ping}
+	if p.isGroup(addr) && !ping.Timeout && conn.method != TWAMPLight {
+		pings = append(pings, p.collect(conn, seq, pktSize)...)
+	} else if isReply(ping) && !sameIP(ping.From, addr) {
+		pings[0].ForeignResponder = true
+	}
+
+	var state State
+	p.updateStats(func(s *Stats) {
+		for _, ping := range pings {
+			if isReply(ping) {
+				s.recordResponder(ping.From)
+			}
+		}
+		state = stateAt(s.Outages(), sentAt, p.opts)
+	})
+	group := p.isGroup(addr)
+	for i := range pings {
+		pings[i].SentAt = sentAt
+		pings[i].Method = conn.method
+		pings[i].State = state
+		pings[i].Category = categorize(pings[i], group)
+		if p.opts.Flows != 0 {
+			pings[i].Flow = flowFor(p.opts, seq)
+		}
+	}
+	return pings, nil
 }
 
-func (p *pinger) send(conn net.PacketConn, addr net.Addr, seq int) (int, error) {
-	pktBytes, err := createPacket(p.id, seq, int(p.opts.PacketSize), p.clock.Now())
+func (p *pinger) send(conn *icmpConn, addr net.Addr, seq int, now time.Time) (int, error) {
+	size := int(p.opts.PacketSize)
+	if p.opts.Flows != 0 && size < timeByteSize+flowByteSize {
+		size = timeByteSize + flowByteSize
+	}
+
+	var pktBytes []byte
+	var err error
+	if p.opts.OneWay {
+		pktBytes, err = createTimestampRequest(p.id, seq, now)
+	} else {
+		pktBytes, err = createPacket(p.id, seq, size, now)
+	}
 	if err != nil {
 		return 0, fmt.Errorf("cannot encode packet: %v", err)
 	}
+	if p.opts.Flows != 0 && !p.opts.OneWay {
+		setFlow(pktBytes, flowFor(p.opts, seq))
+	}
 
-	if _, err := conn.WriteTo(pktBytes, addr); err != nil {
+	p.dump(pktBytes, "sent icmp_seq %d to %v:", seq, addr)
+	if err := conn.writeTo(pktBytes, addr); err != nil {
 		return 0, fmt.Errorf("cannot send ping packet for icmp_seq %d: %v", seq, err)
 	}
 
 	return len(pktBytes), nil
 }
 
-func (p *pinger) recv(conn net.PacketConn, seq int, pktSize int) (Ping, error) {
-	conn.SetReadDeadline(time.Now().Add(p.opts.Timeout))
-	resBytes := make([]byte, pktSize)
-	n, _, err := conn.ReadFrom(resBytes)
-	if err != nil {
-		if neterr, ok := err.(*net.OpError); ok && neterr.Timeout() {
-			p.stats.incTimeout()
-			return Ping{
-				Seq:     seq,
-				Timeout: true,
-			}, nil
-		} else {
-			return Ping{}, fmt.Errorf("cannot read packet for icmp_seq %d: %v", seq, err)
+func (p *pinger) recv(conn *icmpConn, seq int, pktSize int, sentAt time.Time) (Ping, error) {
+	conn.SetReadDeadline(time.Now().Add(p.timeout(seq)))
+	buf := readBuffer(pktSize)
+	oob := make([]byte, oobBufferSize)
+
+	// Raw ICMP sockets receive every ICMP message sent to the host, so
+	// anything but the response to this request (e.g. late replies to
+	// previous requests, or replies to other processes) is skipped.
+	var (
+		resBytes []byte
+		oobn     int
+		peer     net.Addr
+		res      *icmp.Message
+		pkt      *icmp.Echo
+		redirect *Redirect
+	)
+	for {
+		var n int
+		var err error
+		n, oobn, peer, err = conn.readMsg(buf, oob)
+		if err != nil {
+			if neterr, ok := err.(*net.OpError); ok && neterr.Timeout() {
+				p.updateStats(func(s *Stats) {
+					s.incTimeout(sentAt)
+					s.recordMissing(seq)
+				})
+				return Ping{
+					Seq:      seq,
+					Timeout:  true,
+					Redirect: redirect,
+				}, nil
+			} else {
+				return Ping{}, fmt.Errorf("cannot read packet for icmp_seq %d: %v", seq, err)
+			}
+		}
+
+		resBytes = stripIPv4Header(buf[:n])
+		p.dump(resBytes, "received %d bytes from %v:", len(resBytes), peer)
+		if !validChecksum(resBytes) {
+			p.debugf("rejected: invalid checksum")
+			p.updateStats(func(s *Stats) {
+				s.checksumErrors++
+			})
+			continue
+		}
+		if res, pkt, err = p.parse(seq, resBytes); err != nil {
+			if earlier, ok := p.earlierReply(resBytes, seq); ok {
+				if p.replies.replied(earlier) {
+					p.debugf("rejected: duplicate reply to icmp_seq %d", earlier)
+					p.updateStats(func(s *Stats) {
+						s.duplicates++
+					})
+					continue
+				}
+				p.debugf("rejected: late reply to icmp_seq %d", earlier)
+				p.replies.add(earlier)
+				p.updateStats(func(s *Stats) {
+					s.recordLate(earlier)
+				})
+				continue
+			}
+			p.debugf("rejected: %v", err)
+			continue
+		}
+
+		// Redirects are informational, so keep waiting for the response.
+		if r := parseRedirect(res, peer); r != nil {
+			p.debugf("redirected to %v", r.Gateway)
+			redirect = r
+			continue
 		}
+		if res.Type == ipv4.ICMPTypeEchoReply && !p.intactPayload(pkt.Data, pktSize, sentAt) {
+			p.debugf("rejected: corrupted payload")
+			p.updateStats(func(s *Stats) {
+				s.corrupted++
+			})
+			continue
+		}
+		break
 	}
+	n := len(resBytes)
+	receivedAt := p.receivedAt(oob[:oobn])
+	typ, code := typeAndCode(res)
 
-	res, err := p.parse(seq, resBytes)
-	if err != nil {
-		return Ping{}, err
+	switch body := res.Body.(type) {
+	case *icmp.TimeExceeded:
+		p.updateStats(func(s *Stats) {
+			s.incError(sentAt)
+		})
+		return Ping{
+			Seq:          seq,
+			Size:         n,
+			RTT:          p.clock.Now().Sub(sentAt),
+			From:         peer,
+			Type:         typ,
+			Code:         code,
+			ReceivedAt:   receivedAt,
+			Redirect:     redirect,
+			TimeExceeded: true,
+			Hop: &Hop{
+				Addr: peer,
+				Seq:  seq,
+			},
+			Extensions: parseExtensions(body.Extensions),
+		}, nil
+	case *icmp.DstUnreach:
+		p.updateStats(func(s *Stats) {
+			s.incError(sentAt)
+		})
+		return Ping{
+			Seq:         seq,
+			Size:        n,
+			RTT:         p.clock.Now().Sub(sentAt),
+			From:        peer,
+			Type:        typ,
+			Code:        code,
+			ReceivedAt:  receivedAt,
+			Redirect:    redirect,
+			Unreachable: true,
+			NextHopMTU:  nextHopMTU(res, resBytes),
+			Extensions:  parseExtensions(body.Extensions),
+		}, nil
+	}
+
+	if res.Type == ipv4.ICMPTypeTimestampReply {
+		now := p.clock.Now()
+		rtt := now.Sub(sentAt)
+		forward, reverse := oneWayDelays(pkt.Data, now)
+		p.replies.add(seq)
+		p.updateStats(func(s *Stats) {
+			s.recordOneWay(forward, reverse)
+		})
+		return Ping{
+			Seq:        seq,
+			Size:       n,
+			RTT:        rtt,
+			From:       peer,
+			Type:       typ,
+			Code:       code,
+			ReceivedAt: receivedAt,
+			Redirect:   redirect,
+			Forward:    forward,
+			Reverse:    reverse,
+			Anomalous:  p.recordSuccess(rtt, sentAt),
+			Severity:   p.severity(rtt),
+		}, nil
+	}
+
+	rtt := p.clock.Now().Sub(bytesToTime(pkt.Data[:timeByteSize]))
+	p.replies.add(seq)
+	ping := Ping{
+		Seq:        seq,
+		Size:       n,
+		RTT:        rtt,
+		From:       peer,
+		Type:       typ,
+		Code:       code,
+		ReceivedAt: receivedAt,
+		Redirect:   redirect,
+		Anomalous:  p.recordSuccess(rtt, sentAt),
+		Severity:   p.severity(rtt),
+	}
+	p.parseControl(&ping, oob[:oobn])
+	return ping, nil
+}
+
+// receivedAt returns the time a packet was received at by the kernel, as
+// reported in the socket control messages in oob, or the current time if
+// not reported.
+func (p *pinger) receivedAt(oob []byte) time.Time {
+	if t, ok := parseTimestamp(oob); ok {
+		return t
+	}
+	return p.clock.Now()
+}
+
+// parseControl sets the TTL, ECN and DSCP of ping out of the control
+// messages received along with the reply.
+func (p *pinger) parseControl(ping *Ping, oob []byte) {
+	if ttl, ok := parseTTL(oob); ok {
+		ping.TTL = ttl
+	}
+	if tos, ok := parseTOS(oob); ok {
+		if p.opts.ECN != NotECT {
+			ping.ECN = ECN(tos & ecnMask)
+		}
+		if p.opts.DSCP != 0 {
+			ping.DSCP = tos >> 2
+			ping.Remarked = ping.DSCP != int(p.opts.DSCP)
+		}
 	}
+}
+
+// isReply returns whether ping holds an echo reply.
+func isReply(ping Ping) bool {
+	return ping.From != nil && !ping.TimeExceeded && !ping.Unreachable
+}
 
-	rtt := p.clock.Now().Sub(bytesToTime(res.Data[:timeByteSize]))
-	p.stats.incSuccess(rtt)
+// sameIP returns whether a and b hold the same IP address.
+func sameIP(a net.Addr, b net.Addr) bool {
+	ipA, errA := IPAddrOf(a)
+	ipB, errB := IPAddrOf(b)
+	return errA == nil && errB == nil && ipA.IP.Equal(ipB.IP)
+}
 
-	return Ping{
-		Seq:  seq,
-		Size: n,
-		RTT:  rtt,
-	}, nil
+// readBuffer returns a buffer large enough for reading the response to a
+// request of pktSize bytes, along with its IPv4 header.
+func readBuffer(pktSize int) []byte {
+	bufSize := pktSize + maxIPv4HeaderLen
+	if bufSize < readBufferSize {
+		bufSize = readBufferSize
+	}
+	return make([]byte, bufSize)
 }
 
-func (p *pinger) parse(seq int, resBytes []byte) (*icmp.Echo, error) {
+// parse parses the response for the request identified by seq, which is
+// expected to be either an echo reply or an ICMP error message quoting the
+// original echo request. Along with the message, it returns the echo reply
+// or the quoted echo request, respectively.
+func (p *pinger) parse(seq int, resBytes []byte) (*icmp.Message, *icmp.Echo, error) {
 	res, err := icmp.ParseMessage(ipv4Proto, resBytes)
 	if err != nil {
-		return nil, fmt.Errorf("cannot parse response for icmp_seq %d: %v", seq, err)
+		return nil, nil, fmt.Errorf("cannot parse response for icmp_seq %d: %v", seq, err)
 	}
 
-	if res.Type != ipv4.ICMPTypeEchoReply {
-		return nil, fmt.Errorf("cannot parse response for icmp_seq %d: %T", seq, res.Body)
+	var pkt *icmp.Echo
+	switch body := res.Body.(type) {
+	case *icmp.Echo:
+		if res.Type != ipv4.ICMPTypeEchoReply {
+			return nil, nil, fmt.Errorf("cannot parse response for icmp_seq %d: %v", seq, res.Type)
+		}
+		pkt = body
+	case *icmp.TimeExceeded:
+		if pkt, err = quotedEcho(body.Data); err != nil {
+			return nil, nil, fmt.Errorf("cannot parse response for icmp_seq %d: %v", seq, err)
+		}
+	case *icmp.DstUnreach:
+		if pkt, err = quotedEcho(body.Data); err != nil {
+			return nil, nil, fmt.Errorf("cannot parse response for icmp_seq %d: %v", seq, err)
+		}
+	case *icmp.RawBody:
+		switch {
+		case res.Type == ipv4.ICMPTypeTimestampReply:
+			var ok bool
+			if pkt, ok = parseTimestampReply(body); !ok {
+				return nil, nil, fmt.Errorf("cannot parse response for icmp_seq %d: timestamp reply too short", seq)
+			}
+		case res.Type == ipv4.ICMPTypeRedirect && len(body.Data) >= 4:
+			if pkt, err = quotedEcho(body.Data[4:]); err != nil {
+				return nil, nil, fmt.Errorf("cannot parse response for icmp_seq %d: %v", seq, err)
+			}
+		default:
+			return nil, nil, fmt.Errorf("unexpected response type for icmp_seq %d: %v", seq, res.Type)
+		}
+	default:
+		return nil, nil, fmt.Errorf("unexpected response type for icmp_seq %d: %T", seq, res.Body)
 	}
-	pkt, ok := res.Body.(*icmp.Echo)
-	if !ok {
-		return nil, fmt.Errorf("unexpected response type for icmp_seq %d: %T", seq, res.Body)
+
+	if pkt.ID != p.id || pkt.Seq != wireSeq(seq) {
+		return nil, nil, fmt.Errorf("unexpected response for icmp_seq %d: %v", seq, pkt)
 	}
 
-	if pkt.ID != p.id || pkt.Seq != seq {
-		return nil, fmt.Errorf("unexpected response for icmp_seq %d: %v", seq, pkt)
+	return res, pkt, nil
+}
+
+// nextHopMTU returns the next-hop MTU carried by res, the message parsed
+// out of b, if it is a Destination Unreachable with the Fragmentation
+// Needed code (RFC 1191), or zero otherwise.
+func nextHopMTU(res *icmp.Message, b []byte) int {
+	if res.Type != ipv4.ICMPTypeDestinationUnreachable || res.Code != fragmentationNeeded || len(b) < 8 {
+		return 0
+	}
+	return int(binary.BigEndian.Uint16(b[6:8]))
+}
+
+// stripIPv4Header returns the ICMP message in b, skipping the IPv4 header
+// that raw sockets deliver along with it.
+func stripIPv4Header(b []byte) []byte {
+	if len(b) < ipv4.HeaderLen || b[0]>>4 != ipv4.Version {
+		return b
+	}
+	hdrLen := int(b[0]&0x0f) << 2
+	if hdrLen < ipv4.HeaderLen || hdrLen > len(b) {
+		return b
+	}
+	return b[hdrLen:]
+}
+
+// quotedEcho extracts the echo request quoted in the original datagram
+// field of an ICMP error message, which holds the original IPv4 header
+// followed by at least the first 8 bytes of the original ICMP message.
+func quotedEcho(data []byte) (*icmp.Echo, error) {
+	if len(data) < ipv4.HeaderLen {
+		return nil, fmt.Errorf("original datagram too short: %d bytes", len(data))
+	}
+	hdrLen := int(data[0]&0x0f) << 2
+	if len(data) < hdrLen+8 {
+		return nil, fmt.Errorf("original datagram too short: %d bytes", len(data))
+	}
+	if data[9] != ipv4Proto {
+		return nil, fmt.Errorf("original datagram is not ICMP: protocol %d", data[9])
+	}
+
+	msg, err := icmp.ParseMessage(ipv4Proto, data[hdrLen:])
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse original datagram: %v", err)
+	}
+	pkt, ok := msg.Body.(*icmp.Echo)
+	if !ok || msg.Type != ipv4.ICMPTypeEcho {
+		return nil, fmt.Errorf("original datagram is not an echo request: %v", msg.Type)
 	}
 
 	return pkt, nil
@@ -272,7 +1265,7 @@ func createPacket(id int, seq int, size int, now time.Time) ([]byte, error) {
 		Code: 0,
 		Body: &icmp.Echo{
 			ID:   id,
-			Seq:  seq,
+			Seq:  wireSeq(seq),
 			Data: payload,
 		},
 	}
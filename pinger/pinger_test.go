@@ -0,0 +1,762 @@
+package pinger
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+func TestParse(t *testing.T) {
+	p := &pinger{id: 42}
+	now := time.Unix(1500000000, 0)
+
+	tests := []struct {
+		desc     string
+		msg      *icmp.Message
+		seq      int
+		expected Extensions
+		wantErr  bool
+	}{
+		{
+			desc: "accepts a matching echo reply",
+			msg: &icmp.Message{
+				Type: ipv4.ICMPTypeEchoReply,
+				Body: &icmp.Echo{ID: 42, Seq: 3, Data: timeToBytes(now)},
+			},
+			seq: 3,
+		},
+		{
+			desc: "rejects an echo reply with a different ID",
+			msg: &icmp.Message{
+				Type: ipv4.ICMPTypeEchoReply,
+				Body: &icmp.Echo{ID: 43, Seq: 3, Data: timeToBytes(now)},
+			},
+			seq:     3,
+			wantErr: true,
+		},
+		{
+			desc: "accepts an echo reply after the sequence number wraps around",
+			msg: &icmp.Message{
+				Type: ipv4.ICMPTypeEchoReply,
+				Body: &icmp.Echo{ID: 42, Seq: 3, Data: timeToBytes(now)},
+			},
+			seq: seqSpace + 3,
+		},
+		{
+			desc: "rejects an echo reply to the request before the wraparound",
+			msg: &icmp.Message{
+				Type: ipv4.ICMPTypeEchoReply,
+				Body: &icmp.Echo{ID: 42, Seq: 3, Data: timeToBytes(now)},
+			},
+			seq:     seqSpace + 4,
+			wantErr: true,
+		},
+		{
+			desc: "rejects an echo request",
+			msg: &icmp.Message{
+				Type: ipv4.ICMPTypeEcho,
+				Body: &icmp.Echo{ID: 42, Seq: 3, Data: timeToBytes(now)},
+			},
+			seq:     3,
+			wantErr: true,
+		},
+		{
+			desc: "accepts a time exceeded quoting the request",
+			msg: &icmp.Message{
+				Type: ipv4.ICMPTypeTimeExceeded,
+				Body: &icmp.TimeExceeded{Data: quote(t, 42, 3, now)},
+			},
+			seq: 3,
+		},
+		{
+			desc: "rejects a time exceeded quoting another request",
+			msg: &icmp.Message{
+				Type: ipv4.ICMPTypeTimeExceeded,
+				Body: &icmp.TimeExceeded{Data: quote(t, 42, 2, now)},
+			},
+			seq:     3,
+			wantErr: true,
+		},
+		{
+			desc: "parses MPLS extensions in a time exceeded",
+			msg: &icmp.Message{
+				Type: ipv4.ICMPTypeTimeExceeded,
+				Body: &icmp.TimeExceeded{
+					Data: quote(t, 42, 3, now),
+					Extensions: []icmp.Extension{
+						&icmp.MPLSLabelStack{
+							Class: 1,
+							Type:  1,
+							Labels: []icmp.MPLSLabel{
+								{Label: 16014, TC: 0x4, S: true, TTL: 255},
+							},
+						},
+					},
+				},
+			},
+			seq: 3,
+			expected: Extensions{
+				MPLSLabels: []MPLSLabel{{Label: 16014, TC: 0x4, S: true, TTL: 255}},
+			},
+		},
+		{
+			desc: "accepts a redirect quoting the request",
+			msg: &icmp.Message{
+				Type: ipv4.ICMPTypeRedirect,
+				Code: 1,
+				Body: &icmp.RawBody{Data: append([]byte{192, 0, 2, 254}, quote(t, 42, 3, now)...)},
+			},
+			seq: 3,
+		},
+		{
+			desc: "accepts a destination unreachable quoting the request",
+			msg: &icmp.Message{
+				Type: ipv4.ICMPTypeDestinationUnreachable,
+				Code: 1,
+				Body: &icmp.DstUnreach{Data: quote(t, 42, 3, now)},
+			},
+			seq: 3,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			b, err := tc.msg.Marshal(nil)
+			if err != nil {
+				t.Fatalf("cannot marshal message: %v", err)
+			}
+
+			res, pkt, err := p.parse(tc.seq, b)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("wanted an error, got %v", res)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("wanted no error, got %v", err)
+			}
+			if pkt.ID != p.id || pkt.Seq != wireSeq(tc.seq) {
+				t.Errorf("wanted echo for id %d and seq %d, got %v", p.id, tc.seq, pkt)
+			}
+
+			var ext Extensions
+			switch body := res.Body.(type) {
+			case *icmp.TimeExceeded:
+				ext = parseExtensions(body.Extensions)
+			case *icmp.DstUnreach:
+				ext = parseExtensions(body.Extensions)
+			}
+			if !reflect.DeepEqual(ext, tc.expected) {
+				t.Errorf("wanted %+v, got %+v", tc.expected, ext)
+			}
+		})
+	}
+}
+
+// quote returns an original datagram field quoting the echo request
+// identified by id and seq, as found in ICMP error messages.
+func quote(t *testing.T, id int, seq int, now time.Time) []byte {
+	pkt, err := createPacket(id, seq, int(DefaultPacketSize), now)
+	if err != nil {
+		t.Fatalf("cannot create packet: %v", err)
+	}
+
+	hdr := make([]byte, ipv4.HeaderLen)
+	hdr[0] = 0x45
+	hdr[8] = 1
+	hdr[9] = ipv4Proto
+	return append(hdr, pkt[:8]...)
+}
+
+func TestNextHopMTU(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+
+	tests := []struct {
+		desc     string
+		code     int
+		expected int
+	}{
+		{
+			desc:     "reads the MTU of a fragmentation needed",
+			code:     fragmentationNeeded,
+			expected: 1400,
+		},
+		{
+			desc:     "ignores other destination unreachable codes",
+			code:     1,
+			expected: 0,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			msg := &icmp.Message{
+				Type: ipv4.ICMPTypeDestinationUnreachable,
+				Code: tc.code,
+				Body: &icmp.DstUnreach{Data: quote(t, 42, 3, now)},
+			}
+			b, err := msg.Marshal(nil)
+			if err != nil {
+				t.Fatalf("cannot marshal message: %v", err)
+			}
+			b[6], b[7] = 0x05, 0x78
+
+			res, err := icmp.ParseMessage(ipv4Proto, b)
+			if err != nil {
+				t.Fatalf("cannot parse message: %v", err)
+			}
+			if mtu := nextHopMTU(res, b); mtu != tc.expected {
+				t.Errorf("wanted %d, got %d", tc.expected, mtu)
+			}
+		})
+	}
+}
+
+func TestParseRedirect(t *testing.T) {
+	quoted := quote(t, 42, 3, time.Unix(1500000000, 0))
+	copy(quoted[16:20], []byte{198, 51, 100, 7})
+	peer := &net.IPAddr{IP: net.IPv4(192, 0, 2, 1)}
+
+	tests := []struct {
+		desc     string
+		msg      *icmp.Message
+		expected *Redirect
+	}{
+		{
+			desc: "parses the gateway and destination of a redirect",
+			msg: &icmp.Message{
+				Type: ipv4.ICMPTypeRedirect,
+				Code: 1,
+				Body: &icmp.RawBody{Data: append([]byte{192, 0, 2, 254}, quoted...)},
+			},
+			expected: &Redirect{
+				From:        peer,
+				Code:        1,
+				Gateway:     net.IP{192, 0, 2, 254},
+				Destination: net.IP{198, 51, 100, 7},
+			},
+		},
+		{
+			desc: "ignores other messages",
+			msg: &icmp.Message{
+				Type: ipv4.ICMPTypeEchoReply,
+				Body: &icmp.Echo{ID: 42, Seq: 3},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			b, err := tc.msg.Marshal(nil)
+			if err != nil {
+				t.Fatalf("cannot marshal message: %v", err)
+			}
+			res, err := icmp.ParseMessage(ipv4Proto, b)
+			if err != nil {
+				t.Fatalf("cannot parse message: %v", err)
+			}
+
+			if r := parseRedirect(res, peer); !reflect.DeepEqual(r, tc.expected) {
+				t.Errorf("wanted %+v, got %+v", tc.expected, r)
+			}
+		})
+	}
+}
+
+func TestStop(t *testing.T) {
+	p := NewPinger(&Options{}).(*pinger)
+
+	stopped := make(chan struct{})
+	go func() {
+		p.wait(time.Minute)
+		close(stopped)
+	}()
+
+	p.Stop()
+	p.Stop()
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Error("wanted wait to return once stopped, but it did not")
+	}
+}
+
+func TestStopWait(t *testing.T) {
+	p := NewPinger(&Options{}).(*pinger)
+
+	if p.StopWait(10 * time.Millisecond) {
+		t.Error("wanted a forced stop while Ping does not return, got a graceful one")
+	}
+	if !p.forced() {
+		t.Error("wanted the pinger to be forcibly stopped, but it was not")
+	}
+
+	p = NewPinger(&Options{}).(*pinger)
+	close(p.done)
+	if !p.StopWait(time.Minute) {
+		t.Error("wanted a graceful stop once Ping returned, got a forced one")
+	}
+	if p.forced() {
+		t.Error("wanted the pinger not to be forcibly stopped, but it was")
+	}
+}
+
+func TestControl(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %v", err)
+	}
+	defer ln.Close()
+
+	var networks []string
+	p := NewPinger(&Options{
+		TCPPort: uint(ln.Addr().(*net.TCPAddr).Port),
+		Control: func(network, address string, c syscall.RawConn) error {
+			networks = append(networks, network)
+			return nil
+		},
+	}).(*pinger)
+
+	res, err := p.pingTCP(&net.IPAddr{IP: net.IPv4(127, 0, 0, 1)}, 0)
+	if err != nil {
+		t.Fatalf("wanted no error, got %v", err)
+	}
+	if res.Timeout {
+		t.Fatalf("wanted a reply, got a timeout")
+	}
+	if expected := []string{"tcp4"}; !reflect.DeepEqual(networks, expected) {
+		t.Errorf("wanted %v, got %v", expected, networks)
+	}
+}
+
+// rejectNetworks returns an Options.Control function failing to open
+// sockets of the given networks as if not permitted to.
+func rejectNetworks(networks ...string) func(string, string, syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		for _, n := range networks {
+			if n == network {
+				return syscall.EPERM
+			}
+		}
+		return nil
+	}
+}
+
+func TestListenFallback(t *testing.T) {
+	tests := []struct {
+		desc     string
+		opts     Options
+		expected Method
+		wantErr  bool
+	}{
+		{
+			desc:     "raw and datagram sockets not permitted, with TCP fallback",
+			opts:     Options{TCPPort: 80, Control: rejectNetworks("ip4", "udp4")},
+			expected: TCPConnect,
+		},
+		{
+			desc:     "unprivileged and datagram sockets not permitted, with TCP fallback",
+			opts:     Options{Unprivileged: true, TCPPort: 80, Control: rejectNetworks("udp4")},
+			expected: TCPConnect,
+		},
+		{
+			desc:    "raw and datagram sockets not permitted, without TCP fallback",
+			opts:    Options{Control: rejectNetworks("ip4", "udp4")},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			p := NewPinger(&tc.opts).(*pinger)
+			conn, err := p.listen()
+			if tc.wantErr {
+				if err == nil {
+					conn.Close()
+					t.Fatalf("wanted an error, got a %v connection", conn.method)
+				}
+				if _, ok := err.(*PermissionError); !ok {
+					t.Errorf("wanted a permission error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("wanted no error, got %v", err)
+			}
+			defer conn.Close()
+			if conn.method != tc.expected {
+				t.Errorf("wanted %v, got %v", tc.expected, conn.method)
+			}
+		})
+	}
+}
+
+func TestListenDatagramFallback(t *testing.T) {
+	p := NewPinger(&Options{Control: rejectNetworks("ip4")}).(*pinger)
+	id := p.id
+
+	conn, err := p.listen()
+	var permErr *PermissionError
+	if errors.As(err, &permErr) {
+		t.Skipf("datagram ICMP sockets not permitted: %v", err)
+	}
+	if err != nil {
+		t.Fatalf("wanted no error, got %v", err)
+	}
+	defer conn.Close()
+
+	if conn.method != DatagramICMP {
+		t.Fatalf("wanted %v, got %v", DatagramICMP, conn.method)
+	}
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+	if port != 0 && p.id != port {
+		t.Errorf("wanted the identifier rewritten from %v to the local port %v, got %v", id, port, p.id)
+	}
+}
+
+func TestPingTCPRefused(t *testing.T) {
+	// A port just released is most likely closed.
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %v", err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	p := NewPinger(&Options{TCPPort: uint(port)}).(*pinger)
+	res, err := p.pingTCP(&net.IPAddr{IP: net.IPv4(127, 0, 0, 1)}, 0)
+	if err != nil {
+		t.Fatalf("wanted no error, got %v", err)
+	}
+	if res.Timeout || res.Unreachable {
+		t.Errorf("wanted a refused connection to count as a reply, got %+v", res)
+	}
+	if stats := p.Stats(); stats.Received() != 1 || stats.Transmitted() != 1 {
+		t.Errorf("wanted 1 reply out of 1 request, got %v out of %v", stats.Received(), stats.Transmitted())
+	}
+}
+
+func TestTarget(t *testing.T) {
+	addr := &net.IPAddr{IP: net.IPv4(10, 0, 0, 1)}
+	others := []net.Addr{&net.IPAddr{IP: net.IPv4(10, 0, 0, 2)}, &net.IPAddr{IP: net.IPv4(10, 0, 0, 3)}}
+
+	tests := []struct {
+		desc     string
+		rotate   []net.Addr
+		expected []net.Addr
+	}{
+		{
+			desc:     "single address",
+			expected: []net.Addr{addr, addr, addr, addr},
+		},
+		{
+			desc:     "rotating addresses",
+			rotate:   others,
+			expected: []net.Addr{addr, others[0], others[1], addr},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			p := NewPinger(&Options{Rotate: tc.rotate}).(*pinger)
+			for seq, expected := range tc.expected {
+				if target := p.target(addr, seq); target != expected {
+					t.Errorf("icmp_seq %d: wanted %v, got %v", seq, expected, target)
+				}
+			}
+		})
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	tests := []struct {
+		desc     string
+		opts     Options
+		seq      int
+		expected time.Duration
+	}{
+		{
+			desc:     "without linger",
+			opts:     Options{Timeout: time.Second, Count: 3},
+			seq:      2,
+			expected: time.Second,
+		},
+		{
+			desc:     "final request",
+			opts:     Options{Timeout: time.Second, Count: 3, Linger: 5 * time.Second},
+			seq:      2,
+			expected: 5 * time.Second,
+		},
+		{
+			desc:     "earlier request",
+			opts:     Options{Timeout: time.Second, Count: 3, Linger: 5 * time.Second},
+			seq:      1,
+			expected: time.Second,
+		},
+		{
+			desc:     "linger shorter than timeout",
+			opts:     Options{Timeout: 2 * time.Second, Count: 3, Linger: time.Second},
+			seq:      2,
+			expected: 2 * time.Second,
+		},
+		{
+			desc:     "counting replies",
+			opts:     Options{Timeout: time.Second, Count: 3, CountReplies: true, Linger: 5 * time.Second},
+			seq:      2,
+			expected: time.Second,
+		},
+		{
+			desc:     "final request after warmup",
+			opts:     Options{Timeout: time.Second, Count: 3, Warmup: 2, Linger: 5 * time.Second},
+			seq:      4,
+			expected: 5 * time.Second,
+		},
+		{
+			desc:     "without count",
+			opts:     Options{Timeout: time.Second, Linger: 5 * time.Second},
+			seq:      0,
+			expected: time.Second,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			p := &pinger{opts: &tc.opts}
+			if got := p.timeout(tc.seq); got != tc.expected {
+				t.Errorf("wanted %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestWarmup(t *testing.T) {
+	p := NewPinger(&Options{Warmup: 2}).(*pinger)
+
+	p.updateStats(func(s *Stats) {
+		s.incTimeout(time.Now())
+	})
+	stats := p.Stats()
+	if stats.Transmitted() != 0 {
+		t.Errorf("wanted warmup requests excluded, got %v transmitted", stats.Transmitted())
+	}
+
+	p.endWarmup()
+	p.updateStats(func(s *Stats) {
+		s.incTimeout(time.Now())
+	})
+	stats = p.Stats()
+	if stats.Transmitted() != 1 {
+		t.Errorf("wanted %v transmitted, got %v", 1, stats.Transmitted())
+	}
+	if stats.Warmup() != 2 {
+		t.Errorf("wanted %v warmup requests, got %v", 2, stats.Warmup())
+	}
+}
+
+func TestReachedCountAfterWarmup(t *testing.T) {
+	p := NewPinger(&Options{Warmup: 5, Count: 3, CountReplies: true}).(*pinger)
+
+	for i := 0; i < 3; i++ {
+		p.updateStats(func(s *Stats) {
+			s.incSuccess(time.Millisecond, time.Now())
+		})
+	}
+	if p.reachedCount(3) {
+		t.Errorf("wanted warmup replies excluded from the count")
+	}
+
+	p.endWarmup()
+	for i := 0; i < 3; i++ {
+		p.updateStats(func(s *Stats) {
+			s.incSuccess(time.Millisecond, time.Now())
+		})
+	}
+	if !p.reachedCount(8) {
+		stats := p.Stats()
+		t.Errorf("wanted count reached after %v replies, got %v", 3, stats.Received())
+	}
+}
+
+func TestReachedSuccesses(t *testing.T) {
+	reply := Ping{Category: Reply, RTT: 10 * time.Millisecond}
+	slow := Ping{Category: Reply, RTT: 200 * time.Millisecond}
+	timeout := Ping{Category: NoReply, Timeout: true}
+	unreachable := Ping{Category: DestinationUnreachable, Unreachable: true}
+
+	tests := []struct {
+		desc     string
+		opts     Options
+		requests [][]Ping
+		expected bool
+	}{
+		{
+			desc:     "consecutive replies",
+			opts:     Options{Successes: 3},
+			requests: [][]Ping{{reply}, {reply}, {reply}},
+			expected: true,
+		},
+		{
+			desc:     "too few replies",
+			opts:     Options{Successes: 3},
+			requests: [][]Ping{{reply}, {reply}},
+			expected: false,
+		},
+		{
+			desc:     "replies broken by a timeout",
+			opts:     Options{Successes: 3},
+			requests: [][]Ping{{reply}, {reply}, {timeout}, {reply}, {reply}},
+			expected: false,
+		},
+		{
+			desc:     "replies after a timeout",
+			opts:     Options{Successes: 3},
+			requests: [][]Ping{{timeout}, {reply}, {reply}, {reply}},
+			expected: true,
+		},
+		{
+			desc:     "replies broken by an unreachable host",
+			opts:     Options{Successes: 2},
+			requests: [][]Ping{{reply}, {unreachable}, {reply}},
+			expected: false,
+		},
+		{
+			desc:     "slow replies within the bound",
+			opts:     Options{Successes: 2},
+			requests: [][]Ping{{slow}, {slow}},
+			expected: true,
+		},
+		{
+			desc:     "replies broken by a slow one",
+			opts:     Options{Successes: 2, SuccessRTT: 100 * time.Millisecond},
+			requests: [][]Ping{{reply}, {slow}, {reply}},
+			expected: false,
+		},
+		{
+			desc:     "a fast reply among group replies",
+			opts:     Options{Successes: 2, SuccessRTT: 100 * time.Millisecond},
+			requests: [][]Ping{{slow, reply}, {reply}},
+			expected: true,
+		},
+		{
+			desc:     "without successes",
+			opts:     Options{},
+			requests: [][]Ping{{reply}, {reply}, {reply}},
+			expected: false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			p := NewPinger(&tc.opts).(*pinger)
+			for _, pings := range tc.requests {
+				p.countSuccesses(pings)
+			}
+			if got := p.reachedSuccesses(); got != tc.expected {
+				stats := p.Stats()
+				t.Errorf("wanted %v, got %v after a run of %v", tc.expected, got, stats.SuccessRun())
+			}
+		})
+	}
+}
+
+func TestReachedSuccessesAfterWarmup(t *testing.T) {
+	p := NewPinger(&Options{Warmup: 2, Successes: 2}).(*pinger)
+	reply := Ping{Category: Reply, RTT: time.Millisecond}
+
+	p.countSuccesses([]Ping{reply})
+	p.countSuccesses([]Ping{reply})
+	if p.reachedSuccesses() {
+		t.Errorf("wanted warmup replies excluded from the successes")
+	}
+
+	p.endWarmup()
+	p.countSuccesses([]Ping{reply})
+	p.countSuccesses([]Ping{reply})
+	if !p.reachedSuccesses() {
+		stats := p.Stats()
+		t.Errorf("wanted successes reached after %v replies, got a run of %v", 2, stats.SuccessRun())
+	}
+}
+
+func TestPingTCPAddress(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %v", err)
+	}
+	defer ln.Close()
+	p := NewPinger(&Options{TCPPort: uint(ln.Addr().(*net.TCPAddr).Port)}).(*pinger)
+
+	res, err := p.pingTCP(&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1}, 0)
+	if err != nil {
+		t.Fatalf("wanted no error, got %v", err)
+	}
+	if res.Timeout || res.Unreachable {
+		t.Errorf("wanted a reply, got %v", res)
+	}
+
+	if _, err := p.pingTCP(&net.UnixAddr{Name: "/tmp/pingo", Net: "unix"}, 0); err == nil {
+		t.Errorf("wanted an error for an unsupported address")
+	}
+}
+
+func TestValidateTOS(t *testing.T) {
+	tests := []struct {
+		desc    string
+		opts    Options
+		wantErr bool
+	}{
+		{
+			desc: "unmarked",
+			opts: Options{},
+		},
+		{
+			desc: "DSCP and ECT(0)",
+			opts: Options{DSCP: 46, ECN: ECT0},
+		},
+		{
+			desc:    "DSCP out of range",
+			opts:    Options{DSCP: 64},
+			wantErr: true,
+		},
+		{
+			desc:    "CE",
+			opts:    Options{ECN: CE},
+			wantErr: true,
+		},
+		{
+			desc:    "ECN out of range",
+			opts:    Options{ECN: 7},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := tc.opts.validateTOS()
+			if (err != nil) != tc.wantErr {
+				t.Errorf("wanted error %v, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestPingInvalidTOS(t *testing.T) {
+	p := NewPinger(&Options{ECN: CE})
+	results, errs := p.Report()
+	go p.Ping(&net.IPAddr{IP: net.IPv4(127, 0, 0, 1)})
+
+	for range results {
+		t.Error("wanted no results")
+	}
+	if err, ok := <-errs; !ok || err == nil {
+		t.Errorf("wanted an error for an invalid ECN codepoint, got %v", err)
+	}
+}
@@ -0,0 +1,44 @@
+package pinger
+
+import "github.com/caiofilipini/pingo/math"
+
+const (
+	// minRateLimitBursts is the number of bursts of lost packets needed
+	// for telling evenly spaced losses apart from random ones.
+	minRateLimitBursts = 4
+
+	// maxRateLimitSpread is the coefficient of variation up to which the
+	// spacing between bursts of lost packets is considered even.
+	maxRateLimitSpread = 0.2
+)
+
+// RateLimited returns whether the host may be rate limiting ICMP, i.e.
+// whether the most recent requests that went unanswered were lost in
+// bursts spaced evenly, as a token bucket running dry does, while the
+// round-trip latencies stayed near their floor, unlike with congestion.
+// It is always false with Options.DiscardSamples set.
+func (s *Stats) RateLimited() bool {
+	missing := s.Missing()
+	var starts []float64
+	for i, seq := range missing {
+		if i == 0 || seq != missing[i-1]+1 {
+			starts = append(starts, float64(seq))
+		}
+	}
+	if len(starts) < minRateLimitBursts || s.successCount == 0 {
+		return false
+	}
+
+	gaps := make([]float64, len(starts)-1)
+	for i := range gaps {
+		gaps[i] = starts[i+1] - starts[i]
+	}
+	if math.StdDev(gaps) > maxRateLimitSpread*math.Mean(gaps) {
+		return false
+	}
+
+	// Congestion builds up queues, inflating latencies, before packets
+	// are dropped.
+	min, _, _, _ := s.RTTStats()
+	return s.Percentiles(95)[0] <= 2*min+1
+}
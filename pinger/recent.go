@@ -0,0 +1,44 @@
+package pinger
+
+import "sync"
+
+// recentResults is a ring buffer holding the most recent results reported
+// by a Pinger, safe for concurrent use.
+type recentResults struct {
+	mu    sync.Mutex
+	pings []Ping
+	next  int
+	full  bool
+}
+
+// newRecentResults returns a recentResults holding up to size results.
+func newRecentResults(size uint) *recentResults {
+	return &recentResults{
+		pings: make([]Ping, size),
+	}
+}
+
+// add adds ping to the ring, overwriting the oldest result if full.
+func (r *recentResults) add(ping Ping) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.pings) == 0 {
+		return
+	}
+
+	r.pings[r.next] = ping
+	r.next = (r.next + 1) % len(r.pings)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// list returns a copy of the results in the ring, oldest first.
+func (r *recentResults) list() []Ping {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.full {
+		return append([]Ping(nil), r.pings[:r.next]...)
+	}
+	return append(append([]Ping(nil), r.pings[r.next:]...), r.pings[:r.next]...)
+}
@@ -0,0 +1,57 @@
+package pinger
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRecentResults(t *testing.T) {
+	tests := []struct {
+		desc     string
+		size     uint
+		added    int
+		expected []int
+	}{
+		{
+			desc:     "disabled",
+			size:     0,
+			added:    3,
+			expected: nil,
+		},
+		{
+			desc:     "not full",
+			size:     3,
+			added:    2,
+			expected: []int{0, 1},
+		},
+		{
+			desc:     "full",
+			size:     3,
+			added:    3,
+			expected: []int{0, 1, 2},
+		},
+		{
+			desc:     "wrapped around",
+			size:     3,
+			added:    5,
+			expected: []int{2, 3, 4},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			r := newRecentResults(tc.size)
+			for seq := 0; seq < tc.added; seq++ {
+				r.add(Ping{Seq: seq})
+			}
+
+			var seqs []int
+			for _, ping := range r.list() {
+				seqs = append(seqs, ping.Seq)
+			}
+			if !reflect.DeepEqual(seqs, tc.expected) {
+				t.Errorf("wanted %v, got %v", tc.expected, seqs)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package pinger
+
+import (
+	"net"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+// Redirect describes an ICMP Redirect received in response to a request,
+// by which a router suggests a better first hop towards a destination.
+type Redirect struct {
+	// From is the address of the router that sent the redirect.
+	From net.Addr
+
+	// Code is the redirect code: 0 for the network, 1 for the host, or
+	// 2 and 3 for the network and host for the request's type of service.
+	Code int
+
+	// Gateway is the suggested first hop.
+	Gateway net.IP
+
+	// Destination is the destination of the request the redirect applies
+	// to.
+	Destination net.IP
+}
+
+// parseRedirect returns the Redirect held by res, received from peer, or
+// nil if res is not a redirect.
+func parseRedirect(res *icmp.Message, peer net.Addr) *Redirect {
+	body, ok := res.Body.(*icmp.RawBody)
+	if !ok || res.Type != ipv4.ICMPTypeRedirect || len(body.Data) < 4+ipv4.HeaderLen {
+		return nil
+	}
+
+	quoted := body.Data[4:]
+	return &Redirect{
+		From:        peer,
+		Code:        res.Code,
+		Gateway:     net.IP(append([]byte(nil), body.Data[:4]...)),
+		Destination: net.IP(append([]byte(nil), quoted[16:20]...)),
+	}
+}
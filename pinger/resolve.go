@@ -0,0 +1,87 @@
+package pinger
+
+import (
+	"context"
+	"fmt"
+	"net"
+)
+
+// Resolver resolves host names to the addresses of the hosts to ping.
+type Resolver interface {
+	// Resolve resolves host, either a name or an IP address, to its
+	// addresses, in order of preference. Addresses are *net.IPAddr, as
+	// returned by the system resolver, or *net.UDPAddr or *net.TCPAddr,
+	// whose ports are ignored; pinging any other type fails.
+	Resolve(ctx context.Context, host string) ([]net.Addr, error)
+}
+
+// systemResolver resolves hosts to their IPv4 addresses with the system
+// resolver.
+type systemResolver struct{}
+
+func (systemResolver) Resolve(ctx context.Context, host string) ([]net.Addr, error) {
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	var addrs []net.Addr
+	for _, ip := range ips {
+		if ip.IP.To4() != nil {
+			addrs = append(addrs, &net.IPAddr{IP: ip.IP.To4()})
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no IPv4 address found for %s", host)
+	}
+	return addrs, nil
+}
+
+// Resolve resolves host to the address to ping with Options.Resolver, or
+// the system resolver if not set, picking the preferred address.
+func (o *Options) Resolve(ctx context.Context, host string) (net.Addr, error) {
+	addrs, err := o.ResolveAll(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	return addrs[0], nil
+}
+
+// ResolveAll resolves host to all of its addresses with Options.Resolver,
+// or the system resolver if not set, in order of preference, e.g. for
+// Options.Rotate. At least one address is returned unless it fails.
+func (o *Options) ResolveAll(ctx context.Context, host string) ([]net.Addr, error) {
+	var r Resolver = systemResolver{}
+	if o.Resolver != nil {
+		r = o.Resolver
+	}
+
+	addrs, err := r.Resolve(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no address found for %s", host)
+	}
+	return addrs, nil
+}
+
+// IPAddrOf returns the IP address of addr, one of the types of addresses
+// a Resolver may return and replies may come from.
+func IPAddrOf(addr net.Addr) (*net.IPAddr, error) {
+	switch a := addr.(type) {
+	case *net.IPAddr:
+		return a, nil
+	case *net.UDPAddr:
+		return &net.IPAddr{IP: a.IP, Zone: a.Zone}, nil
+	case *net.TCPAddr:
+		return &net.IPAddr{IP: a.IP, Zone: a.Zone}, nil
+	default:
+		return nil, fmt.Errorf("unsupported address %v of type %T", addr, addr)
+	}
+}
+
+// Resolve resolves the given host to a net.Addr with the system resolver.
+func Resolve(host string) (net.Addr, error) {
+	return (&Options{}).Resolve(context.Background(), host)
+}
@@ -0,0 +1,115 @@
+package pinger
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+)
+
+// fakeResolver resolves every host to the same addresses.
+type fakeResolver struct {
+	addrs []net.Addr
+	err   error
+}
+
+func (r fakeResolver) Resolve(ctx context.Context, host string) ([]net.Addr, error) {
+	return r.addrs, r.err
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		desc     string
+		resolver Resolver
+		host     string
+		expected string
+		ok       bool
+	}{
+		{
+			desc:     "system resolver with an IP address",
+			host:     "127.0.0.1",
+			expected: "127.0.0.1",
+			ok:       true,
+		},
+		{
+			desc: "custom resolver",
+			resolver: fakeResolver{addrs: []net.Addr{
+				&net.IPAddr{IP: net.IPv4(10, 0, 0, 1)},
+				&net.IPAddr{IP: net.IPv4(10, 0, 0, 2)},
+			}},
+			host:     "example.com",
+			expected: "10.0.0.1",
+			ok:       true,
+		},
+		{
+			desc:     "custom resolver failing",
+			resolver: fakeResolver{err: errors.New("no such host")},
+			host:     "example.com",
+			ok:       false,
+		},
+		{
+			desc:     "custom resolver finding no addresses",
+			resolver: fakeResolver{},
+			host:     "example.com",
+			ok:       false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := &Options{Resolver: tc.resolver}
+			addr, err := opts.Resolve(context.Background(), tc.host)
+			if (err == nil) != tc.ok {
+				t.Fatalf("wanted %v, got %v (%v)", tc.ok, err == nil, err)
+			}
+			if tc.ok && addr.String() != tc.expected {
+				t.Errorf("wanted %v, got %v", tc.expected, addr)
+			}
+		})
+	}
+}
+
+func TestIPAddrOf(t *testing.T) {
+	tests := []struct {
+		desc     string
+		addr     net.Addr
+		expected string
+		ok       bool
+	}{
+		{
+			desc:     "IP address",
+			addr:     &net.IPAddr{IP: net.IPv4(10, 0, 0, 1)},
+			expected: "10.0.0.1",
+			ok:       true,
+		},
+		{
+			desc:     "UDP address",
+			addr:     &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 862},
+			expected: "10.0.0.1",
+			ok:       true,
+		},
+		{
+			desc:     "TCP address",
+			addr:     &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 80},
+			expected: "10.0.0.1",
+			ok:       true,
+		},
+		{
+			desc: "unsupported address",
+			addr: &net.UnixAddr{Name: "/tmp/pingo", Net: "unix"},
+			ok:   false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			ipAddr, err := IPAddrOf(tc.addr)
+			if (err == nil) != tc.ok {
+				t.Fatalf("wanted %v, got %v (%v)", tc.ok, err == nil, err)
+			}
+			if tc.ok && ipAddr.String() != tc.expected {
+				t.Errorf("wanted %v, got %v", tc.expected, ipAddr)
+			}
+		})
+	}
+}
@@ -0,0 +1,19 @@
+package pinger
+
+// seqSpace is the number of distinct sequence numbers on the wire, whose
+// ICMP field is 16 bits long.
+const seqSpace = 1 << 16
+
+// wireSeq returns the 16-bit sequence number carried on the wire by the
+// request identified by the logical sequence number seq, which keeps
+// growing past it during long runs.
+func wireSeq(seq int) int {
+	return seq & (seqSpace - 1)
+}
+
+// logicalSeq returns the logical sequence number of the most recent
+// request, up to seq, carrying wire on the wire. It is negative if no
+// such request was sent yet.
+func logicalSeq(wire int, seq int) int {
+	return seq - (wireSeq(seq)-wireSeq(wire))&(seqSpace-1)
+}
@@ -0,0 +1,88 @@
+package pinger
+
+import "testing"
+
+func TestWireSeq(t *testing.T) {
+	tests := []struct {
+		desc     string
+		seq      int
+		expected int
+	}{
+		{
+			desc:     "fits in 16 bits",
+			seq:      42,
+			expected: 42,
+		},
+		{
+			desc:     "largest in 16 bits",
+			seq:      seqSpace - 1,
+			expected: seqSpace - 1,
+		},
+		{
+			desc:     "wraps around",
+			seq:      seqSpace,
+			expected: 0,
+		},
+		{
+			desc:     "wraps around repeatedly",
+			seq:      3*seqSpace + 42,
+			expected: 42,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			if actual := wireSeq(tc.seq); actual != tc.expected {
+				t.Errorf("wanted %v, got %v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestLogicalSeq(t *testing.T) {
+	tests := []struct {
+		desc     string
+		wire     int
+		seq      int
+		expected int
+	}{
+		{
+			desc:     "current request",
+			wire:     42,
+			seq:      42,
+			expected: 42,
+		},
+		{
+			desc:     "earlier request",
+			wire:     40,
+			seq:      42,
+			expected: 40,
+		},
+		{
+			desc:     "current request after the wraparound",
+			wire:     42,
+			seq:      2*seqSpace + 42,
+			expected: 2*seqSpace + 42,
+		},
+		{
+			desc:     "request before the wraparound",
+			wire:     seqSpace - 1,
+			seq:      seqSpace + 1,
+			expected: seqSpace - 1,
+		},
+		{
+			desc:     "request not sent yet",
+			wire:     43,
+			seq:      42,
+			expected: 43 - seqSpace,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			if actual := logicalSeq(tc.wire, tc.seq); actual != tc.expected {
+				t.Errorf("wanted %v, got %v", tc.expected, actual)
+			}
+		})
+	}
+}
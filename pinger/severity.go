@@ -0,0 +1,58 @@
+package pinger
+
+import "time"
+
+// Severity is how bad a round-trip latency is, according to
+// Options.WarnRTT and Options.CritRTT.
+type Severity int
+
+const (
+	// SeverityOK is for RTTs under either threshold.
+	SeverityOK Severity = iota
+
+	// SeverityWarn is for RTTs at or above Options.WarnRTT, but under
+	// Options.CritRTT.
+	SeverityWarn
+
+	// SeverityCrit is for RTTs at or above Options.CritRTT.
+	SeverityCrit
+)
+
+// String returns a human readable name for the severity.
+func (s Severity) String() string {
+	switch s {
+	case SeverityOK:
+		return "ok"
+	case SeverityWarn:
+		return "warn"
+	case SeverityCrit:
+		return "crit"
+	default:
+		return "unknown"
+	}
+}
+
+// severityOf returns the severity of rtt against the warn and crit
+// thresholds, either of which is disabled if zero.
+func severityOf(rtt time.Duration, warn time.Duration, crit time.Duration) Severity {
+	switch {
+	case crit > 0 && rtt >= crit:
+		return SeverityCrit
+	case warn > 0 && rtt >= warn:
+		return SeverityWarn
+	default:
+		return SeverityOK
+	}
+}
+
+// severity returns the severity of rtt against the thresholds in the
+// options.
+func (p *pinger) severity(rtt time.Duration) Severity {
+	return severityOf(rtt, p.opts.WarnRTT, p.opts.CritRTT)
+}
+
+// Severities returns the number of RTTs at or above Options.WarnRTT but
+// under Options.CritRTT, and of those at or above Options.CritRTT.
+func (s *Stats) Severities() (int, int) {
+	return s.warnCount, s.critCount
+}
@@ -0,0 +1,63 @@
+package pinger
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSeverityOf(t *testing.T) {
+	tests := []struct {
+		desc     string
+		rtt      time.Duration
+		warn     time.Duration
+		crit     time.Duration
+		expected Severity
+	}{
+		{
+			desc:     "ok without thresholds",
+			rtt:      time.Second,
+			expected: SeverityOK,
+		},
+		{
+			desc:     "ok under the warn threshold",
+			rtt:      49 * time.Millisecond,
+			warn:     50 * time.Millisecond,
+			crit:     150 * time.Millisecond,
+			expected: SeverityOK,
+		},
+		{
+			desc:     "warn at the warn threshold",
+			rtt:      50 * time.Millisecond,
+			warn:     50 * time.Millisecond,
+			crit:     150 * time.Millisecond,
+			expected: SeverityWarn,
+		},
+		{
+			desc:     "crit at the crit threshold",
+			rtt:      150 * time.Millisecond,
+			warn:     50 * time.Millisecond,
+			crit:     150 * time.Millisecond,
+			expected: SeverityCrit,
+		},
+		{
+			desc:     "crit without a warn threshold",
+			rtt:      200 * time.Millisecond,
+			crit:     150 * time.Millisecond,
+			expected: SeverityCrit,
+		},
+		{
+			desc:     "warn without a crit threshold",
+			rtt:      time.Second,
+			warn:     50 * time.Millisecond,
+			expected: SeverityWarn,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			if actual := severityOf(tc.rtt, tc.warn, tc.crit); actual != tc.expected {
+				t.Errorf("wanted %v, got %v", tc.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+//go:build linux
+
+package pinger
+
+import (
+	"encoding/binary"
+	"syscall"
+	"time"
+)
+
+// setRecvTOS enables reporting the TOS byte of received packets through
+// socket control messages.
+func setRecvTOS(conn syscall.Conn) error {
+	return setsockoptInt(conn, syscall.IPPROTO_IP, syscall.IP_RECVTOS, 1)
+}
+
+// setRecvTTL enables reporting the TTL of received packets through socket
+// control messages.
+func setRecvTTL(conn syscall.Conn) error {
+	return setsockoptInt(conn, syscall.IPPROTO_IP, syscall.IP_RECVTTL, 1)
+}
+
+// setRecvTimestamp enables reporting the time packets are received at by
+// the kernel through socket control messages.
+func setRecvTimestamp(conn syscall.Conn) error {
+	return setsockoptInt(conn, syscall.SOL_SOCKET, syscall.SO_TIMESTAMPNS, 1)
+}
+
+// parseTOS returns the TOS byte reported in the socket control messages
+// in oob, if any.
+func parseTOS(oob []byte) (int, bool) {
+	data, ok := controlMessage(oob, syscall.IPPROTO_IP, syscall.IP_TOS)
+	if !ok {
+		return 0, false
+	}
+	return int(data[0]), true
+}
+
+// parseTTL returns the TTL reported in the socket control messages in oob,
+// if any.
+func parseTTL(oob []byte) (int, bool) {
+	data, ok := controlMessage(oob, syscall.IPPROTO_IP, syscall.IP_TTL)
+	if !ok || len(data) < 4 {
+		return 0, false
+	}
+	return int(binary.NativeEndian.Uint32(data)), true
+}
+
+// parseTimestamp returns the time the kernel received the packet at, as
+// reported in the socket control messages in oob, if any.
+func parseTimestamp(oob []byte) (time.Time, bool) {
+	data, ok := controlMessage(oob, syscall.SOL_SOCKET, syscall.SCM_TIMESTAMPNS)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	// The data is a struct timespec, whose fields are as wide as a word.
+	switch len(data) {
+	case 16:
+		sec := int64(binary.NativeEndian.Uint64(data[0:8]))
+		nsec := int64(binary.NativeEndian.Uint64(data[8:16]))
+		return time.Unix(sec, nsec), true
+	case 8:
+		sec := int64(int32(binary.NativeEndian.Uint32(data[0:4])))
+		nsec := int64(int32(binary.NativeEndian.Uint32(data[4:8])))
+		return time.Unix(sec, nsec), true
+	default:
+		return time.Time{}, false
+	}
+}
+
+// controlMessage returns the data of the socket control message of the
+// given level and type in oob, if any.
+func controlMessage(oob []byte, level int32, typ int32) ([]byte, bool) {
+	msgs, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return nil, false
+	}
+
+	for _, m := range msgs {
+		if m.Header.Level == level && m.Header.Type == typ && len(m.Data) > 0 {
+			return m.Data, true
+		}
+	}
+	return nil, false
+}
+
+// setBroadcast enables sending packets to broadcast addresses.
+func setBroadcast(conn syscall.Conn) error {
+	return setsockoptInt(conn, syscall.SOL_SOCKET, syscall.SO_BROADCAST, 1)
+}
+
+// setIPOptions sets the options included in the IPv4 header of packets
+// sent through conn to b.
+func setIPOptions(conn syscall.Conn, b []byte) error {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var serr error
+	err = raw.Control(func(fd uintptr) {
+		serr = syscall.SetsockoptString(int(fd), syscall.IPPROTO_IP, syscall.IP_OPTIONS, string(b))
+	})
+	if err != nil {
+		return err
+	}
+	return serr
+}
+
+// setsockoptInt sets an integer socket option on the socket underlying conn.
+func setsockoptInt(conn syscall.Conn, level int, opt int, value int) error {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var serr error
+	err = raw.Control(func(fd uintptr) {
+		serr = syscall.SetsockoptInt(int(fd), level, opt, value)
+	})
+	if err != nil {
+		return err
+	}
+	return serr
+}
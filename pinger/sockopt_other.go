@@ -0,0 +1,53 @@
+//go:build !linux
+
+package pinger
+
+import (
+	"fmt"
+	"runtime"
+	"syscall"
+	"time"
+)
+
+// setRecvTOS is not supported outside of Linux, where outgoing packets are
+// still marked, but replies are reported without their TOS.
+func setRecvTOS(conn syscall.Conn) error {
+	return nil
+}
+
+// setRecvTTL is not supported outside of Linux, where replies are reported
+// without their TTL.
+func setRecvTTL(conn syscall.Conn) error {
+	return nil
+}
+
+// setRecvTimestamp is not supported outside of Linux, where the time
+// packets are received at is taken once read instead.
+func setRecvTimestamp(conn syscall.Conn) error {
+	return nil
+}
+
+// parseTimestamp is not supported outside of Linux.
+func parseTimestamp(oob []byte) (time.Time, bool) {
+	return time.Time{}, false
+}
+
+// parseTTL is not supported outside of Linux.
+func parseTTL(oob []byte) (int, bool) {
+	return 0, false
+}
+
+// parseTOS is not supported outside of Linux.
+func parseTOS(oob []byte) (int, bool) {
+	return 0, false
+}
+
+// setBroadcast is not supported outside of Linux.
+func setBroadcast(conn syscall.Conn) error {
+	return fmt.Errorf("broadcast is not supported on %s", runtime.GOOS)
+}
+
+// setIPOptions is not supported outside of Linux.
+func setIPOptions(conn syscall.Conn, b []byte) error {
+	return fmt.Errorf("IP options are not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,31 @@
+package pinger
+
+import (
+	"time"
+
+	"github.com/caiofilipini/pingo/math"
+)
+
+// SmoothedRTT returns, respectively, the smoothed round-trip latency (SRTT)
+// and its variation (RTTVAR), in milliseconds, computed as exponentially
+// weighted moving averages the way TCP does it (RFC 6298).
+func (s *Stats) SmoothedRTT() (float64, float64) {
+	return math.TimeInMillis(s.srtt), math.TimeInMillis(s.rttvar)
+}
+
+// updateSmoothedRTT updates SRTT and RTTVAR with a new RTT sample, using
+// the gains recommended by RFC 6298 (alpha = 1/8 and beta = 1/4).
+func (s *Stats) updateSmoothedRTT(rtt time.Duration) {
+	if s.srtt == 0 {
+		s.srtt = rtt
+		s.rttvar = rtt / 2
+		return
+	}
+
+	dev := s.srtt - rtt
+	if dev < 0 {
+		dev = -dev
+	}
+	s.rttvar = (3*s.rttvar + dev) / 4
+	s.srtt = (7*s.srtt + rtt) / 8
+}
@@ -0,0 +1,63 @@
+package pinger
+
+import "time"
+
+const (
+	// DefaultFlapThreshold is the default number of outages within
+	// FlapWindow for the host to be considered flapping.
+	DefaultFlapThreshold = uint(3)
+
+	// DefaultFlapWindow is the default window within which outages are
+	// counted for detecting flapping.
+	DefaultFlapWindow = 10 * time.Minute
+)
+
+// State represents the reachability of the host being pinged.
+type State int
+
+const (
+	// Up means the host is reachable.
+	Up State = iota
+
+	// Down means the host is unreachable, i.e. an outage is ongoing.
+	Down
+
+	// Flapping means the host has been oscillating between reachable and
+	// unreachable, i.e. at least Options.FlapThreshold outages started
+	// within Options.FlapWindow.
+	Flapping
+)
+
+// String returns a human readable name for the state.
+func (s State) String() string {
+	switch s {
+	case Up:
+		return "up"
+	case Down:
+		return "down"
+	case Flapping:
+		return "flapping"
+	default:
+		return "unknown"
+	}
+}
+
+// stateAt returns the state of the host at the given time, given the
+// outages detected up to then.
+func stateAt(outages []Outage, now time.Time, opts *Options) State {
+	recent := 0
+	for _, o := range outages {
+		if now.Sub(o.Start) <= opts.FlapWindow {
+			recent++
+		}
+	}
+
+	switch {
+	case opts.FlapThreshold > 0 && recent >= int(opts.FlapThreshold):
+		return Flapping
+	case len(outages) > 0 && outages[len(outages)-1].Ongoing():
+		return Down
+	default:
+		return Up
+	}
+}
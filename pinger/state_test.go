@@ -0,0 +1,78 @@
+package pinger
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStateAt(t *testing.T) {
+	start := time.Unix(1500000000, 0)
+	opts := &Options{
+		FlapThreshold: 2,
+		FlapWindow:    time.Minute,
+	}
+
+	tests := []struct {
+		desc     string
+		outages  []Outage
+		now      time.Time
+		expected State
+	}{
+		{
+			desc:     "up without outages",
+			now:      start,
+			expected: Up,
+		},
+		{
+			desc: "up after an outage",
+			outages: []Outage{
+				{Start: start, End: start.Add(5 * time.Second)},
+			},
+			now:      start.Add(10 * time.Second),
+			expected: Up,
+		},
+		{
+			desc: "down during an outage",
+			outages: []Outage{
+				{Start: start},
+			},
+			now:      start.Add(10 * time.Second),
+			expected: Down,
+		},
+		{
+			desc: "flapping after outages within the window",
+			outages: []Outage{
+				{Start: start, End: start.Add(5 * time.Second)},
+				{Start: start.Add(20 * time.Second), End: start.Add(25 * time.Second)},
+			},
+			now:      start.Add(30 * time.Second),
+			expected: Flapping,
+		},
+		{
+			desc: "flapping during an outage within the window",
+			outages: []Outage{
+				{Start: start, End: start.Add(5 * time.Second)},
+				{Start: start.Add(20 * time.Second)},
+			},
+			now:      start.Add(30 * time.Second),
+			expected: Flapping,
+		},
+		{
+			desc: "down once previous outages are outside the window",
+			outages: []Outage{
+				{Start: start, End: start.Add(5 * time.Second)},
+				{Start: start.Add(20 * time.Second)},
+			},
+			now:      start.Add(2 * time.Minute),
+			expected: Down,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			if s := stateAt(tc.outages, tc.now, opts); s != tc.expected {
+				t.Errorf("wanted %v, got %v", tc.expected, s)
+			}
+		})
+	}
+}
@@ -3,14 +3,96 @@ package pinger
 import (
 	"time"
 
+	"github.com/HdrHistogram/hdrhistogram-go"
 	"github.com/caiofilipini/pingo/math"
 )
 
-// Stats stores the packet statistics.
+const (
+	// minPeriodicity is the autocorrelation from which round-trip
+	// latencies are considered periodic.
+	minPeriodicity = 0.5
+
+	// minPeriods is the number of periods latencies must span for a
+	// periodic pattern to be detected.
+	minPeriods = 3
+
+	// DefaultMaxSamples is the default number of most recent samples kept
+	// in the stats.
+	DefaultMaxSamples = uint(10000)
+)
+
+// Stats stores the packet statistics. Most of them are accumulated as they
+// are recorded, in constant memory, while only the most recent samples are
+// kept, e.g. for RTTs, outages and Periodicity. The zero value is not
+// usable, neither for recording stats nor as the prev of Since: stats are
+// created with newStats, and read out of the snapshots pingers return.
 type Stats struct {
-	totalCount   int
-	successCount int
-	rtts         []time.Duration
+	totalCount      int
+	successCount    int
+	anomalyCount    int
+	warnCount       int
+	critCount       int
+	checksumErrors  int
+	timeouts        int
+	errors          int
+	duplicates      int
+	corrupted       int
+	missing         []int
+	missingCount    int
+	late            []int
+	lateCount       int
+	warmupCount     int
+	rtts            []time.Duration
+	rtt             math.Moments
+	rttMin          time.Duration
+	rttMax          time.Duration
+	trend           math.Regression
+	trendStart      time.Time
+	forward         math.Moments
+	reverse         math.Moments
+	bandwidths      *math.TDigest
+	srtt            time.Duration
+	rttvar          time.Duration
+	bursts          []int
+	burstCount      int
+	burstSum        int
+	burstMax        int
+	lossRun         int
+	successRun      int
+	lossStart       time.Time
+	outages         []Outage
+	outageCount     int
+	longestOutage   Outage
+	minOutages      int
+	buckets         []Bucket
+	bucketWidth     time.Duration
+	coarse          []Bucket
+	coarseWidth     time.Duration
+	outageThreshold int
+	responders      map[string]int
+	hist            *hdrhistogram.Histogram
+	quantiles       *math.TDigest
+	maxSamples      int
+	startedAt       time.Time
+	stoppedAt       time.Time
+}
+
+// newStats returns a new Stats recording RTTs into a histogram with the
+// given number of significant value digits, detecting outages of at least
+// outageThreshold consecutive lost packets, summarizing requests into time
+// buckets of bucketWidth, downsampled into buckets of coarseWidth as they
+// age, and keeping up to maxSamples of the most recent samples and buckets,
+// or none if 0.
+func newStats(histDigits uint, outageThreshold uint, maxSamples uint, bucketWidth time.Duration, coarseWidth time.Duration) *Stats {
+	return &Stats{
+		hist:            newHistogram(histDigits),
+		quantiles:       math.NewTDigest(math.DefaultCompression),
+		bandwidths:      math.NewTDigest(math.DefaultCompression),
+		outageThreshold: int(outageThreshold),
+		maxSamples:      int(maxSamples),
+		bucketWidth:     bucketWidth,
+		coarseWidth:     coarseWidth,
+	}
 }
 
 // Transmitted returns the total number of packets transmitted.
@@ -23,35 +105,401 @@ func (s *Stats) Received() int {
 	return s.successCount
 }
 
+// Warmup returns the number of warmup requests excluded from the stats.
+func (s *Stats) Warmup() int {
+	return s.warmupCount
+}
+
+// SuccessRun returns the number of consecutive requests most recently
+// replied to within Options.SuccessRTT, if set.
+func (s *Stats) SuccessRun() int {
+	return s.successRun
+}
+
+// Anomalies returns the number of RTTs flagged as anomalous.
+func (s *Stats) Anomalies() int {
+	return s.anomalyCount
+}
+
+// ChecksumErrors returns the number of ICMP messages received with an
+// invalid checksum, which are discarded.
+func (s *Stats) ChecksumErrors() int {
+	return s.checksumErrors
+}
+
+// Timeouts returns the number of requests that timed out without any
+// response.
+func (s *Stats) Timeouts() int {
+	return s.timeouts
+}
+
+// Errors returns the number of requests answered with an ICMP error
+// message (or, when timing TCP handshakes, that failed), instead of a
+// reply. Every request transmitted is either received, timed out or
+// answered with an error.
+func (s *Stats) Errors() int {
+	return s.errors
+}
+
+// Duplicates returns the number of replies received to requests that were
+// already replied to, which are discarded.
+func (s *Stats) Duplicates() int {
+	return s.duplicates
+}
+
+// Missing returns the sequence numbers of the most recent requests that
+// timed out and were never replied to, in the order they were sent.
+func (s *Stats) Missing() []int {
+	late := make(map[int]bool, len(s.late))
+	for _, seq := range s.late {
+		late[seq] = true
+	}
+
+	var missing []int
+	for _, seq := range s.missing[s.recentFrom(len(s.missing)):] {
+		if !late[seq] {
+			missing = append(missing, seq)
+		}
+	}
+	return missing
+}
+
+// Late returns the sequence numbers of the most recent requests that timed
+// out, but were replied to afterwards, in the order the replies were
+// received.
+func (s *Stats) Late() []int {
+	return append([]int(nil), s.late[s.recentFrom(len(s.late)):]...)
+}
+
+// Corrupted returns the number of replies discarded for being corrupted in
+// transit, either with an invalid checksum or with a payload other than
+// the one sent.
+func (s *Stats) Corrupted() int {
+	return s.checksumErrors + s.corrupted
+}
+
 // PacketLoss calculates and returns the percentage of packets that have been
 // lost (i.e. a packet was sent, but a reply was not received due to a timeout).
 func (s *Stats) PacketLoss() float64 {
+	if s.totalCount == 0 {
+		return 0
+	}
 	return (1 - float64(s.successCount)/float64(s.totalCount)) * 100
 }
 
+// RTTs returns a copy of the most recent round-trip latencies recorded, up
+// to Options.MaxSamples of them, in the order the replies were received.
+func (s *Stats) RTTs() []time.Duration {
+	return append([]time.Duration(nil), s.rtts[s.recentFrom(len(s.rtts)):]...)
+}
+
+// Count returns the number of round-trip latencies recorded.
+func (s *Stats) Count() int {
+	return s.rtt.Count()
+}
+
 // RTTStats calculates and returns, respectively, the min, average, max and
 // standard deviation for round-trip latencies.
 func (s *Stats) RTTStats() (float64, float64, float64, float64) {
-	rttsInMillis := make([]float64, len(s.rtts))
-	for i, rtt := range s.rtts {
-		rttsInMillis[i] = math.TimeInMillis(rtt)
+	if s.rtt.Count() == 0 {
+		return 0, 0, 0, 0
+	}
+	return math.TimeInMillis(s.rttMin), s.rtt.Mean() / float64(time.Millisecond),
+		math.TimeInMillis(s.rttMax), s.rtt.StdDev() / float64(time.Millisecond)
+}
+
+// RTTSummary summarizes the round-trip latencies.
+type RTTSummary struct {
+	Min    time.Duration
+	Avg    time.Duration
+	Max    time.Duration
+	StdDev time.Duration
+	Median time.Duration
+	P95    time.Duration
+}
+
+// RTTSummary calculates and returns the summary of the round-trip
+// latencies.
+func (s *Stats) RTTSummary() RTTSummary {
+	percentiles := s.Percentiles(50, 95)
+	return RTTSummary{
+		Min:    s.rttMin,
+		Avg:    nanos(s.rtt.Mean()),
+		Max:    s.rttMax,
+		StdDev: nanos(s.rtt.StdDev()),
+		Median: nanos(percentiles[0] * float64(time.Millisecond)),
+		P95:    nanos(percentiles[1] * float64(time.Millisecond)),
+	}
+}
+
+// nanos rounds a non-negative number of nanoseconds to a time.Duration.
+func nanos(v float64) time.Duration {
+	return time.Duration(v + 0.5)
+}
+
+// Trend estimates how fast round-trip latencies drift over time, in
+// milliseconds per minute, e.g. as queues build up, along with whether
+// there are enough latencies spread over time for estimating it.
+func (s *Stats) Trend() (float64, bool) {
+	slope, _, ok := s.trend.Trend()
+	return slope, ok
+}
+
+// Periodicity detects a periodic pattern of the most recent round-trip
+// latencies, e.g. a spike every 30 replies caused by a scheduled job,
+// returning its period, in number of replies, and the autocorrelation of
+// the latencies at that period. The period is 0 if no pattern is detected.
+func (s *Stats) Periodicity() (int, float64) {
+	var rtts []float64
+	for _, rtt := range s.rtts[s.recentFrom(len(s.rtts)):] {
+		rtts = append(rtts, math.TimeInMillis(rtt))
+	}
+
+	// Latencies varying slowly are correlated at short lags as well, so
+	// only peaks of the autocorrelation count as periods.
+	period, max := 0, minPeriodicity
+	prev := math.Autocorrelation(rtts, 1)
+	for lag := 2; lag*minPeriods <= len(rtts); lag++ {
+		r := math.Autocorrelation(rtts, lag)
+		next := math.Autocorrelation(rtts, lag+1)
+		if r >= max && r > prev && r >= next {
+			period, max = lag, r
+		}
+		prev = r
+	}
+	if period == 0 {
+		return 0, 0
+	}
+	return period, max
+}
+
+// OneWayDelays calculates and returns, respectively, the average forward
+// and reverse one-way delays, in milliseconds, and whether any were
+// estimated, i.e. whether Options.OneWay is set.
+func (s *Stats) OneWayDelays() (float64, float64, bool) {
+	if s.forward.Count() == 0 {
+		return 0, 0, false
+	}
+	return s.forward.Mean(), s.reverse.Mean(), true
+}
+
+// Bandwidth estimates the median of the bottleneck bandwidths estimated out
+// of packet pairs, in bits per second, returning it along with the number
+// of estimates, which is 0 unless Options.PacketPair is set.
+func (s *Stats) Bandwidth() (float64, int) {
+	return s.bandwidths.Percentile(50), s.bandwidths.Count()
+}
+
+// Percentiles estimates and returns the given percentiles (0-100) of the
+// round-trip latencies, in milliseconds. They are exact for up to a few
+// hundred replies.
+func (s *Stats) Percentiles(ps ...float64) []float64 {
+	percentiles := make([]float64, len(ps))
+	for i, p := range ps {
+		percentiles[i] = s.quantiles.Percentile(p)
+	}
+	return percentiles
+}
+
+// StartTime returns when the host started being pinged.
+func (s *Stats) StartTime() time.Time {
+	return s.startedAt
+}
+
+// Duration returns for how long the host was pinged, or zero if it is
+// still being pinged.
+func (s *Stats) Duration() time.Duration {
+	if s.stoppedAt.IsZero() {
+		return 0
+	}
+	return s.stoppedAt.Sub(s.startedAt)
+}
+
+// Since returns the stats accumulated after prev, a previous snapshot of
+// these stats, which is useful for reporting on intervals of a run. The
+// start time and duration of the returned stats are left zeroed, while
+// the smoothed RTT is the current one. Percentiles are estimated out of
+// the samples of the interval that are kept, which are all of them unless
+// there are more than Options.MaxSamples, while bandwidth estimates and
+// time buckets are not broken down by interval.
+func (s *Stats) Since(prev Stats) Stats {
+	rttCount := s.rtt.Count() - prev.rtt.Count()
+	since := Stats{
+		totalCount:      s.totalCount - prev.totalCount,
+		successCount:    s.successCount - prev.successCount,
+		anomalyCount:    s.anomalyCount - prev.anomalyCount,
+		warnCount:       s.warnCount - prev.warnCount,
+		critCount:       s.critCount - prev.critCount,
+		checksumErrors:  s.checksumErrors - prev.checksumErrors,
+		timeouts:        s.timeouts - prev.timeouts,
+		errors:          s.errors - prev.errors,
+		duplicates:      s.duplicates - prev.duplicates,
+		corrupted:       s.corrupted - prev.corrupted,
+		missing:         append([]int(nil), s.missing[lastFrom(len(s.missing), s.missingCount-prev.missingCount):]...),
+		missingCount:    s.missingCount - prev.missingCount,
+		late:            append([]int(nil), s.late[lastFrom(len(s.late), s.lateCount-prev.lateCount):]...),
+		lateCount:       s.lateCount - prev.lateCount,
+		warmupCount:     s.warmupCount - prev.warmupCount,
+		rtts:            append([]time.Duration(nil), s.rtts[lastFrom(len(s.rtts), rttCount):]...),
+		rtt:             s.rtt.Sub(prev.rtt),
+		trend:           s.trend.Sub(prev.trend),
+		trendStart:      s.trendStart,
+		forward:         s.forward.Sub(prev.forward),
+		reverse:         s.reverse.Sub(prev.reverse),
+		bandwidths:      math.NewTDigest(math.DefaultCompression),
+		srtt:            s.srtt,
+		rttvar:          s.rttvar,
+		lossStart:       s.lossStart,
+		outages:         append([]Outage(nil), s.outages[lastFrom(len(s.outages), s.outageCount-prev.outageCount):]...),
+		outageCount:     s.outageCount - prev.outageCount,
+		minOutages:      s.minOutages,
+		outageThreshold: s.outageThreshold,
+		hist:            histogramSince(s.hist, prev.hist),
+		quantiles:       math.NewTDigest(math.DefaultCompression),
+		maxSamples:      s.maxSamples,
+		bucketWidth:     s.bucketWidth,
+		coarseWidth:     s.coarseWidth,
+	}
+	for i, rtt := range since.rtts {
+		since.quantiles.Add(math.TimeInMillis(rtt))
+		if i == 0 || rtt < since.rttMin {
+			since.rttMin = rtt
+		}
+		if rtt > since.rttMax {
+			since.rttMax = rtt
+		}
+	}
+	if len(since.rtts) < rttCount {
+		// Not every latency of the interval was kept, but the histogram
+		// has them all.
+		since.rttMin = time.Duration(since.hist.Min())
+		since.rttMax = time.Duration(since.hist.Max())
+	}
+	s.burstsSince(prev, &since)
+	s.outagesSince(prev, &since)
+	for addr, n := range s.responders {
+		if n > prev.responders[addr] {
+			if since.responders == nil {
+				since.responders = make(map[string]int)
+			}
+			since.responders[addr] = n - prev.responders[addr]
+		}
 	}
+	return since
+}
 
-	return math.Min(rttsInMillis),
-		math.Mean(rttsInMillis),
-		math.Max(rttsInMillis),
-		math.StdDev(rttsInMillis)
+// snapshot returns a copy of the stats that does not share any state with
+// them, so it can be used while they are updated.
+func (s *Stats) snapshot() Stats {
+	c := *s
+	c.rtts = append([]time.Duration(nil), s.rtts...)
+	c.bursts = append([]int(nil), s.bursts...)
+	c.bandwidths = s.bandwidths.Clone()
+	c.missing = append([]int(nil), s.missing...)
+	c.late = append([]int(nil), s.late...)
+	c.outages = append([]Outage(nil), s.outages...)
+	c.buckets = append([]Bucket(nil), s.buckets...)
+	c.coarse = append([]Bucket(nil), s.coarse...)
+	c.responders = s.Responders()
+	c.hist = s.Histogram()
+	c.quantiles = s.quantiles.Clone()
+	return c
 }
 
 // incSuccess increments both the totalCount and the successCount,
-// as well as appends the given rtt to the list of rtts.
-func (s *Stats) incSuccess(rtt time.Duration) {
+// as well as records the given rtt, for a request sent at sentAt.
+func (s *Stats) incSuccess(rtt time.Duration, sentAt time.Time) {
 	s.totalCount++
 	s.successCount++
-	s.rtts = append(s.rtts, rtt)
+	if s.rtt.Count() == 0 || rtt < s.rttMin {
+		s.rttMin = rtt
+	}
+	if rtt > s.rttMax {
+		s.rttMax = rtt
+	}
+	s.rtt.Add(float64(rtt))
+	if s.trend.Count() == 0 {
+		s.trendStart = sentAt
+	}
+	s.trend.Add(sentAt.Sub(s.trendStart).Minutes(), math.TimeInMillis(rtt))
+	if s.maxSamples > 0 {
+		s.rtts = append(s.rtts, rtt)
+		if s.full(len(s.rtts)) {
+			s.rtts = append([]time.Duration(nil), s.rtts[s.recentFrom(len(s.rtts)):]...)
+		}
+	}
+	s.hist.RecordValue(int64(rtt))
+	s.quantiles.Add(math.TimeInMillis(rtt))
+	s.updateSmoothedRTT(rtt)
+	s.recordBucket(sentAt, rtt, true)
+	s.recordReceived(sentAt)
+}
+
+// incTimeout increments both the totalCount and the timeouts, for a
+// request sent at sentAt.
+func (s *Stats) incTimeout(sentAt time.Time) {
+	s.totalCount++
+	s.timeouts++
+	s.recordBucket(sentAt, 0, false)
+	s.recordLoss(sentAt)
 }
 
-// incTimeout increments only the totalCount.
-func (s *Stats) incTimeout() {
+// incError increments both the totalCount and the errors, for a request
+// sent at sentAt and answered with an ICMP error message instead of an
+// echo reply.
+func (s *Stats) incError(sentAt time.Time) {
 	s.totalCount++
+	s.errors++
+	s.recordBucket(sentAt, 0, false)
+	s.recordLoss(sentAt)
+}
+
+// recordMissing records that the request identified by seq timed out.
+func (s *Stats) recordMissing(seq int) {
+	s.missingCount++
+	if s.maxSamples > 0 {
+		s.missing = append(s.missing, seq)
+		if s.full(len(s.missing)) {
+			s.missing = append([]int(nil), s.missing[s.recentFrom(len(s.missing)):]...)
+		}
+	}
+}
+
+// recordLate records that a reply to the request identified by seq was
+// received after it timed out.
+func (s *Stats) recordLate(seq int) {
+	s.lateCount++
+	if s.maxSamples > 0 {
+		s.late = append(s.late, seq)
+		if s.full(len(s.late)) {
+			s.late = append([]int(nil), s.late[s.recentFrom(len(s.late)):]...)
+		}
+	}
+}
+
+// recordOneWay records the given estimates of one-way delays.
+func (s *Stats) recordOneWay(forward time.Duration, reverse time.Duration) {
+	s.forward.Add(math.TimeInMillis(forward))
+	s.reverse.Add(math.TimeInMillis(reverse))
+}
+
+// full returns whether n samples are enough for trimming them down to the
+// most recent Options.MaxSamples. Samples are kept up to twice as many, so
+// that trimming them is amortized over the samples recorded.
+func (s *Stats) full(n int) bool {
+	return n >= 2*s.maxSamples
+}
+
+// recentFrom returns the index from which the most recent
+// Options.MaxSamples of n samples start.
+func (s *Stats) recentFrom(n int) int {
+	return lastFrom(n, s.maxSamples)
+}
+
+// lastFrom returns the index from which the last count of n values start.
+func lastFrom(n int, count int) int {
+	if n > count {
+		return n - count
+	}
+	return 0
 }
@@ -0,0 +1,803 @@
+package pinger
+
+import (
+	"bytes"
+	"math"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+)
+
+func TestHistogram(t *testing.T) {
+	stats := newStats(DefaultHistogramDigits, DefaultOutageThreshold, DefaultMaxSamples, DefaultBucketWidth, DefaultCoarseBucketWidth)
+	for i := 1; i <= 100; i++ {
+		stats.incSuccess(time.Duration(i)*time.Millisecond, time.Time{})
+	}
+
+	tests := []struct {
+		desc     string
+		quantile float64
+		expected time.Duration
+	}{
+		{
+			desc:     "records the median",
+			quantile: 50,
+			expected: 50 * time.Millisecond,
+		},
+		{
+			desc:     "records the 99th percentile",
+			quantile: 99,
+			expected: 99 * time.Millisecond,
+		},
+		{
+			desc:     "records the max",
+			quantile: 100,
+			expected: 100 * time.Millisecond,
+		},
+	}
+
+	hist := stats.Histogram()
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			v := time.Duration(hist.ValueAtQuantile(tc.quantile))
+			if !hist.ValuesAreEquivalent(int64(v), int64(tc.expected)) {
+				t.Errorf("wanted %v, got %v", tc.expected, v)
+			}
+		})
+	}
+}
+
+func TestRTTs(t *testing.T) {
+	stats := newStats(DefaultHistogramDigits, DefaultOutageThreshold, DefaultMaxSamples, DefaultBucketWidth, DefaultCoarseBucketWidth)
+	stats.incSuccess(3*time.Millisecond, time.Time{})
+	stats.incTimeout(time.Time{})
+	stats.incSuccess(5*time.Millisecond, time.Time{})
+
+	expected := []time.Duration{3 * time.Millisecond, 5 * time.Millisecond}
+	rtts := stats.RTTs()
+	if !reflect.DeepEqual(rtts, expected) {
+		t.Errorf("wanted %v, got %v", expected, rtts)
+	}
+	if stats.Count() != len(expected) {
+		t.Errorf("wanted %v, got %v", len(expected), stats.Count())
+	}
+
+	rtts[0] = time.Hour
+	if stats.RTTs()[0] != expected[0] {
+		t.Errorf("wanted a copy of the RTTs, but modifying it changed the stats")
+	}
+}
+
+func TestMaxSamples(t *testing.T) {
+	stats := newStats(DefaultHistogramDigits, DefaultOutageThreshold, 5, DefaultBucketWidth, DefaultCoarseBucketWidth)
+	for i := 1; i <= 100; i++ {
+		stats.incSuccess(time.Duration(i)*time.Millisecond, time.Time{})
+		stats.incTimeout(time.Time{})
+		stats.recordMissing(i)
+	}
+	prev := stats.snapshot()
+	for i := 1; i <= 10; i++ {
+		stats.incSuccess(time.Duration(i)*time.Millisecond, time.Time{})
+	}
+
+	expected := []time.Duration{6 * time.Millisecond, 7 * time.Millisecond, 8 * time.Millisecond, 9 * time.Millisecond, 10 * time.Millisecond}
+	if rtts := stats.RTTs(); !reflect.DeepEqual(rtts, expected) {
+		t.Errorf("wanted %v, got %v", expected, rtts)
+	}
+	if len(stats.rtts) > 10 || len(stats.missing) > 10 || len(stats.bursts) > 10 {
+		t.Errorf("wanted up to 10 samples kept, got %d/%d/%d", len(stats.rtts), len(stats.missing), len(stats.bursts))
+	}
+	if expected, missing := []int{96, 97, 98, 99, 100}, stats.Missing(); !reflect.DeepEqual(missing, expected) {
+		t.Errorf("wanted %v, got %v", expected, missing)
+	}
+
+	if stats.Count() != 110 {
+		t.Errorf("wanted %v, got %v", 110, stats.Count())
+	}
+	if min, _, max, _ := stats.RTTStats(); min != 1 || max != 100 {
+		t.Errorf("wanted min/max 1/100, got %f/%f", min, max)
+	}
+	if bursts, max, _ := stats.LossBursts(); bursts != 100 || max != 1 {
+		t.Errorf("wanted 100 loss bursts of 1 packet, got %d of up to %d", bursts, max)
+	}
+
+	since := stats.Since(prev)
+	if min, _, max, _ := since.RTTStats(); !stats.hist.ValuesAreEquivalent(int64(min*1e6), int64(time.Millisecond)) || !stats.hist.ValuesAreEquivalent(int64(max*1e6), int64(10*time.Millisecond)) {
+		t.Errorf("wanted interval min/max 1/10, got %f/%f", min, max)
+	}
+}
+
+func TestNoSamples(t *testing.T) {
+	stats := newStats(DefaultHistogramDigits, DefaultOutageThreshold, 0, DefaultBucketWidth, DefaultCoarseBucketWidth)
+	for i := 1; i <= 10; i++ {
+		stats.incSuccess(time.Duration(i)*time.Millisecond, time.Time{})
+		stats.incTimeout(time.Time{})
+		stats.recordMissing(i)
+		stats.recordLate(i)
+	}
+
+	if stats.rtts != nil || stats.missing != nil || stats.late != nil || stats.bursts != nil {
+		t.Errorf("wanted no samples kept, got %v/%v/%v/%v", stats.rtts, stats.missing, stats.late, stats.bursts)
+	}
+	if stats.Count() != 10 {
+		t.Errorf("wanted %v, got %v", 10, stats.Count())
+	}
+	if min, avg, max, _ := stats.RTTStats(); min != 1 || avg != 5.5 || max != 10 {
+		t.Errorf("wanted min/avg/max 1/5.5/10, got %f/%f/%f", min, avg, max)
+	}
+	if bursts, _, _ := stats.LossBursts(); bursts != 10 {
+		t.Errorf("wanted %v, got %v", 10, bursts)
+	}
+	if buckets := stats.Buckets(); len(buckets) != 0 {
+		t.Errorf("wanted no buckets kept, got %v", buckets)
+	}
+	if stats.RateLimited() {
+		t.Errorf("wanted no rate limiting detected without samples")
+	}
+	if p := stats.Percentiles(50)[0]; p < 5 || p > 6 {
+		t.Errorf("wanted the median of the whole run, got %f", p)
+	}
+	stats.minOutages = 2
+	for i := 0; i < 5; i++ {
+		stats.recordOutage(Outage{Lost: i + 3})
+	}
+	if outages := stats.Outages(); len(outages) >= 5 {
+		t.Errorf("wanted only the most recent outages kept, got %v", len(outages))
+	}
+	if stats.OutageCount() != 5 {
+		t.Errorf("wanted %v, got %v", 5, stats.OutageCount())
+	}
+}
+
+func TestCounters(t *testing.T) {
+	stats := newStats(DefaultHistogramDigits, DefaultOutageThreshold, DefaultMaxSamples, DefaultBucketWidth, DefaultCoarseBucketWidth)
+	stats.incSuccess(3*time.Millisecond, time.Time{})
+	stats.incTimeout(time.Time{})
+	stats.incError(time.Time{})
+	stats.incTimeout(time.Time{})
+	stats.duplicates++
+	stats.checksumErrors++
+	stats.corrupted++
+
+	if transmitted := stats.Transmitted(); transmitted != stats.Received()+stats.Timeouts()+stats.Errors() {
+		t.Errorf("wanted every request transmitted to be accounted for, got %d received, %d timeouts and %d errors out of %d",
+			stats.Received(), stats.Timeouts(), stats.Errors(), transmitted)
+	}
+	if stats.Timeouts() != 2 {
+		t.Errorf("wanted %v, got %v", 2, stats.Timeouts())
+	}
+	if stats.Errors() != 1 {
+		t.Errorf("wanted %v, got %v", 1, stats.Errors())
+	}
+	if stats.Duplicates() != 1 {
+		t.Errorf("wanted %v, got %v", 1, stats.Duplicates())
+	}
+	if stats.Corrupted() != 2 {
+		t.Errorf("wanted %v, got %v", 2, stats.Corrupted())
+	}
+}
+
+func TestMissing(t *testing.T) {
+	stats := newStats(DefaultHistogramDigits, DefaultOutageThreshold, DefaultMaxSamples, DefaultBucketWidth, DefaultCoarseBucketWidth)
+	for seq := 0; seq < 30; seq++ {
+		if seq%10 == 3 {
+			stats.incTimeout(time.Time{})
+			stats.recordMissing(seq)
+			continue
+		}
+		stats.incSuccess(time.Millisecond, time.Time{})
+	}
+	stats.recordLate(13)
+
+	if expected, missing := []int{3, 23}, stats.Missing(); !reflect.DeepEqual(missing, expected) {
+		t.Errorf("wanted %v, got %v", expected, missing)
+	}
+	if expected, late := []int{13}, stats.Late(); !reflect.DeepEqual(late, expected) {
+		t.Errorf("wanted %v, got %v", expected, late)
+	}
+}
+
+func TestRTTSummary(t *testing.T) {
+	tests := []struct {
+		desc     string
+		rtts     []time.Duration
+		expected RTTSummary
+	}{
+		{
+			desc:     "no RTTs",
+			expected: RTTSummary{},
+		},
+		{
+			desc: "several RTTs",
+			rtts: []time.Duration{
+				2 * time.Millisecond,
+				4 * time.Millisecond,
+				4 * time.Millisecond,
+				4 * time.Millisecond,
+				5 * time.Millisecond,
+				5 * time.Millisecond,
+				7 * time.Millisecond,
+				9 * time.Millisecond,
+			},
+			expected: RTTSummary{
+				Min:    2 * time.Millisecond,
+				Avg:    5 * time.Millisecond,
+				Max:    9 * time.Millisecond,
+				StdDev: 2 * time.Millisecond,
+				Median: 4500 * time.Microsecond,
+				P95:    8300 * time.Microsecond,
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			stats := newStats(DefaultHistogramDigits, DefaultOutageThreshold, DefaultMaxSamples, DefaultBucketWidth, DefaultCoarseBucketWidth)
+			for _, rtt := range tc.rtts {
+				stats.incSuccess(rtt, time.Time{})
+			}
+
+			if summary := stats.RTTSummary(); summary != tc.expected {
+				t.Errorf("wanted %+v, got %+v", tc.expected, summary)
+			}
+		})
+	}
+}
+
+func TestTrend(t *testing.T) {
+	start := time.Unix(1500000000, 0)
+
+	tests := []struct {
+		desc     string
+		rtts     []time.Duration
+		interval time.Duration
+		expected float64
+		ok       bool
+	}{
+		{
+			desc: "not enough RTTs",
+			rtts: []time.Duration{10 * time.Millisecond},
+			ok:   false,
+		},
+		{
+			desc:     "RTTs not spread over time",
+			rtts:     []time.Duration{10 * time.Millisecond, 20 * time.Millisecond},
+			interval: 0,
+			ok:       false,
+		},
+		{
+			desc:     "increasing RTTs",
+			rtts:     []time.Duration{10 * time.Millisecond, 11 * time.Millisecond, 12 * time.Millisecond},
+			interval: 30 * time.Second,
+			expected: 2,
+			ok:       true,
+		},
+		{
+			desc:     "stable RTTs",
+			rtts:     []time.Duration{10 * time.Millisecond, 10 * time.Millisecond, 10 * time.Millisecond},
+			interval: time.Second,
+			expected: 0,
+			ok:       true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			stats := newStats(DefaultHistogramDigits, DefaultOutageThreshold, DefaultMaxSamples, DefaultBucketWidth, DefaultCoarseBucketWidth)
+			for i, rtt := range tc.rtts {
+				stats.incSuccess(rtt, start.Add(time.Duration(i)*tc.interval))
+			}
+
+			trend, ok := stats.Trend()
+			if ok != tc.ok || math.Abs(trend-tc.expected) > 1e-9 {
+				t.Errorf("wanted %v (%v), got %v (%v)", tc.expected, tc.ok, trend, ok)
+			}
+		})
+	}
+}
+
+func TestPeriodicity(t *testing.T) {
+	tests := []struct {
+		desc     string
+		rtts     func(i int) time.Duration
+		count    int
+		expected int
+	}{
+		{
+			desc: "spike every 5 replies",
+			rtts: func(i int) time.Duration {
+				if i%5 == 0 {
+					return 50 * time.Millisecond
+				}
+				return 10*time.Millisecond + time.Duration(i%3)*time.Millisecond
+			},
+			count:    40,
+			expected: 5,
+		},
+		{
+			desc: "steadily increasing",
+			rtts: func(i int) time.Duration {
+				return time.Duration(10+i) * time.Millisecond
+			},
+			count:    40,
+			expected: 0,
+		},
+		{
+			desc: "too few periods",
+			rtts: func(i int) time.Duration {
+				if i%10 == 0 {
+					return 50 * time.Millisecond
+				}
+				return 10 * time.Millisecond
+			},
+			count:    20,
+			expected: 0,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			stats := newStats(DefaultHistogramDigits, DefaultOutageThreshold, DefaultMaxSamples, DefaultBucketWidth, DefaultCoarseBucketWidth)
+			for i := 0; i < tc.count; i++ {
+				stats.incSuccess(tc.rtts(i), time.Time{})
+			}
+
+			if period, _ := stats.Periodicity(); period != tc.expected {
+				t.Errorf("wanted %v, got %v", tc.expected, period)
+			}
+		})
+	}
+}
+
+func TestWriteHistogramLog(t *testing.T) {
+	stats := newStats(DefaultHistogramDigits, DefaultOutageThreshold, DefaultMaxSamples, DefaultBucketWidth, DefaultCoarseBucketWidth)
+	stats.startedAt = time.Unix(1500000000, 0)
+	stats.stoppedAt = stats.startedAt.Add(10 * time.Second)
+	stats.incSuccess(3*time.Millisecond, time.Time{})
+	stats.incSuccess(5*time.Millisecond, time.Time{})
+
+	var buf bytes.Buffer
+	if err := stats.WriteHistogramLog(&buf, "example.com"); err != nil {
+		t.Fatalf("wanted no error, got %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	interval := strings.Split(lines[len(lines)-1], ",")
+	if len(interval) != 5 {
+		t.Fatalf("wanted 5 fields in the interval line, got %q", interval)
+	}
+
+	if interval[0] != "Tag=example.com" || interval[1] != "0.000" || interval[2] != "10.000" {
+		t.Errorf("wanted tag, start and length fields, got %q", interval[:3])
+	}
+	if !strings.HasPrefix(interval[3], "5.00") {
+		t.Errorf("wanted interval max of ~5ms, got %s", interval[3])
+	}
+
+	hist, err := hdrhistogram.Decode([]byte(interval[4]))
+	if err != nil {
+		t.Fatalf("cannot decode histogram: %v", err)
+	}
+	if hist.TotalCount() != 2 {
+		t.Errorf("wanted 2 values, got %d", hist.TotalCount())
+	}
+}
+
+func TestSince(t *testing.T) {
+	stats := newStats(DefaultHistogramDigits, DefaultOutageThreshold, DefaultMaxSamples, DefaultBucketWidth, DefaultCoarseBucketWidth)
+	stats.incSuccess(10*time.Millisecond, time.Time{})
+	stats.incTimeout(time.Time{})
+	prev := stats.snapshot()
+
+	stats.incSuccess(20*time.Millisecond, time.Time{})
+	stats.incSuccess(30*time.Millisecond, time.Time{})
+	stats.incTimeout(time.Time{})
+
+	since := stats.Since(prev)
+	if since.Transmitted() != 3 {
+		t.Errorf("wanted 3 packets transmitted, got %d", since.Transmitted())
+	}
+	if since.Received() != 2 {
+		t.Errorf("wanted 2 packets received, got %d", since.Received())
+	}
+
+	min, _, max, _ := since.RTTStats()
+	if min != 20 || max != 30 {
+		t.Errorf("wanted min/max 20/30, got %f/%f", min, max)
+	}
+	if count := since.Histogram().TotalCount(); count != 2 {
+		t.Errorf("wanted 2 values in the histogram, got %d", count)
+	}
+	if median := since.Percentiles(50)[0]; median != 25 {
+		t.Errorf("wanted median 25, got %f", median)
+	}
+	if bursts, max, _ := since.LossBursts(); bursts != 1 || max != 1 {
+		t.Errorf("wanted 1 loss burst of 1 packet, got %d of up to %d", bursts, max)
+	}
+}
+
+func TestLossBursts(t *testing.T) {
+	tests := []struct {
+		desc       string
+		received   string
+		bursts     int
+		max        int
+		mean       float64
+		burstiness float64
+	}{
+		{
+			desc:     "no loss",
+			received: "++++",
+		},
+		{
+			desc:       "isolated losses",
+			received:   "+-+-+-+",
+			bursts:     3,
+			max:        1,
+			mean:       1,
+			burstiness: 1.0 / (3.0/4.0 + 1),
+		},
+		{
+			desc:       "bursty losses",
+			received:   "++++---+++---+",
+			bursts:     2,
+			max:        3,
+			mean:       3,
+			burstiness: 1.0 / (2.0/8.0 + 2.0/6.0),
+		},
+		{
+			desc:       "ongoing burst",
+			received:   "++-+--",
+			bursts:     2,
+			max:        2,
+			mean:       1.5,
+			burstiness: 1.0 / (2.0/3.0 + 1.0/3.0),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			stats := newStats(DefaultHistogramDigits, DefaultOutageThreshold, DefaultMaxSamples, DefaultBucketWidth, DefaultCoarseBucketWidth)
+			for _, r := range tc.received {
+				if r == '+' {
+					stats.incSuccess(time.Millisecond, time.Time{})
+				} else {
+					stats.incTimeout(time.Time{})
+				}
+			}
+
+			bursts, max, mean := stats.LossBursts()
+			if bursts != tc.bursts || max != tc.max || mean != tc.mean {
+				t.Errorf("wanted %v/%v/%v, got %v/%v/%v", tc.bursts, tc.max, tc.mean, bursts, max, mean)
+			}
+			if b := stats.Burstiness(); math.Abs(b-tc.burstiness) > 1e-9 {
+				t.Errorf("wanted burstiness %v, got %v", tc.burstiness, b)
+			}
+		})
+	}
+}
+
+func TestRateLimited(t *testing.T) {
+	tests := []struct {
+		desc     string
+		lost     func(seq int) bool
+		rtt      func(seq int) time.Duration
+		expected bool
+	}{
+		{
+			desc:     "no loss",
+			lost:     func(seq int) bool { return false },
+			rtt:      func(seq int) time.Duration { return 10 * time.Millisecond },
+			expected: false,
+		},
+		{
+			desc:     "evenly spaced bursts with stable latencies",
+			lost:     func(seq int) bool { return seq%10 >= 8 },
+			rtt:      func(seq int) time.Duration { return time.Duration(10+seq%3) * time.Millisecond },
+			expected: true,
+		},
+		{
+			desc:     "unevenly spaced bursts",
+			lost:     func(seq int) bool { return seq == 3 || seq == 5 || seq == 17 || seq == 40 || seq == 44 },
+			rtt:      func(seq int) time.Duration { return 10 * time.Millisecond },
+			expected: false,
+		},
+		{
+			desc:     "evenly spaced bursts with inflated latencies",
+			lost:     func(seq int) bool { return seq%10 >= 8 },
+			rtt:      func(seq int) time.Duration { return time.Duration(10+10*(seq%10)) * time.Millisecond },
+			expected: false,
+		},
+		{
+			desc:     "too few bursts",
+			lost:     func(seq int) bool { return seq == 8 || seq == 18 || seq == 28 },
+			rtt:      func(seq int) time.Duration { return 10 * time.Millisecond },
+			expected: false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			stats := newStats(DefaultHistogramDigits, DefaultOutageThreshold, DefaultMaxSamples, DefaultBucketWidth, DefaultCoarseBucketWidth)
+			for seq := 0; seq < 50; seq++ {
+				if tc.lost(seq) {
+					stats.incTimeout(time.Time{})
+					stats.recordMissing(seq)
+				} else {
+					stats.incSuccess(tc.rtt(seq), time.Time{})
+				}
+			}
+			if limited := stats.RateLimited(); limited != tc.expected {
+				t.Errorf("wanted %v, got %v", tc.expected, limited)
+			}
+		})
+	}
+}
+
+func TestOutages(t *testing.T) {
+	start := time.Unix(1500000000, 0)
+	stats := newStats(DefaultHistogramDigits, 2, DefaultMaxSamples, DefaultBucketWidth, DefaultCoarseBucketWidth)
+	for i, r := range "+-+--+---" {
+		sentAt := start.Add(time.Duration(i) * time.Second)
+		if r == '+' {
+			stats.incSuccess(time.Millisecond, sentAt)
+		} else {
+			stats.incTimeout(sentAt)
+		}
+	}
+
+	expected := []Outage{
+		{
+			Start: start.Add(3 * time.Second),
+			End:   start.Add(5 * time.Second),
+			Lost:  2,
+		},
+		{
+			Start: start.Add(6 * time.Second),
+			Lost:  3,
+		},
+	}
+
+	outages := stats.Outages()
+	if !reflect.DeepEqual(outages, expected) {
+		t.Fatalf("wanted %v, got %v", expected, outages)
+	}
+	if d := outages[0].Duration(); d != 2*time.Second {
+		t.Errorf("wanted duration 2s, got %v", d)
+	}
+	if !outages[1].Ongoing() {
+		t.Errorf("wanted last outage to be ongoing")
+	}
+}
+
+func TestMaxOutages(t *testing.T) {
+	tests := []struct {
+		desc       string
+		maxSamples uint
+		minOutages int
+		expected   int
+	}{
+		{
+			desc:       "bounded by max samples",
+			maxSamples: 5,
+			expected:   5,
+		},
+		{
+			desc:       "bounded by the flap threshold when discarding samples",
+			maxSamples: 0,
+			minOutages: 3,
+			expected:   3,
+		},
+		{
+			desc:       "none when discarding samples without flap detection",
+			maxSamples: 0,
+			expected:   0,
+		},
+	}
+
+	start := time.Unix(1500000000, 0)
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			stats := newStats(DefaultHistogramDigits, 1, tc.maxSamples, DefaultBucketWidth, DefaultCoarseBucketWidth)
+			stats.minOutages = tc.minOutages
+			prev := stats.snapshot()
+			// Outages last longer and longer, up to the 10th, lasting 10s.
+			sentAt := start
+			for i := 1; i <= 20; i++ {
+				stats.incTimeout(sentAt)
+				lost := i
+				if i > 10 {
+					lost = 1
+				}
+				sentAt = sentAt.Add(time.Duration(lost) * time.Second)
+				stats.incSuccess(time.Millisecond, sentAt)
+				sentAt = sentAt.Add(time.Second)
+			}
+
+			if n := len(stats.Outages()); n < tc.expected || n > 2*tc.expected {
+				t.Errorf("wanted %v outages kept, got %v", tc.expected, n)
+			}
+			if stats.OutageCount() != 20 {
+				t.Errorf("wanted %v, got %v", 20, stats.OutageCount())
+			}
+			since := stats.Since(prev)
+			if since.OutageCount() != 20 || len(since.Outages()) != len(stats.Outages()) {
+				t.Errorf("wanted %v outages since the start, got %v", 20, since.OutageCount())
+			}
+			longest, ok := stats.LongestOutage()
+			if !ok || longest.Duration() != 10*time.Second {
+				t.Errorf("wanted longest outage of %v, got %v", 10*time.Second, longest.Duration())
+			}
+		})
+	}
+}
+
+func TestBuckets(t *testing.T) {
+	start := time.Unix(1500000000, 0).Truncate(time.Minute)
+	stats := newStats(DefaultHistogramDigits, DefaultOutageThreshold, DefaultMaxSamples, time.Minute, time.Hour)
+	stats.incSuccess(10*time.Millisecond, start.Add(10*time.Second))
+	stats.incTimeout(start.Add(20 * time.Second))
+	stats.incSuccess(30*time.Millisecond, start.Add(2*time.Minute))
+	stats.incSuccess(20*time.Millisecond, start.Add(50*time.Second))
+
+	buckets := stats.Buckets()
+	if len(buckets) != 2 {
+		t.Fatalf("wanted 2 buckets, got %d", len(buckets))
+	}
+
+	tests := []struct {
+		desc     string
+		bucket   Bucket
+		start    time.Time
+		received int
+		loss     float64
+		min      time.Duration
+		avg      time.Duration
+		max      time.Duration
+	}{
+		{
+			desc:     "bucket with losses and a late reply",
+			bucket:   buckets[0],
+			start:    start,
+			received: 2,
+			loss:     100.0 / 3,
+			min:      10 * time.Millisecond,
+			avg:      15 * time.Millisecond,
+			max:      20 * time.Millisecond,
+		},
+		{
+			desc:     "bucket after an idle minute",
+			bucket:   buckets[1],
+			start:    start.Add(2 * time.Minute),
+			received: 1,
+			loss:     0,
+			min:      30 * time.Millisecond,
+			avg:      30 * time.Millisecond,
+			max:      30 * time.Millisecond,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			b := tc.bucket
+			if !b.Start.Equal(tc.start) || b.Received != tc.received || math.Abs(b.PacketLoss()-tc.loss) > 1e-9 {
+				t.Errorf("wanted %v/%d/%.2f%%, got %v/%d/%.2f%%", tc.start, tc.received, tc.loss, b.Start, b.Received, b.PacketLoss())
+			}
+			if b.Min != tc.min || b.Avg() != tc.avg || b.Max != tc.max {
+				t.Errorf("wanted %v/%v/%v, got %v/%v/%v", tc.min, tc.avg, tc.max, b.Min, b.Avg(), b.Max)
+			}
+		})
+	}
+}
+
+func TestDownsampledBuckets(t *testing.T) {
+	start := time.Unix(1500000000, 0).Truncate(time.Hour)
+	stats := newStats(DefaultHistogramDigits, DefaultOutageThreshold, 10, time.Minute, time.Hour)
+	for i := 0; i < 3*60; i++ {
+		stats.incSuccess(time.Duration(i+1)*time.Millisecond, start.Add(time.Duration(i)*time.Minute))
+	}
+
+	buckets := stats.Buckets()
+	if len(buckets) > 3+2*10 {
+		t.Fatalf("wanted up to %d buckets, got %d", 3+2*10, len(buckets))
+	}
+
+	transmitted := 0
+	for i, b := range buckets {
+		transmitted += b.Transmitted
+		if i > 0 && b.Start.Before(buckets[i-1].Start) {
+			t.Errorf("wanted buckets in chronological order, got %v after %v", b.Start, buckets[i-1].Start)
+		}
+	}
+	if transmitted != 3*60 {
+		t.Errorf("wanted %v, got %v", 3*60, transmitted)
+	}
+
+	first := buckets[0]
+	if first.Width != time.Hour || first.Transmitted != 60 || first.Min != time.Millisecond || first.Max != 60*time.Millisecond {
+		t.Errorf("wanted a full hour downsampled, got %+v", first)
+	}
+	if last := buckets[len(buckets)-1]; last.Width != time.Minute || last.Transmitted != 1 {
+		t.Errorf("wanted the last minute kept, got %+v", last)
+	}
+}
+
+func TestSmoothedRTT(t *testing.T) {
+	stats := newStats(DefaultHistogramDigits, DefaultOutageThreshold, DefaultMaxSamples, DefaultBucketWidth, DefaultCoarseBucketWidth)
+
+	tests := []struct {
+		desc   string
+		rtt    time.Duration
+		srtt   float64
+		rttvar float64
+	}{
+		{
+			desc:   "initializes with the first sample",
+			rtt:    80 * time.Millisecond,
+			srtt:   80,
+			rttvar: 40,
+		},
+		{
+			desc:   "smooths a higher sample",
+			rtt:    160 * time.Millisecond,
+			srtt:   90,
+			rttvar: 50,
+		},
+		{
+			desc:   "smooths a sample close to the average",
+			rtt:    90 * time.Millisecond,
+			srtt:   90,
+			rttvar: 37.5,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			stats.incSuccess(tc.rtt, time.Time{})
+			srtt, rttvar := stats.SmoothedRTT()
+			if srtt != tc.srtt || rttvar != tc.rttvar {
+				t.Errorf("wanted %v/%v, got %v/%v", tc.srtt, tc.rttvar, srtt, rttvar)
+			}
+		})
+	}
+}
+
+func TestResponders(t *testing.T) {
+	a := &net.IPAddr{IP: net.IPv4(192, 0, 2, 1)}
+	b := &net.IPAddr{IP: net.IPv4(192, 0, 2, 2)}
+
+	stats := newStats(DefaultHistogramDigits, DefaultOutageThreshold, DefaultMaxSamples, DefaultBucketWidth, DefaultCoarseBucketWidth)
+	stats.recordResponder(a)
+	stats.recordResponder(b)
+	prev := stats.snapshot()
+	stats.recordResponder(a)
+
+	tests := []struct {
+		desc     string
+		stats    Stats
+		expected map[string]int
+	}{
+		{
+			desc:     "counts replies per responder",
+			stats:    *stats,
+			expected: map[string]int{"192.0.2.1": 2, "192.0.2.2": 1},
+		},
+		{
+			desc:     "counts replies per responder since a snapshot",
+			stats:    stats.Since(prev),
+			expected: map[string]int{"192.0.2.1": 1},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			if r := tc.stats.Responders(); !reflect.DeepEqual(r, tc.expected) {
+				t.Errorf("wanted %v, got %v", tc.expected, r)
+			}
+		})
+	}
+}
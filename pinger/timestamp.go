@@ -0,0 +1,80 @@
+package pinger
+
+import (
+	"encoding/binary"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+const (
+	// timestampsLen is the number of bytes of the originate, receive and
+	// transmit timestamps of ICMP Timestamp messages.
+	timestampsLen = 12
+
+	// msPerDay is the number of milliseconds in a day, as ICMP timestamps
+	// are milliseconds since midnight UT.
+	msPerDay = 24 * 60 * 60 * 1000
+)
+
+// createTimestampRequest returns an ICMP Timestamp request identified by id
+// and seq, originated at now.
+func createTimestampRequest(id int, seq int, now time.Time) ([]byte, error) {
+	data := make([]byte, 4+timestampsLen)
+	binary.BigEndian.PutUint16(data[0:2], uint16(id))
+	binary.BigEndian.PutUint16(data[2:4], uint16(seq))
+	binary.BigEndian.PutUint32(data[4:8], msSinceMidnight(now))
+
+	msg := icmp.Message{
+		Type: ipv4.ICMPTypeTimestamp,
+		Body: &icmp.RawBody{Data: data},
+	}
+	return msg.Marshal(nil)
+}
+
+// parseTimestampReply returns the body of an ICMP Timestamp reply as an
+// Echo holding its identifier, sequence number and timestamps, so that it
+// is matched to its request the same way echo replies are.
+func parseTimestampReply(body *icmp.RawBody) (*icmp.Echo, bool) {
+	if len(body.Data) < 4+timestampsLen {
+		return nil, false
+	}
+	return &icmp.Echo{
+		ID:   int(binary.BigEndian.Uint16(body.Data[0:2])),
+		Seq:  int(binary.BigEndian.Uint16(body.Data[2:4])),
+		Data: body.Data[4 : 4+timestampsLen],
+	}, true
+}
+
+// oneWayDelays estimates the forward and reverse one-way delays out of the
+// originate, receive and transmit timestamps in b and the arrival time of
+// the reply. They are only meaningful if the clocks of both ends are
+// synchronized, and have a resolution of a millisecond.
+func oneWayDelays(b []byte, arrival time.Time) (time.Duration, time.Duration) {
+	originate := binary.BigEndian.Uint32(b[0:4])
+	receive := binary.BigEndian.Uint32(b[4:8])
+	transmit := binary.BigEndian.Uint32(b[8:12])
+
+	forward := msBetween(originate, receive)
+	reverse := msBetween(transmit, msSinceMidnight(arrival))
+	return time.Duration(forward) * time.Millisecond, time.Duration(reverse) * time.Millisecond
+}
+
+// msSinceMidnight returns the milliseconds elapsed since midnight UT.
+func msSinceMidnight(t time.Time) uint32 {
+	t = t.UTC()
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return uint32(t.Sub(midnight) / time.Millisecond)
+}
+
+// msBetween returns the milliseconds from one timestamp to another,
+// accounting for midnight in between. Differences of more than half a day
+// are considered negative, as happens when the clocks are off.
+func msBetween(from uint32, to uint32) int64 {
+	d := (int64(to) - int64(from) + msPerDay) % msPerDay
+	if d > msPerDay/2 {
+		d -= msPerDay
+	}
+	return d
+}
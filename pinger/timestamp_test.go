@@ -0,0 +1,63 @@
+package pinger
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+func TestOneWayDelays(t *testing.T) {
+	midnight := time.Date(2017, 7, 14, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		desc      string
+		originate uint32
+		receive   uint32
+		transmit  uint32
+		arrival   time.Time
+		forward   time.Duration
+		reverse   time.Duration
+	}{
+		{
+			desc:      "asymmetric delays",
+			originate: 1000,
+			receive:   1030,
+			transmit:  1031,
+			arrival:   midnight.Add(1041 * time.Millisecond),
+			forward:   30 * time.Millisecond,
+			reverse:   10 * time.Millisecond,
+		},
+		{
+			desc:      "delays across midnight",
+			originate: msPerDay - 10,
+			receive:   5,
+			transmit:  6,
+			arrival:   midnight.Add(20 * time.Millisecond),
+			forward:   15 * time.Millisecond,
+			reverse:   14 * time.Millisecond,
+		},
+		{
+			desc:      "clock of the host behind",
+			originate: 1000,
+			receive:   990,
+			transmit:  990,
+			arrival:   midnight.Add(1020 * time.Millisecond),
+			forward:   -10 * time.Millisecond,
+			reverse:   30 * time.Millisecond,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			b := make([]byte, timestampsLen)
+			binary.BigEndian.PutUint32(b[0:4], tc.originate)
+			binary.BigEndian.PutUint32(b[4:8], tc.receive)
+			binary.BigEndian.PutUint32(b[8:12], tc.transmit)
+
+			forward, reverse := oneWayDelays(b, tc.arrival)
+			if forward != tc.forward || reverse != tc.reverse {
+				t.Errorf("wanted %v/%v, got %v/%v", tc.forward, tc.reverse, forward, reverse)
+			}
+		})
+	}
+}
@@ -0,0 +1,273 @@
+package pinger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Method identifies how requests are sent to the host being pinged.
+type Method int
+
+const (
+	// RawICMP sends ICMP echo requests through a raw socket, which
+	// requires privileges (e.g. root or CAP_NET_RAW on Linux).
+	RawICMP Method = iota
+
+	// DatagramICMP sends ICMP echo requests through an unprivileged
+	// datagram socket, as allowed by macOS and by Linux for the groups in
+	// net.ipv4.ping_group_range.
+	DatagramICMP
+
+	// TCPConnect times the TCP handshake with Options.TCPPort instead of
+	// sending ICMP echo requests, for hosts or networks filtering ICMP.
+	TCPConnect
+
+	// TWAMPLight sends TWAMP Light test packets to the reflector on
+	// Options.TWAMPPort instead of sending ICMP echo requests.
+	TWAMPLight
+)
+
+// String returns a human readable name for the method.
+func (m Method) String() string {
+	switch m {
+	case RawICMP:
+		return "raw ICMP"
+	case DatagramICMP:
+		return "datagram ICMP"
+	case TCPConnect:
+		return "TCP connect"
+	case TWAMPLight:
+		return "TWAMP Light"
+	default:
+		return "unknown"
+	}
+}
+
+// socket is the interface shared by the raw and datagram sockets ICMP
+// messages are exchanged through.
+type socket interface {
+	net.Conn
+	net.PacketConn
+	syscall.Conn
+}
+
+// icmpConn is the connection requests are sent through, using a given
+// method. The socket is nil for TCPConnect, which dials every request.
+type icmpConn struct {
+	socket
+	method Method
+}
+
+// PermissionError is returned when the process is not permitted to open
+// any kind of ICMP socket.
+type PermissionError struct {
+	// RawErr is the error opening a raw socket, if one was attempted.
+	RawErr error
+
+	// DatagramErr is the error opening a datagram socket.
+	DatagramErr error
+}
+
+// Error explains the ways of obtaining the permissions needed, or of doing
+// without them by timing TCP handshakes, as Options.TCPPort (the
+// -tcp-fallback flag of pingo) enables.
+func (e *PermissionError) Error() string {
+	err := e.DatagramErr
+	if e.RawErr != nil {
+		err = e.RawErr
+	}
+	return fmt.Sprintf(
+		"not permitted to open an ICMP socket (%v); either run as root, grant the CAP_NET_RAW capability to the binary (e.g. setcap cap_net_raw+ep <binary>), or allow unprivileged ICMP sockets for your group via the net.ipv4.ping_group_range sysctl; alternatively, time TCP handshakes instead with -tcp-fallback port",
+		err,
+	)
+}
+
+// listen opens a connection for sending requests, falling back from raw to
+// datagram ICMP sockets when the former are not permitted, and then to TCP
+// connect probing when enabled by Options.TCPPort. A UDP socket is opened
+// instead when Options.TWAMPPort is set.
+func (p *pinger) listen() (*icmpConn, error) {
+	lc := net.ListenConfig{Control: p.opts.Control}
+	if p.opts.TWAMPPort != 0 {
+		conn, err := lc.ListenPacket(context.Background(), "udp4", (&net.UDPAddr{IP: p.opts.Source}).String())
+		if err != nil {
+			return nil, err
+		}
+		return &icmpConn{socket: conn.(*net.UDPConn), method: TWAMPLight}, nil
+	}
+
+	var rawErr error
+	if !p.opts.Unprivileged {
+		var conn net.PacketConn
+		conn, rawErr = lc.ListenPacket(context.Background(), "ip4:icmp", sourceAddr(p.opts.Source))
+		if rawErr == nil {
+			return &icmpConn{socket: conn.(*net.IPConn), method: RawICMP}, nil
+		}
+	}
+
+	dgram, err := listenDatagram(p.opts.Source, p.opts.Control)
+	if err == nil {
+		// Linux replaces the identifier of echo requests sent through
+		// datagram sockets with the local port of the socket.
+		if port := dgram.LocalAddr().(*net.UDPAddr).Port; port != 0 {
+			p.id = port
+		}
+		return &icmpConn{socket: dgram, method: DatagramICMP}, nil
+	}
+
+	if p.opts.TCPPort != 0 {
+		return &icmpConn{method: TCPConnect}, nil
+	}
+	if (rawErr == nil || errors.Is(rawErr, os.ErrPermission)) && errors.Is(err, os.ErrPermission) {
+		return nil, &PermissionError{RawErr: rawErr, DatagramErr: err}
+	}
+	if rawErr == nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("%v (datagram fallback: %v)", rawErr, err)
+}
+
+// sourceAddr returns the address raw sockets are bound to for src, which
+// is any address if src is nil.
+func sourceAddr(src net.IP) string {
+	if src == nil {
+		return "0.0.0.0"
+	}
+	return src.String()
+}
+
+// readMsg reads a message into b, and its control messages into oob,
+// returning the address of the peer it was received from.
+func (c *icmpConn) readMsg(b []byte, oob []byte) (int, int, net.Addr, error) {
+	if conn, ok := c.socket.(*net.UDPConn); ok {
+		n, oobn, _, peer, err := conn.ReadMsgUDP(b, oob)
+		if err != nil {
+			return 0, 0, nil, err
+		}
+		return n, oobn, &net.IPAddr{IP: peer.IP}, nil
+	}
+
+	n, oobn, _, peer, err := c.socket.(*net.IPConn).ReadMsgIP(b, oob)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	return n, oobn, peer, nil
+}
+
+// writeTo sends b to addr, which raw sockets expect as an IP address and
+// datagram sockets as a UDP address.
+func (c *icmpConn) writeTo(b []byte, addr net.Addr) error {
+	ipAddr, err := IPAddrOf(addr)
+	if err != nil {
+		return err
+	}
+	addr = ipAddr
+	if c.method == DatagramICMP {
+		addr = &net.UDPAddr{IP: ipAddr.IP, Zone: ipAddr.Zone}
+	}
+	_, err = c.socket.WriteTo(b, addr)
+	return err
+}
+
+// Close closes the underlying socket, if any.
+func (c *icmpConn) Close() error {
+	if c.socket == nil {
+		return nil
+	}
+	return c.socket.Close()
+}
+
+// pingTCP times a TCP handshake with addr on Options.TCPPort. A refused
+// connection counts as a reply, since the host answered it.
+func (p *pinger) pingTCP(addr net.Addr, seq int) (Ping, error) {
+	ipAddr, err := IPAddrOf(addr)
+	if err != nil {
+		return Ping{}, fmt.Errorf("cannot connect for seq %d: %v", seq, err)
+	}
+	dialer := net.Dialer{
+		Timeout:   p.timeout(seq),
+		LocalAddr: &net.TCPAddr{IP: p.opts.Source},
+		Control:   p.opts.Control,
+	}
+	target := net.JoinHostPort(ipAddr.IP.String(), strconv.Itoa(int(p.opts.TCPPort)))
+
+	// Forcibly stopping cancels the handshake in flight.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-p.force:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	sentAt := p.clock.Now()
+	conn, err := dialer.DialContext(ctx, "tcp4", target)
+	rtt := p.clock.Now().Sub(sentAt)
+	if err == nil {
+		conn.Close()
+	}
+
+	var neterr net.Error
+	switch {
+	case err == nil || errors.Is(err, syscall.ECONNREFUSED):
+		return Ping{
+			Seq:        seq,
+			RTT:        rtt,
+			From:       addr,
+			SentAt:     sentAt,
+			ReceivedAt: sentAt.Add(rtt),
+			Anomalous:  p.recordSuccess(rtt, sentAt),
+			Severity:   p.severity(rtt),
+		}, nil
+	case ctx.Err() != nil:
+		return Ping{Seq: seq}, nil
+	case errors.As(err, &neterr) && neterr.Timeout():
+		p.updateStats(func(s *Stats) {
+			s.incTimeout(sentAt)
+			s.recordMissing(seq)
+		})
+		return Ping{
+			Seq:     seq,
+			SentAt:  sentAt,
+			Timeout: true,
+		}, nil
+	default:
+		p.updateStats(func(s *Stats) {
+			s.incError(sentAt)
+		})
+		return Ping{
+			Seq:         seq,
+			RTT:         rtt,
+			SentAt:      sentAt,
+			Unreachable: true,
+		}, nil
+	}
+}
+
+// recordSuccess records a reply received after rtt to a request sent at
+// sentAt, returning whether rtt is anomalous.
+func (p *pinger) recordSuccess(rtt time.Duration, sentAt time.Time) bool {
+	// Warmup RTTs are kept out of the baseline as well.
+	anomalous := p.warmup == nil && p.anomalies.anomalous(rtt)
+	p.updateStats(func(s *Stats) {
+		s.incSuccess(rtt, sentAt)
+		if anomalous {
+			s.anomalyCount++
+		}
+		switch p.severity(rtt) {
+		case SeverityWarn:
+			s.warnCount++
+		case SeverityCrit:
+			s.critCount++
+		}
+	})
+	return anomalous
+}
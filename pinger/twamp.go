@@ -0,0 +1,90 @@
+package pinger
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/caiofilipini/pingo/twamp"
+)
+
+// pingTWAMP sends a TWAMP Light test packet identified by seq to the
+// reflector on Options.TWAMPPort of addr, and waits for it to be
+// reflected. The RTT excludes the time spent by the reflector.
+func (p *pinger) pingTWAMP(conn *icmpConn, addr net.Addr, seq int, sentAt time.Time) (Ping, error) {
+	size := int(p.opts.PacketSize)
+	if size < twamp.ReflectedPacketLen {
+		size = twamp.ReflectedPacketLen
+	}
+	pkt := twamp.TestPacket{
+		Seq:           uint32(seq),
+		Timestamp:     sentAt,
+		ErrorEstimate: twamp.ErrorEstimate,
+	}
+	b := pkt.Marshal(size)
+	ipAddr, err := IPAddrOf(addr)
+	if err != nil {
+		return Ping{}, fmt.Errorf("cannot send test packet for seq %d: %v", seq, err)
+	}
+	target := &net.UDPAddr{IP: ipAddr.IP, Port: int(p.opts.TWAMPPort)}
+
+	p.dump(b, "sent twamp seq %d to %v:", seq, target)
+	if _, err := conn.WriteTo(b, target); err != nil {
+		return Ping{}, fmt.Errorf("cannot send test packet for seq %d: %v", seq, err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(p.timeout(seq)))
+	buf := readBuffer(size)
+	oob := make([]byte, oobBufferSize)
+
+	// Late replies to previous test packets are skipped.
+	for {
+		n, oobn, peer, err := conn.readMsg(buf, oob)
+		if err != nil {
+			if neterr, ok := err.(*net.OpError); ok && neterr.Timeout() {
+				p.updateStats(func(s *Stats) {
+					s.incTimeout(sentAt)
+					s.recordMissing(seq)
+				})
+				return Ping{
+					Seq:     seq,
+					Timeout: true,
+				}, nil
+			}
+			return Ping{}, fmt.Errorf("cannot read packet for seq %d: %v", seq, err)
+		}
+		arrival := p.clock.Now()
+
+		p.dump(buf[:n], "received %d bytes from %v:", n, peer)
+		reply, err := twamp.ParseReflectedPacket(buf[:n])
+		if err != nil {
+			p.debugf("rejected: %v", err)
+			continue
+		}
+		if reply.SenderSeq != uint32(seq) {
+			p.debugf("rejected: unexpected sender seq %d", reply.SenderSeq)
+			continue
+		}
+
+		rtt := arrival.Sub(sentAt) - reply.Timestamp.Sub(reply.ReceiveTimestamp)
+		forward := reply.ReceiveTimestamp.Sub(reply.SenderTimestamp)
+		reverse := arrival.Sub(reply.Timestamp)
+		p.updateStats(func(s *Stats) {
+			s.recordOneWay(forward, reverse)
+		})
+
+		ping := Ping{
+			Seq:        seq,
+			Size:       n,
+			RTT:        rtt,
+			From:       peer,
+			ReceivedAt: arrival,
+			Forward:    forward,
+			Reverse:    reverse,
+			Anomalous:  p.recordSuccess(rtt, sentAt),
+			Severity:   p.severity(rtt),
+		}
+		p.parseControl(&ping, oob[:oobn])
+		return ping, nil
+	}
+}
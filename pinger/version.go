@@ -0,0 +1,78 @@
+package pinger
+
+import (
+	"runtime/debug"
+	"time"
+)
+
+// BuildInfo describes the build of the running binary, as embedded by the
+// Go toolchain.
+type BuildInfo struct {
+	// Version is the module version of the main package, or "(devel)"
+	// when built from a working copy.
+	Version string
+
+	// Revision is the VCS revision the binary was built from, if known.
+	Revision string
+
+	// Time is the time of Revision, if known.
+	Time time.Time
+
+	// Modified is whether the working copy had uncommitted changes.
+	Modified bool
+
+	// GoVersion is the version of the Go toolchain that built the binary.
+	GoVersion string
+}
+
+// ReadBuildInfo returns the build info of the running binary.
+func ReadBuildInfo() BuildInfo {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return BuildInfo{Version: "unknown"}
+	}
+	return newBuildInfo(info)
+}
+
+// Version returns the version of the running binary, followed by the VCS
+// revision it was built from, if known, e.g. "(devel) 1a2b3c4d5e6f-dirty".
+func Version() string {
+	return ReadBuildInfo().String()
+}
+
+// String formats the version and revision of the build.
+func (b BuildInfo) String() string {
+	if b.Revision == "" {
+		return b.Version
+	}
+	rev := b.Revision
+	if len(rev) > 12 {
+		rev = rev[:12]
+	}
+	if b.Modified {
+		rev += "-dirty"
+	}
+	return b.Version + " " + rev
+}
+
+// newBuildInfo converts the build info read from the binary.
+func newBuildInfo(info *debug.BuildInfo) BuildInfo {
+	b := BuildInfo{
+		Version:   info.Main.Version,
+		GoVersion: info.GoVersion,
+	}
+	if b.Version == "" {
+		b.Version = "(devel)"
+	}
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			b.Revision = s.Value
+		case "vcs.time":
+			b.Time, _ = time.Parse(time.RFC3339, s.Value)
+		case "vcs.modified":
+			b.Modified = s.Value == "true"
+		}
+	}
+	return b
+}
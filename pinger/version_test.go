@@ -0,0 +1,52 @@
+package pinger
+
+import (
+	"runtime/debug"
+	"testing"
+)
+
+func TestBuildInfoString(t *testing.T) {
+	tests := []struct {
+		desc     string
+		info     *debug.BuildInfo
+		expected string
+	}{
+		{
+			desc: "released module",
+			info: &debug.BuildInfo{
+				Main: debug.Module{Version: "v1.2.0"},
+			},
+			expected: "v1.2.0",
+		},
+		{
+			desc: "working copy",
+			info: &debug.BuildInfo{
+				Main: debug.Module{Version: "(devel)"},
+				Settings: []debug.BuildSetting{
+					{Key: "vcs.revision", Value: "48b51f7c0ffee0123456789abcdef0123456789a"},
+					{Key: "vcs.time", Value: "2017-07-14T02:40:00Z"},
+					{Key: "vcs.modified", Value: "false"},
+				},
+			},
+			expected: "(devel) 48b51f7c0ffe",
+		},
+		{
+			desc: "modified working copy",
+			info: &debug.BuildInfo{
+				Settings: []debug.BuildSetting{
+					{Key: "vcs.revision", Value: "48b51f7"},
+					{Key: "vcs.modified", Value: "true"},
+				},
+			},
+			expected: "(devel) 48b51f7-dirty",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := newBuildInfo(tc.info).String(); got != tc.expected {
+				t.Errorf("wanted %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// progressWidth is the width of the progress bar, in characters.
+const progressWidth = 30
+
+// progress renders a progress bar with an ETA for runs with a fixed
+// number of requests. It is meant to be written to stderr, so it redraws
+// itself on a single line and is cleared before other output is printed.
+type progress struct {
+	w     io.Writer
+	total int
+	start time.Time
+}
+
+// newProgress returns a progress bar for total requests, starting now.
+func newProgress(w io.Writer, total int) *progress {
+	return &progress{
+		w:     w,
+		total: total,
+		start: time.Now(),
+	}
+}
+
+// update redraws the progress bar after done requests. It is a no-op on a
+// nil progress bar.
+func (p *progress) update(done int) {
+	if p == nil {
+		return
+	}
+	if done > p.total {
+		done = p.total
+	}
+
+	filled := progressWidth * done / p.total
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", progressWidth-filled)
+
+	// The first request is reported right away, so the pace can only be
+	// estimated from the time it took to get from it to the last one.
+	eta := "--"
+	if done > 1 {
+		pace := time.Since(p.start) / time.Duration(done-1)
+		eta = (pace * time.Duration(p.total-done)).Round(time.Second).String()
+	}
+
+	fmt.Fprintf(p.w, "\r[%s] %3d%% (%d/%d) ETA %s\x1b[K", bar, 100*done/p.total, done, p.total, eta)
+}
+
+// clear erases the progress bar, so other output can be printed. It is a
+// no-op on a nil progress bar.
+func (p *progress) clear() {
+	if p == nil {
+		return
+	}
+	fmt.Fprint(p.w, "\r\x1b[K")
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/caiofilipini/pingo/twamp"
+)
+
+// runReflector runs a TWAMP Light reflector on listenAddr until
+// interrupted.
+func runReflector(listenAddr string) {
+	r, err := twamp.Listen(listenAddr)
+	if err != nil {
+		fmt.Printf("cannot listen on %s: %v\n", listenAddr, err)
+		os.Exit(2)
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sig
+		r.Close()
+	}()
+
+	fmt.Printf("TWAMP Light reflector listening on %v\n", r.Addr())
+	if err := r.Serve(); err != nil && !errors.Is(err, net.ErrClosed) {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/caiofilipini/pingo/twamp"
+)
+
+// defaultResponderAddr is the address 'pingo respond' listens on when none
+// is specified: an unprivileged port of the loopback interface, so that
+// it runs without root and is not exposed to the network.
+const defaultResponderAddr = "127.0.0.1:8620"
+
+// runResponder answers pingo's probes on listenAddr until interrupted, for
+// end-to-end tests and demos without root or real targets: TWAMP Light test
+// packets over UDP (-twamp), adding delay to their round-trips and dropping
+// loss of them (0-1), and TCP handshakes (-tcp-fallback) on the same port,
+// which are completed by the kernel, without any delay or loss.
+func runResponder(listenAddr string, delay time.Duration, loss float64) {
+	r, err := twamp.Listen(listenAddr)
+	if err != nil {
+		fmt.Printf("cannot listen on %s: %v\n", listenAddr, err)
+		os.Exit(2)
+	}
+	r.Delay = delay
+	r.Loss = loss
+
+	// The TCP listener takes the port picked for UDP, in case it was 0.
+	addr := r.Addr().(*net.UDPAddr)
+	ln, err := net.ListenTCP("tcp4", &net.TCPAddr{IP: addr.IP, Port: addr.Port})
+	if err != nil {
+		r.Close()
+		fmt.Printf("cannot listen on %v: %v\n", addr, err)
+		os.Exit(2)
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sig
+		ln.Close()
+		r.Close()
+	}()
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	port := strconv.Itoa(addr.Port)
+	fmt.Printf("responder listening on %v, with %v delay and %.1f%% loss\n", addr, delay, loss*100)
+	fmt.Printf("probe it with -twamp %s, or -tcp-fallback %s where ICMP is not permitted\n", port, port)
+	if err := r.Serve(); err != nil && !errors.Is(err, net.ErrClosed) {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+// discoverSRV looks up the SRV records of name, e.g.
+// _sip._udp.example.com, returning the distinct target hosts they point
+// to, sorted by name.
+func discoverSRV(name string) ([]string, error) {
+	_, srvs, err := net.LookupSRV("", "", name)
+	if err != nil {
+		return nil, fmt.Errorf("cannot look up SRV records of %s: %v", name, err)
+	}
+
+	seen := make(map[string]bool, len(srvs))
+	var hosts []string
+	for _, srv := range srvs {
+		// A target of "." means the service is decidedly not available.
+		host := strings.TrimSuffix(srv.Target, ".")
+		if host == "" || seen[host] {
+			continue
+		}
+		seen[host] = true
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	return hosts, nil
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/caiofilipini/pingo/geoip"
+	"github.com/caiofilipini/pingo/math"
+	"github.com/caiofilipini/pingo/pinger"
+)
+
+// summary holds the end-of-run statistics for a host, as made available to
+// summary templates and printed as JSON, along with the version of pingo
+// that produced it. Latencies are in milliseconds.
+type summary struct {
+	Version        string             `json:"version"`
+	Host           string             `json:"host"`
+	Addr           string             `json:"addr"`
+	Location       *location          `json:"location,omitempty"`
+	StartTime      time.Time          `json:"start_time"`
+	Duration       float64            `json:"duration_s"`
+	Transmitted    int                `json:"transmitted"`
+	Received       int                `json:"received"`
+	PacketLoss     float64            `json:"packet_loss"`
+	Timeouts       int                `json:"timeouts"`
+	Errors         int                `json:"errors"`
+	Duplicates     int                `json:"duplicates"`
+	Corrupted      int                `json:"corrupted"`
+	Warmup         int                `json:"warmup"`
+	LossBursts     int                `json:"loss_bursts"`
+	MaxBurst       int                `json:"max_burst"`
+	MeanBurst      float64            `json:"mean_burst"`
+	Burstiness     float64            `json:"burstiness"`
+	RateLimited    bool               `json:"rate_limited,omitempty"`
+	Outages        []outage           `json:"outages"`
+	OutageCount    int                `json:"outage_count"`
+	LongestOutage  *outage            `json:"longest_outage,omitempty"`
+	Buckets        []bucket           `json:"buckets,omitempty"`
+	Min            float64            `json:"min_ms"`
+	Avg            float64            `json:"avg_ms"`
+	Max            float64            `json:"max_ms"`
+	StdDev         float64            `json:"stddev_ms"`
+	SRTT           float64            `json:"srtt_ms"`
+	RTTVar         float64            `json:"rttvar_ms"`
+	Trend          float64            `json:"trend_ms_per_min"`
+	Period         int                `json:"period,omitempty"`
+	Anomalies      int                `json:"anomalies"`
+	Warn           int                `json:"warn,omitempty"`
+	Crit           int                `json:"crit,omitempty"`
+	Responders     map[string]int     `json:"responders"`
+	ChecksumErrors int                `json:"checksum_errors"`
+	Missing        []int              `json:"missing,omitempty"`
+	Late           []int              `json:"late,omitempty"`
+	Bandwidth      float64            `json:"bandwidth_bps,omitempty"`
+	OneWay         *oneWay            `json:"one_way,omitempty"`
+	Percentiles    map[string]float64 `json:"percentiles_ms"`
+}
+
+// outage describes a period during which the host was unreachable. End
+// is nil if the outage is still ongoing.
+type outage struct {
+	Start    time.Time  `json:"start"`
+	End      *time.Time `json:"end"`
+	Duration float64    `json:"duration_s"`
+	Lost     int        `json:"lost"`
+}
+
+// bucket summarizes the requests sent within a time bucket.
+type bucket struct {
+	Start       time.Time `json:"start"`
+	Width       float64   `json:"width_s"`
+	Transmitted int       `json:"transmitted"`
+	Received    int       `json:"received"`
+	PacketLoss  float64   `json:"packet_loss"`
+	Min         float64   `json:"min_ms"`
+	Avg         float64   `json:"avg_ms"`
+	Max         float64   `json:"max_ms"`
+}
+
+// oneWay holds the average one-way delays estimated out of ICMP
+// Timestamp replies, and the asymmetry between them.
+type oneWay struct {
+	Forward   float64 `json:"forward_ms"`
+	Reverse   float64 `json:"reverse_ms"`
+	Asymmetry float64 `json:"asymmetry_ms"`
+}
+
+// location holds the geolocation and AS information of the host.
+type location struct {
+	Country string `json:"country,omitempty"`
+	City    string `json:"city,omitempty"`
+	ASN     uint   `json:"asn,omitempty"`
+	ASOrg   string `json:"as_org,omitempty"`
+}
+
+// newOutage converts o into an outage.
+func newOutage(o pinger.Outage) outage {
+	out := outage{
+		Start:    o.Start,
+		Duration: o.Duration().Seconds(),
+		Lost:     o.Lost,
+	}
+	if !o.Ongoing() {
+		out.End = &o.End
+	}
+	return out
+}
+
+// newSummary builds the summary for host out of stats, including the
+// percentiles ps, keyed by their names (e.g. "p99"), and loc, if known.
+func newSummary(host string, addr string, loc *geoip.Location, stats pinger.Stats, ps []float64) summary {
+	warn, crit := stats.Severities()
+	s := summary{
+		Version:        pinger.Version(),
+		Host:           host,
+		Addr:           addr,
+		StartTime:      stats.StartTime(),
+		Duration:       stats.Duration().Seconds(),
+		Transmitted:    stats.Transmitted(),
+		Received:       stats.Received(),
+		PacketLoss:     stats.PacketLoss(),
+		Timeouts:       stats.Timeouts(),
+		Errors:         stats.Errors(),
+		Duplicates:     stats.Duplicates(),
+		Corrupted:      stats.Corrupted(),
+		Warmup:         stats.Warmup(),
+		Burstiness:     stats.Burstiness(),
+		RateLimited:    stats.RateLimited(),
+		Anomalies:      stats.Anomalies(),
+		Warn:           warn,
+		Crit:           crit,
+		Responders:     stats.Responders(),
+		ChecksumErrors: stats.ChecksumErrors(),
+		Missing:        stats.Missing(),
+		Late:           stats.Late(),
+		Outages:        []outage{},
+		OutageCount:    stats.OutageCount(),
+		Percentiles:    make(map[string]float64, len(ps)),
+	}
+	if loc != nil {
+		s.Location = &location{
+			Country: loc.Country,
+			City:    loc.City,
+			ASN:     loc.ASN,
+			ASOrg:   loc.ASOrg,
+		}
+	}
+	for _, o := range stats.Outages() {
+		s.Outages = append(s.Outages, newOutage(o))
+	}
+	if o, ok := stats.LongestOutage(); ok {
+		longest := newOutage(o)
+		s.LongestOutage = &longest
+	}
+	for _, b := range stats.Buckets() {
+		s.Buckets = append(s.Buckets, bucket{
+			Start:       b.Start,
+			Width:       b.Width.Seconds(),
+			Transmitted: b.Transmitted,
+			Received:    b.Received,
+			PacketLoss:  b.PacketLoss(),
+			Min:         math.TimeInMillis(b.Min),
+			Avg:         math.TimeInMillis(b.Avg()),
+			Max:         math.TimeInMillis(b.Max),
+		})
+	}
+	s.LossBursts, s.MaxBurst, s.MeanBurst = stats.LossBursts()
+	s.Min, s.Avg, s.Max, s.StdDev = stats.RTTStats()
+	s.SRTT, s.RTTVar = stats.SmoothedRTT()
+	s.Trend, _ = stats.Trend()
+	s.Period, _ = stats.Periodicity()
+	s.Bandwidth, _ = stats.Bandwidth()
+	if forward, reverse, ok := stats.OneWayDelays(); ok {
+		s.OneWay = &oneWay{
+			Forward:   forward,
+			Reverse:   reverse,
+			Asymmetry: forward - reverse,
+		}
+	}
+	for i, p := range stats.Percentiles(ps...) {
+		s.Percentiles[percentileName(ps[i])] = p
+	}
+	return s
+}
+
+// percentileName returns the name of the p-th percentile, e.g. "p99.9".
+func percentileName(p float64) string {
+	return "p" + strconv.FormatFloat(p, 'f', -1, 64)
+}
@@ -0,0 +1,253 @@
+// Package twamp implements the test packets of TWAMP Light (RFC 5357,
+// Appendix I) in unauthenticated mode, along with a session reflector, so
+// that two-way and one-way delays can be measured against any reflector
+// supporting it, e.g. most routers.
+package twamp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/net/ipv4"
+)
+
+const (
+	// DefaultPort is the well-known TWAMP port, commonly used by
+	// TWAMP Light reflectors as well.
+	DefaultPort = 862
+
+	// TestPacketLen is the length of a test packet sent by a
+	// Session-Sender, without padding.
+	TestPacketLen = 14
+
+	// ReflectedPacketLen is the length of a test packet sent by a
+	// Session-Reflector, without padding. Senders pad their packets to at
+	// least this length, so that requests and replies have the same size.
+	ReflectedPacketLen = 41
+
+	// ntpEpochOffset is the number of seconds between the NTP epoch
+	// (1900) and the Unix epoch (1970).
+	ntpEpochOffset = 2208988800
+)
+
+// ErrorEstimate is the error estimate of unsynchronized clocks with a
+// resolution of a second, as nothing better is known about them.
+const ErrorEstimate uint16 = 0x0001
+
+// TestPacket is a test packet sent by a Session-Sender.
+type TestPacket struct {
+	// Seq is the sequence number of the packet.
+	Seq uint32
+
+	// Timestamp is the time the packet was sent at.
+	Timestamp time.Time
+
+	// ErrorEstimate is the error estimate of Timestamp.
+	ErrorEstimate uint16
+}
+
+// Marshal encodes the packet, padded with zeroes to size bytes.
+func (p TestPacket) Marshal(size int) []byte {
+	if size < TestPacketLen {
+		size = TestPacketLen
+	}
+	b := make([]byte, size)
+	binary.BigEndian.PutUint32(b[0:4], p.Seq)
+	putTimestamp(b[4:12], p.Timestamp)
+	binary.BigEndian.PutUint16(b[12:14], p.ErrorEstimate)
+	return b
+}
+
+// ParseTestPacket decodes a test packet sent by a Session-Sender.
+func ParseTestPacket(b []byte) (TestPacket, error) {
+	if len(b) < TestPacketLen {
+		return TestPacket{}, fmt.Errorf("test packet too short: %d bytes", len(b))
+	}
+	return TestPacket{
+		Seq:           binary.BigEndian.Uint32(b[0:4]),
+		Timestamp:     timestamp(b[4:12]),
+		ErrorEstimate: binary.BigEndian.Uint16(b[12:14]),
+	}, nil
+}
+
+// ReflectedPacket is a test packet sent by a Session-Reflector in response
+// to one sent by a Session-Sender.
+type ReflectedPacket struct {
+	// Seq is the sequence number of the packet, counted by the reflector.
+	Seq uint32
+
+	// Timestamp is the time the packet was sent at.
+	Timestamp time.Time
+
+	// ErrorEstimate is the error estimate of Timestamp.
+	ErrorEstimate uint16
+
+	// ReceiveTimestamp is the time the test packet was received at.
+	ReceiveTimestamp time.Time
+
+	// SenderSeq is the sequence number of the test packet.
+	SenderSeq uint32
+
+	// SenderTimestamp is the time the test packet was sent at.
+	SenderTimestamp time.Time
+
+	// SenderErrorEstimate is the error estimate of SenderTimestamp.
+	SenderErrorEstimate uint16
+
+	// SenderTTL is the TTL of the test packet when received, or 255 if
+	// unknown.
+	SenderTTL uint8
+}
+
+// Marshal encodes the packet, padded with zeroes to size bytes.
+func (p ReflectedPacket) Marshal(size int) []byte {
+	if size < ReflectedPacketLen {
+		size = ReflectedPacketLen
+	}
+	b := make([]byte, size)
+	binary.BigEndian.PutUint32(b[0:4], p.Seq)
+	putTimestamp(b[4:12], p.Timestamp)
+	binary.BigEndian.PutUint16(b[12:14], p.ErrorEstimate)
+	putTimestamp(b[16:24], p.ReceiveTimestamp)
+	binary.BigEndian.PutUint32(b[24:28], p.SenderSeq)
+	putTimestamp(b[28:36], p.SenderTimestamp)
+	binary.BigEndian.PutUint16(b[36:38], p.SenderErrorEstimate)
+	b[40] = p.SenderTTL
+	return b
+}
+
+// ParseReflectedPacket decodes a test packet sent by a Session-Reflector.
+func ParseReflectedPacket(b []byte) (ReflectedPacket, error) {
+	if len(b) < ReflectedPacketLen {
+		return ReflectedPacket{}, fmt.Errorf("reflected packet too short: %d bytes", len(b))
+	}
+	return ReflectedPacket{
+		Seq:                 binary.BigEndian.Uint32(b[0:4]),
+		Timestamp:           timestamp(b[4:12]),
+		ErrorEstimate:       binary.BigEndian.Uint16(b[12:14]),
+		ReceiveTimestamp:    timestamp(b[16:24]),
+		SenderSeq:           binary.BigEndian.Uint32(b[24:28]),
+		SenderTimestamp:     timestamp(b[28:36]),
+		SenderErrorEstimate: binary.BigEndian.Uint16(b[36:38]),
+		SenderTTL:           b[40],
+	}, nil
+}
+
+// putTimestamp encodes t into b in the NTP timestamp format: seconds since
+// 1900, followed by the fraction of a second in units of 2^-32 seconds.
+func putTimestamp(b []byte, t time.Time) {
+	secs := uint64(t.Unix() + ntpEpochOffset)
+	frac := (uint64(t.Nanosecond()) << 32) / uint64(time.Second)
+	binary.BigEndian.PutUint32(b[0:4], uint32(secs))
+	binary.BigEndian.PutUint32(b[4:8], uint32(frac))
+}
+
+// timestamp decodes a timestamp in the NTP format out of b.
+func timestamp(b []byte) time.Time {
+	secs := int64(binary.BigEndian.Uint32(b[0:4])) - ntpEpochOffset
+	frac := uint64(binary.BigEndian.Uint32(b[4:8]))
+	nsecs := (frac*uint64(time.Second) + 1<<31) >> 32
+	return time.Unix(secs, int64(nsecs))
+}
+
+// Reflector is a stateless TWAMP Light Session-Reflector, reflecting every
+// test packet it receives back to its sender.
+type Reflector struct {
+	// Delay is added to the round-trip of every test packet, as if the
+	// reflector were that much further away, split evenly between both
+	// directions, e.g. for demos and tests against the local host.
+	Delay time.Duration
+
+	// Loss is the probability (0-1) of every test packet being dropped
+	// rather than reflected.
+	Loss float64
+
+	conn *ipv4.PacketConn
+
+	// mu guards seq, as delayed packets are reflected concurrently.
+	mu  sync.Mutex
+	seq uint32
+}
+
+// Listen returns a Reflector listening on the UDP address addr, e.g.
+// ":862".
+func Listen(addr string) (*Reflector, error) {
+	conn, err := net.ListenPacket("udp4", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	pconn := ipv4.NewPacketConn(conn)
+	// The TTL of test packets is reported as 255 where unsupported.
+	_ = pconn.SetControlMessage(ipv4.FlagTTL, true)
+	return &Reflector{conn: pconn}, nil
+}
+
+// Addr returns the local address the reflector is listening on.
+func (r *Reflector) Addr() net.Addr {
+	return r.conn.LocalAddr()
+}
+
+// Serve reflects test packets until the reflector is closed, returning
+// the error that stopped it. It is not safe for concurrent use, and Delay
+// and Loss must not be changed while serving.
+func (r *Reflector) Serve() error {
+	buf := make([]byte, 65535)
+	for {
+		n, cm, peer, err := r.conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+		receivedAt := time.Now()
+
+		pkt, err := ParseTestPacket(buf[:n])
+		if err != nil || (r.Loss > 0 && rand.Float64() < r.Loss) {
+			continue
+		}
+		ttl := uint8(255)
+		if cm != nil && cm.TTL != 0 {
+			ttl = uint8(cm.TTL)
+		}
+
+		reply := ReflectedPacket{
+			ErrorEstimate:       ErrorEstimate,
+			ReceiveTimestamp:    receivedAt.Add(r.Delay / 2),
+			SenderSeq:           pkt.Seq,
+			SenderTimestamp:     pkt.Timestamp,
+			SenderErrorEstimate: pkt.ErrorEstimate,
+			SenderTTL:           ttl,
+		}
+		if r.Delay <= 0 {
+			r.reflect(reply, n, peer)
+			continue
+		}
+		time.AfterFunc(r.Delay, func() {
+			r.reflect(reply, n, peer)
+		})
+	}
+}
+
+// reflect sends reply, of size bytes, to peer. Failing to reflect a single
+// packet is reported to its sender as a loss, so the reflector keeps
+// serving the others.
+func (r *Reflector) reflect(reply ReflectedPacket, size int, peer net.Addr) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reply.Seq = r.seq
+	// The delay is accounted for as if the reflector had received the
+	// packet later, and sent the reply earlier, than it did.
+	reply.Timestamp = time.Now().Add(-r.Delay / 2)
+	if _, err := r.conn.WriteTo(reply.Marshal(size), nil, peer); err == nil {
+		r.seq++
+	}
+}
+
+// Close stops the reflector.
+func (r *Reflector) Close() error {
+	return r.conn.Close()
+}
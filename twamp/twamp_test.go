@@ -0,0 +1,198 @@
+package twamp
+
+import (
+	"encoding/binary"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTimestamp(t *testing.T) {
+	tests := []struct {
+		desc     string
+		time     time.Time
+		secs     uint32
+		frac     uint32
+		expected time.Time
+	}{
+		{
+			desc:     "unix epoch",
+			time:     time.Unix(0, 0),
+			secs:     ntpEpochOffset,
+			frac:     0,
+			expected: time.Unix(0, 0),
+		},
+		{
+			desc:     "half a second",
+			time:     time.Unix(1500000000, 500000000),
+			secs:     1500000000 + ntpEpochOffset,
+			frac:     1 << 31,
+			expected: time.Unix(1500000000, 500000000),
+		},
+		{
+			desc:     "nanoseconds",
+			time:     time.Unix(1500000000, 123456789),
+			secs:     1500000000 + ntpEpochOffset,
+			frac:     530242871,
+			expected: time.Unix(1500000000, 123456789),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			b := make([]byte, 8)
+			putTimestamp(b, tc.time)
+
+			if secs := binary.BigEndian.Uint32(b[0:4]); secs != tc.secs {
+				t.Errorf("wanted seconds %v, got %v", tc.secs, secs)
+			}
+			if frac := binary.BigEndian.Uint32(b[4:8]); frac != tc.frac {
+				t.Errorf("wanted fraction %v, got %v", tc.frac, frac)
+			}
+			if got := timestamp(b); !got.Equal(tc.expected) {
+				t.Errorf("wanted %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestParseReflectedPacket(t *testing.T) {
+	sentAt := time.Unix(1500000000, 0)
+	expected := ReflectedPacket{
+		Seq:                 7,
+		Timestamp:           sentAt.Add(11 * time.Millisecond),
+		ErrorEstimate:       ErrorEstimate,
+		ReceiveTimestamp:    sentAt.Add(10 * time.Millisecond),
+		SenderSeq:           42,
+		SenderTimestamp:     sentAt,
+		SenderErrorEstimate: ErrorEstimate,
+		SenderTTL:           61,
+	}
+
+	b := expected.Marshal(0)
+	if len(b) != ReflectedPacketLen {
+		t.Fatalf("wanted %v bytes, got %v", ReflectedPacketLen, len(b))
+	}
+
+	got, err := ParseReflectedPacket(b)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("wanted %+v, got %+v", expected, got)
+	}
+
+	if _, err := ParseReflectedPacket(b[:TestPacketLen]); err == nil {
+		t.Error("wanted an error for a truncated packet, got nil")
+	}
+}
+
+func TestReflector(t *testing.T) {
+	r, err := Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %v", err)
+	}
+	defer r.Close()
+	go r.Serve()
+
+	conn, err := net.Dial("udp4", r.Addr().String())
+	if err != nil {
+		t.Fatalf("cannot dial reflector: %v", err)
+	}
+	defer conn.Close()
+
+	sentAt := time.Now()
+	pkt := TestPacket{Seq: 42, Timestamp: sentAt, ErrorEstimate: ErrorEstimate}
+	if _, err := conn.Write(pkt.Marshal(ReflectedPacketLen)); err != nil {
+		t.Fatalf("cannot send test packet: %v", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, 1500)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("cannot read reflected packet: %v", err)
+	}
+
+	reply, err := ParseReflectedPacket(buf[:n])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if reply.SenderSeq != pkt.Seq {
+		t.Errorf("wanted sender seq %v, got %v", pkt.Seq, reply.SenderSeq)
+	}
+	if d := reply.SenderTimestamp.Sub(sentAt); d < -time.Nanosecond || d > time.Nanosecond {
+		t.Errorf("wanted sender timestamp %v, got %v", sentAt, reply.SenderTimestamp)
+	}
+	if reply.ReceiveTimestamp.Before(reply.SenderTimestamp.Add(-time.Nanosecond)) || reply.Timestamp.Before(reply.ReceiveTimestamp) {
+		t.Errorf("wanted ordered timestamps, got sent %v, received %v, reflected %v", reply.SenderTimestamp, reply.ReceiveTimestamp, reply.Timestamp)
+	}
+}
+
+func TestReflectorDelay(t *testing.T) {
+	r, err := Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %v", err)
+	}
+	defer r.Close()
+	r.Delay = 50 * time.Millisecond
+	go r.Serve()
+
+	conn, err := net.Dial("udp4", r.Addr().String())
+	if err != nil {
+		t.Fatalf("cannot dial reflector: %v", err)
+	}
+	defer conn.Close()
+
+	sentAt := time.Now()
+	pkt := TestPacket{Seq: 7, Timestamp: sentAt, ErrorEstimate: ErrorEstimate}
+	if _, err := conn.Write(pkt.Marshal(ReflectedPacketLen)); err != nil {
+		t.Fatalf("cannot send test packet: %v", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, 1500)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("cannot read reflected packet: %v", err)
+	}
+	arrival := time.Now()
+
+	reply, err := ParseReflectedPacket(buf[:n])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The round-trip excludes the time spent by the reflector, as a
+	// sender computes it, which must not make up for the delay.
+	rtt := arrival.Sub(sentAt) - reply.Timestamp.Sub(reply.ReceiveTimestamp)
+	if rtt < r.Delay {
+		t.Errorf("wanted a round-trip of at least %v, got %v", r.Delay, rtt)
+	}
+}
+
+func TestReflectorLoss(t *testing.T) {
+	r, err := Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %v", err)
+	}
+	defer r.Close()
+	r.Loss = 1
+	go r.Serve()
+
+	conn, err := net.Dial("udp4", r.Addr().String())
+	if err != nil {
+		t.Fatalf("cannot dial reflector: %v", err)
+	}
+	defer conn.Close()
+
+	pkt := TestPacket{Seq: 1, Timestamp: time.Now(), ErrorEstimate: ErrorEstimate}
+	if _, err := conn.Write(pkt.Marshal(ReflectedPacketLen)); err != nil {
+		t.Fatalf("cannot send test packet: %v", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if _, err := conn.Read(make([]byte, 1500)); err == nil {
+		t.Error("wanted the test packet to be dropped, got a reply")
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/caiofilipini/pingo/pinger"
+)
+
+// discoverer returns the hosts currently registered with a discovery
+// source, sorted by name.
+type discoverer func() ([]string, error)
+
+// maxWatchBackoff bounds the number of intervals a host whose pinger
+// failed is left alone for before being pinged again.
+const maxWatchBackoff = 16
+
+// watchTargets pings the hosts returned by discover simultaneously,
+// querying it again every interval so that hosts are added and removed as
+// they change, until interrupted. Results are printed as they arrive,
+// labeled with their host, followed by a comparative summary and, for
+// several hosts, an aggregate of them. A host added again after being
+// removed is summarized over its latest run, and one whose pinger fails is
+// pinged again after exponentially more intervals. source describes the
+// discovery source, and round-trip latencies are printed in unit.
+func watchTargets(source string, discover discoverer, interval time.Duration, unit string, opts pinger.Options) {
+	hosts, err := discover()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	if len(hosts) == 0 {
+		fmt.Printf("no targets found in %s\n", source)
+		os.Exit(2)
+	}
+	fmt.Printf("PING %s, re-queried every %v: %d data bytes\n", source, interval, opts.PacketSize)
+
+	type result struct {
+		label  string
+		pinger pinger.Pinger
+		ping   pinger.Ping
+		err    error
+	}
+	results := make(chan result)
+
+	// Targets are kept by host, in the order they were first added, so
+	// that each host is summarized once however often it comes and goes.
+	type target struct {
+		probe    probe
+		pinger   pinger.Pinger
+		running  bool
+		failures int
+		retryAt  time.Time
+	}
+	var (
+		wg    sync.WaitGroup
+		all   = make(map[string]*target)
+		order []string
+		width int
+	)
+	start := func(host string) {
+		addr, err := opts.Resolve(context.Background(), host)
+		if err != nil {
+			fmt.Printf("failed to resolve host %s: %v\n", host, err)
+			return
+		}
+		t, ok := all[host]
+		if !ok {
+			t = &target{}
+			all[host] = t
+			order = append(order, host)
+		}
+		t.probe = probe{label: host, addr: addr, opts: opts}
+		p := pinger.NewPinger(&t.probe.opts)
+		t.pinger = p
+		t.running = true
+		if len(host) > width {
+			width = len(host)
+		}
+		fmt.Printf("+ %s (%v)\n", host, addr)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			pings, errs := p.Report()
+			go p.Ping(addr)
+
+			for ping := range pings {
+				results <- result{label: host, pinger: p, ping: ping}
+			}
+			if err, ok := <-errs; ok {
+				results <- result{label: host, pinger: p, err: fmt.Errorf("failed to ping %v (%s): %v", addr, host, err)}
+			}
+		}()
+	}
+	for _, host := range hosts {
+		start(host)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+
+	interrupted := false
+	for stop := false; !stop; {
+		select {
+		case <-sig:
+			// A second interrupt terminates right away, even if the
+			// requests in flight hang.
+			if interrupted {
+				for _, t := range all {
+					t.pinger.StopWait(0)
+				}
+				stop = true
+				continue
+			}
+			interrupted = true
+			for _, t := range all {
+				t.pinger.Stop()
+			}
+			go func() {
+				wg.Wait()
+				close(results)
+			}()
+		case <-ticker.C:
+			if interrupted {
+				continue
+			}
+			hosts, err := discover()
+			if err != nil {
+				// Keep pinging the targets known so far.
+				fmt.Println(err)
+				continue
+			}
+			current := make(map[string]bool, len(hosts))
+			now := time.Now()
+			for _, host := range hosts {
+				current[host] = true
+				if t, ok := all[host]; ok && (t.running || now.Before(t.retryAt)) {
+					continue
+				}
+				start(host)
+			}
+			for host, t := range all {
+				if t.running && !current[host] {
+					fmt.Printf("- %s\n", host)
+					t.pinger.Stop()
+					t.running = false
+				}
+			}
+		case res, ok := <-results:
+			if !ok {
+				stop = true
+				continue
+			}
+			// Results of the earlier runs of hosts added again are
+			// printed, but do not affect the current ones.
+			t := all[res.label]
+			current := t.pinger == res.pinger
+			if res.err != nil {
+				fmt.Println(res.err)
+				if current && t.running {
+					t.running = false
+					t.failures++
+					backoff := 1 << (t.failures - 1)
+					if backoff > maxWatchBackoff {
+						backoff = maxWatchBackoff
+					}
+					t.retryAt = time.Now().Add(time.Duration(backoff) * interval)
+					fmt.Printf("- %s, retrying in %v\n", res.label, time.Duration(backoff)*interval)
+				}
+				continue
+			}
+			if current {
+				t.failures = 0
+			}
+			fmt.Printf("%-*s icmp_seq=%d %s\n", width, res.label, res.ping.Seq, formatStatus(res.ping, unit))
+		}
+	}
+
+	probes := make([]probe, len(order))
+	stats := make([]pinger.Stats, len(order))
+	var total aggregate
+	for i, host := range order {
+		probes[i] = all[host].probe
+		stats[i] = all[host].pinger.Stats()
+		total.addStats(host, stats[i])
+	}
+	printComparison(probes, stats, width, unit)
+	if len(order) > 1 {
+		fmt.Println(total.format(unit))
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+)
+
+// defaultWakeAddr is the address Wake-on-LAN magic packets are sent to by
+// default: the limited broadcast address, on the discard port.
+const defaultWakeAddr = "255.255.255.255:9"
+
+// magicPacket returns the Wake-on-LAN magic packet waking up the host with
+// the given MAC address: six 0xff bytes, followed by sixteen repetitions
+// of the address.
+func magicPacket(mac net.HardwareAddr) []byte {
+	return append(bytes.Repeat([]byte{0xff}, 6), bytes.Repeat(mac, 16)...)
+}
+
+// wake sends the Wake-on-LAN magic packet for mac to addr, usually a
+// broadcast address of the segment the host is attached to.
+func wake(mac net.HardwareAddr, addr string) error {
+	if len(mac) != 6 {
+		return fmt.Errorf("cannot wake %s: not an EUI-48 address", mac)
+	}
+
+	conn, err := net.Dial("udp4", addr)
+	if err != nil {
+		return fmt.Errorf("cannot send magic packet to %s: %v", addr, err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write(magicPacket(mac)); err != nil {
+		return fmt.Errorf("cannot send magic packet to %s: %v", addr, err)
+	}
+	return nil
+}
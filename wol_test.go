@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+func TestMagicPacket(t *testing.T) {
+	mac, err := net.ParseMAC("00:11:22:aa:bb:cc")
+	if err != nil {
+		t.Fatalf("cannot parse MAC address: %v", err)
+	}
+
+	pkt := magicPacket(mac)
+	if len(pkt) != 102 {
+		t.Fatalf("wanted %v bytes, got %v", 102, len(pkt))
+	}
+	if sync := pkt[:6]; !bytes.Equal(sync, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}) {
+		t.Errorf("wanted a synchronization stream of 0xff bytes, got % x", sync)
+	}
+	for i := 0; i < 16; i++ {
+		if rep := pkt[6+6*i : 12+6*i]; !bytes.Equal(rep, mac) {
+			t.Errorf("wanted repetition %d to be %v, got %v", i, mac, net.HardwareAddr(rep))
+		}
+	}
+}
+
+func TestWakeInvalidMAC(t *testing.T) {
+	mac, err := net.ParseMAC("00:11:22:33:44:55:66:77")
+	if err != nil {
+		t.Fatalf("cannot parse MAC address: %v", err)
+	}
+	if err := wake(mac, "127.0.0.1:9"); err == nil {
+		t.Errorf("wanted an error for an EUI-64 address")
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/caiofilipini/pingo/pinger"
+)
+
+const (
+	// zabbixPort is the default port of Zabbix servers and proxies.
+	zabbixPort = "10051"
+
+	// zabbixTimeout is the timeout of exchanges with Zabbix servers and
+	// proxies.
+	zabbixTimeout = 10 * time.Second
+
+	// zabbixMaxResponseLen bounds the length of the responses read.
+	zabbixMaxResponseLen = 1 << 16
+)
+
+// zabbixHeader starts every message of the Zabbix protocol, followed by the
+// flags (0x01, with neither compression nor large packets) and the length
+// of the data.
+var zabbixHeader = []byte("ZBXD\x01")
+
+// zabbixItem is the value of a trapper item, as sent by zabbix_sender.
+type zabbixItem struct {
+	Host  string `json:"host"`
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	Clock int64  `json:"clock"`
+}
+
+// zabbixSender pushes item values to a Zabbix server or proxy with the
+// sender protocol, as zabbix_sender does.
+type zabbixSender struct {
+	addr string
+
+	// host is the name of the host the items belong to in Zabbix.
+	host string
+}
+
+// newZabbixSender returns a sender of values to the Zabbix server or proxy
+// at addr, whose port defaults to 10051, for the items of host.
+func newZabbixSender(addr string, host string) *zabbixSender {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, zabbixPort)
+	}
+	return &zabbixSender{addr: addr, host: host}
+}
+
+// items returns the values of the items of target for stats, collected at
+// clock: the packet loss in percent, and the min, average and max
+// round-trip latencies in seconds, if any replies were received, keyed like
+//
+//	pingo.loss[target]
+//	pingo.rtt.avg[target]
+//
+// after the simple checks of Zabbix (icmppingloss, icmppingsec). There are
+// none if no requests were sent.
+func (z *zabbixSender) items(target string, stats pinger.Stats, clock time.Time) []zabbixItem {
+	var items []zabbixItem
+	if stats.Transmitted() == 0 {
+		return items
+	}
+	add := func(key string, value float64) {
+		items = append(items, zabbixItem{
+			Host:  z.host,
+			Key:   fmt.Sprintf("pingo.%s[%s]", key, target),
+			Value: fmt.Sprintf("%g", value),
+			Clock: clock.Unix(),
+		})
+	}
+
+	add("loss", stats.PacketLoss())
+	if stats.Received() > 0 {
+		rtts := stats.RTTSummary()
+		add("rtt.min", rtts.Min.Seconds())
+		add("rtt.avg", rtts.Avg.Seconds())
+		add("rtt.max", rtts.Max.Seconds())
+	}
+	return items
+}
+
+// send pushes items to the Zabbix server or proxy, returning an error if
+// any of them were not processed, e.g. because no such trapper items are
+// configured.
+func (z *zabbixSender) send(items []zabbixItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(struct {
+		Request string       `json:"request"`
+		Data    []zabbixItem `json:"data"`
+		Clock   int64        `json:"clock"`
+	}{"sender data", items, time.Now().Unix()})
+	if err != nil {
+		return fmt.Errorf("cannot encode Zabbix items: %v", err)
+	}
+
+	conn, err := net.DialTimeout("tcp", z.addr, zabbixTimeout)
+	if err != nil {
+		return fmt.Errorf("cannot connect to Zabbix at %s: %v", z.addr, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(zabbixTimeout))
+
+	msg := make([]byte, len(zabbixHeader)+8, len(zabbixHeader)+8+len(data))
+	copy(msg, zabbixHeader)
+	binary.LittleEndian.PutUint64(msg[len(zabbixHeader):], uint64(len(data)))
+	if _, err := conn.Write(append(msg, data...)); err != nil {
+		return fmt.Errorf("cannot send items to Zabbix at %s: %v", z.addr, err)
+	}
+
+	header := make([]byte, len(zabbixHeader)+8)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return fmt.Errorf("cannot read response from Zabbix at %s: %v", z.addr, err)
+	}
+	n := binary.LittleEndian.Uint64(header[len(zabbixHeader):])
+	if !bytes.Equal(header[:len(zabbixHeader)], zabbixHeader) || n > zabbixMaxResponseLen {
+		return fmt.Errorf("unexpected response from Zabbix at %s", z.addr)
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(conn, body); err != nil {
+		return fmt.Errorf("cannot read response from Zabbix at %s: %v", z.addr, err)
+	}
+
+	var res struct {
+		Response string `json:"response"`
+		Info     string `json:"info"`
+	}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return fmt.Errorf("cannot decode response from Zabbix at %s: %v", z.addr, err)
+	}
+	if res.Response != "success" {
+		return fmt.Errorf("Zabbix at %s answered %s: %s", z.addr, res.Response, res.Info)
+	}
+
+	// The info reads like "processed: 3; failed: 1; total: 4; seconds
+	// spent: 0.000055".
+	for _, field := range strings.Split(res.Info, ";") {
+		var failed int
+		if _, err := fmt.Sscanf(strings.TrimSpace(field), "failed: %d", &failed); err == nil && failed > 0 {
+			return fmt.Errorf("Zabbix at %s failed to process %d of %d items (%s)", z.addr, failed, len(items), res.Info)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// zabbixRequest is a request received by a fake Zabbix server.
+type zabbixRequest struct {
+	header []byte
+	data   struct {
+		Request string       `json:"request"`
+		Data    []zabbixItem `json:"data"`
+	}
+	err error
+}
+
+// serveZabbix accepts a single connection on ln, reads as much data as the
+// length in the header of the request sent through it tells, decodes it
+// and answers with response and info, reporting the request to the
+// returned channel.
+func serveZabbix(ln net.Listener, response string, info string) <-chan zabbixRequest {
+	reqs := make(chan zabbixRequest, 1)
+	go func() {
+		var req zabbixRequest
+		defer func() { reqs <- req }()
+
+		conn, err := ln.Accept()
+		if err != nil {
+			req.err = err
+			return
+		}
+		defer conn.Close()
+		// A length shorter than the data fails decoding it, and a longer
+		// one times out reading it.
+		conn.SetDeadline(time.Now().Add(time.Second))
+
+		req.header = make([]byte, len(zabbixHeader)+8)
+		if _, req.err = io.ReadFull(conn, req.header); req.err != nil {
+			return
+		}
+		data := make([]byte, binary.LittleEndian.Uint64(req.header[len(zabbixHeader):]))
+		if _, req.err = io.ReadFull(conn, data); req.err != nil {
+			return
+		}
+		if req.err = json.Unmarshal(data, &req.data); req.err != nil {
+			return
+		}
+
+		body, _ := json.Marshal(map[string]string{"response": response, "info": info})
+		msg := make([]byte, len(zabbixHeader)+8)
+		copy(msg, zabbixHeader)
+		binary.LittleEndian.PutUint64(msg[len(zabbixHeader):], uint64(len(body)))
+		conn.Write(append(msg, body...))
+	}()
+	return reqs
+}
+
+func TestZabbixSend(t *testing.T) {
+	items := []zabbixItem{
+		{Host: "probe", Key: "pingo.loss[example.com]", Value: "0", Clock: 1700000000},
+		{Host: "probe", Key: "pingo.rtt.avg[example.com]", Value: "0.0125", Clock: 1700000000},
+	}
+
+	tests := []struct {
+		desc     string
+		response string
+		info     string
+		wantErr  bool
+	}{
+		{
+			desc:     "all items processed",
+			response: "success",
+			info:     "processed: 2; failed: 0; total: 2; seconds spent: 0.000055",
+		},
+		{
+			desc:     "some items failed",
+			response: "success",
+			info:     "processed: 1; failed: 1; total: 2; seconds spent: 0.000055",
+			wantErr:  true,
+		},
+		{
+			desc:     "request rejected",
+			response: "failed",
+			info:     "invalid request",
+			wantErr:  true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			ln, err := net.Listen("tcp4", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("cannot listen: %v", err)
+			}
+			defer ln.Close()
+			reqs := serveZabbix(ln, tc.response, tc.info)
+
+			err = newZabbixSender(ln.Addr().String(), "probe").send(items)
+			if tc.wantErr && err == nil {
+				t.Errorf("wanted an error, got none")
+			}
+			if !tc.wantErr && err != nil {
+				t.Errorf("wanted no error, got %v", err)
+			}
+
+			req := <-reqs
+			if req.err != nil {
+				t.Fatalf("cannot read request: %v", req.err)
+			}
+			if !bytes.Equal(req.header[:len(zabbixHeader)], zabbixHeader) {
+				t.Errorf("wanted header %q, got %q", zabbixHeader, req.header[:len(zabbixHeader)])
+			}
+			if req.data.Request != "sender data" {
+				t.Errorf("wanted %q, got %q", "sender data", req.data.Request)
+			}
+			if !reflect.DeepEqual(req.data.Data, items) {
+				t.Errorf("wanted %+v, got %+v", items, req.data.Data)
+			}
+		})
+	}
+}